@@ -95,8 +95,31 @@
 // All log messages automatically include:
 //   - cmd: Command name (passed to Init functions)
 //   - hostname: Current hostname
+//   - trace_id and span_id: When the context carries an OpenTelemetry span
 //   - Any attributes added to the context via WithLogAttrs
 //
+// WithTenant stores the tenant of a request in the context and adds it to the
+// log attributes. TenantFromContext reads it back, and ValidTenant checks that
+// a tenant ID is safe to use in database names and storage keys.
+//
+// # Tracing
+//
+// Init also sets up OpenTelemetry tracing with W3C trace context propagation.
+// Spans are only exported when an exporter is configured:
+//
+//	otel:
+//	  exporter: otlp          # stdout or otlp, tracing is disabled if unset
+//	  endpoint: localhost:4317
+//	  insecure: true
+//	  sampling_ratio: 0.1     # defaults to 1 (sample everything)
+//	  service_name: myapp     # defaults to the command name
+//
+// The stdout exporter prints spans to the console for local development,
+// while otlp sends them to an OpenTelemetry collector over gRPC. Call
+// Shutdown before exiting to flush buffered spans:
+//
+//	defer func() { _ = app.Shutdown(context.Background()) }()
+//
 // # Configuration Access
 //
 // Access configuration values using the Config() function:
@@ -26,6 +26,7 @@ func Init(cmd string) {
 	workdir, _ := os.Getwd()
 	initConfig(path.Join(workdir, "configs"))
 	initLog()
+	initTracing()
 	slog.Info("APP initialized")
 }
 
@@ -37,5 +38,6 @@ func InitWithConfigPath(cmd string, configPath string) {
 	}
 	initConfig(configPath)
 	initLog()
+	initTracing()
 	slog.Info("APP initialized")
 }
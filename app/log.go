@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/lmittmann/tint"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey string
@@ -42,6 +43,13 @@ type logHandler struct {
 	slog.Handler
 }
 
+// NewLogHandler wraps the given handler so that records are enriched with the
+// cmd, hostname and context attributes added via WithLogAttrs, the same way
+// the default logger configured by Init is.
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return &logHandler{h}
+}
+
 func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
 	existingAttrs, ok := ctx.Value(logAttrsKey).([]slog.Attr)
 	if !ok {
@@ -58,6 +66,14 @@ func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
 		r.AddAttrs(slog.String("hostname", hostname))
 	}
 
+	// Correlate logs with the active trace
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", spanCtx.TraceID().String()),
+			slog.String("span_id", spanCtx.SpanID().String()),
+		)
+	}
+
 	// Add log fields from context
 	if attrs, ok := ctx.Value(logAttrsKey).([]slog.Attr); ok {
 		for _, v := range attrs {
@@ -80,6 +96,6 @@ func initLog() {
 	default:
 		handler = tint.NewHandler(os.Stdout, &tint.Options{Level: logLevel})
 	}
-	handler = &logHandler{handler}
+	handler = NewLogHandler(handler)
 	slog.SetDefault(slog.New(handler))
 }
@@ -8,6 +8,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestWithLogFields(t *testing.T) {
@@ -219,3 +221,26 @@ func TestLogHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestLogHandler_TraceContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil)))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(
+		trace.SpanContextConfig{TraceID: traceID, SpanID: spanID},
+	))
+	logger.InfoContext(ctx, "traced message")
+
+	var logEntry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &logEntry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if got := logEntry["trace_id"]; got != traceID.String() {
+		t.Errorf("Field trace_id = %v, want %s", got, traceID)
+	}
+	if got := logEntry["span_id"]; got != spanID.String() {
+		t.Errorf("Field span_id = %v, want %s", got, spanID)
+	}
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+)
+
+const tenantKey contextKey = "tenant"
+
+// ErrNoTenant is returned by tenant scoped helpers when the context carries
+// no tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+// tenantPattern restricts tenant IDs to characters that are safe in database
+// names, key prefixes and log lines. Uppercase letters are excluded since
+// MongoDB rejects database names differing only by case.
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ValidTenant reports whether tenant is a valid tenant ID: 1 to 63 lowercase
+// letters, digits, '-' or '_', starting with a letter or digit.
+func ValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// WithTenant returns a copy of ctx carrying the tenant ID, which is also added
+// to the log attributes as "tenant".
+//
+// The gorm_client, mongo_client and object_storage tenant helpers read the
+// tenant from the context, so it is resolved once per request, typically by
+// the grpc_utils tenant interceptor.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	ctx = context.WithValue(ctx, tenantKey, tenant)
+	return WithLogAttrs(ctx, slog.String("tenant", tenant))
+}
+
+// TenantFromContext returns the tenant ID stored in ctx by WithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey).(string)
+	return tenant, ok && tenant != ""
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithTenant(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := TenantFromContext(ctx); ok {
+		t.Error("Expected no tenant in empty context")
+	}
+
+	ctx = WithTenant(ctx, "acme")
+	tenant, ok := TenantFromContext(ctx)
+	if !ok || tenant != "acme" {
+		t.Errorf("Expected tenant acme, got %q", tenant)
+	}
+
+	attrs, _ := ctx.Value(logAttrsKey).([]slog.Attr)
+	if len(attrs) != 1 || attrs[0].Key != "tenant" || attrs[0].Value.String() != "acme" {
+		t.Errorf("Expected tenant log attr, got %v", attrs)
+	}
+}
+
+func TestValidTenant(t *testing.T) {
+	tests := []struct {
+		tenant string
+		valid  bool
+	}{
+		{"acme", true},
+		{"acme-corp_2", true},
+		{"", false},
+		{"-acme", false},
+		{"Acme", false},
+		{"../other", false},
+		{"acme/other", false},
+		{"acme.other", false},
+		{strings.Repeat("a", 63), true},
+		{strings.Repeat("a", 64), false},
+	}
+	for _, tt := range tests {
+		if got := ValidTenant(tt.tenant); got != tt.valid {
+			t.Errorf("ValidTenant(%q): expected %v, got %v", tt.tenant, tt.valid, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	configKeyOtelExporter      = "otel.exporter"
+	configKeyOtelEndpoint      = "otel.endpoint"
+	configKeyOtelInsecure      = "otel.insecure"
+	configKeyOtelSamplingRatio = "otel.sampling_ratio"
+	configKeyOtelServiceName   = "otel.service_name"
+
+	otelExporterStdout = "stdout"
+	otelExporterOTLP   = "otlp"
+)
+
+var tracerProvider *sdktrace.TracerProvider
+
+// initTracing sets up the global OpenTelemetry tracer provider and W3C trace
+// context propagation. Tracing stays disabled unless otel.exporter is set.
+func initTracing() {
+	// Stop the provider of a previous Init before replacing it
+	if tracerProvider != nil {
+		_ = tracerProvider.Shutdown(context.Background())
+		tracerProvider = nil
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporterName := config.GetString(configKeyOtelExporter)
+	if exporterName == "" {
+		return
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch exporterName {
+	case otelExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case otelExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if endpoint := config.GetString(configKeyOtelEndpoint); endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		if config.GetBool(configKeyOtelInsecure) {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+	default:
+		panic(fmt.Sprintf("unsupported otel exporter: %s", exporterName))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	serviceName := config.GetString(configKeyOtelServiceName)
+	if serviceName == "" {
+		serviceName = cmdName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		panic(err)
+	}
+
+	samplingRatio := 1.0
+	if config.IsSet(configKeyOtelSamplingRatio) {
+		samplingRatio = config.GetFloat64(configKeyOtelSamplingRatio)
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplingRatio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	slog.Info("Tracing initialized",
+		"exporter", exporterName,
+		"service_name", serviceName,
+		"sampling_ratio", samplingRatio,
+	)
+}
+
+// Shutdown flushes and stops the tracer provider set up by Init. It should be
+// called before the application exits so buffered spans are exported.
+func Shutdown(ctx context.Context) error {
+	if tracerProvider == nil {
+		return nil
+	}
+	return tracerProvider.Shutdown(ctx)
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestInitTracing(t *testing.T) {
+	configDir := t.TempDir()
+	config := `
+log:
+  format: json
+otel:
+  exporter: stdout
+  sampling_ratio: 0.5
+  service_name: tracing-test
+`
+	err := os.WriteFile(filepath.Join(configDir, "default.yaml"), []byte(config), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	InitWithConfigPath("testapp", configDir)
+	defer func() {
+		if err := Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+		tracerProvider = nil
+	}()
+
+	if tracerProvider == nil {
+		t.Fatal("Expected tracer provider to be initialized")
+	}
+	if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); !ok {
+		t.Errorf("Expected global tracer provider to be the SDK provider, got %T", otel.GetTracerProvider())
+	}
+	fields := otel.GetTextMapPropagator().Fields()
+	if !slices.Contains(fields, "traceparent") {
+		t.Errorf("Expected W3C trace context propagator, got fields %v", fields)
+	}
+}
+
+func TestInitTracing_Disabled(t *testing.T) {
+	configDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(configDir, "default.yaml"), []byte(`log: {}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	InitWithConfigPath("testapp", configDir)
+
+	if tracerProvider != nil {
+		t.Error("Expected tracing to stay disabled without otel.exporter")
+	}
+	if err := Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
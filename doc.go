@@ -11,6 +11,7 @@
 //   - redis_client: Redis client with caching support and cluster mode
 //   - object_storage: Multi-provider object storage interface (local, MinIO, Volcengine TOS)
 //   - grpc_utils: gRPC middleware and interceptors for logging and request ID tracking
+//   - http_utils: net/http middleware for logging, request ID tracking, CORS and metrics
 //   - smtp_mailer: SMTP email sender with TLS support
 //
 // # Installation
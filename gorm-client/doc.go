@@ -90,6 +90,18 @@
 //
 // For more GORM features, see https://gorm.io/docs/
 //
+// # Multi-Tenancy
+//
+// TenantScope restricts a statement to the rows of the tenant stored in the
+// context with app.WithTenant, for example by the grpc_utils tenant
+// interceptor. Statements fail with app.ErrNoTenant when the context has no
+// tenant:
+//
+//	var orders []Order
+//	err := db.WithContext(ctx).Scopes(gorm_client.TenantScope(ctx)).Find(&orders).Error
+//
+// Use TenantScopeColumn when the tenant column is not named tenant_id.
+//
 // # Error Handling
 //
 // NewDB panics if:
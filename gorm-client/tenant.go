@@ -0,0 +1,40 @@
+package gorm_client
+
+import (
+	"context"
+
+	"github.com/poly-workshop/go-webmods/app"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultTenantColumn is the column holding the tenant ID used by TenantScope.
+const DefaultTenantColumn = "tenant_id"
+
+// TenantScope returns a scope restricting queries, updates and deletes to
+// rows whose tenant_id column matches the tenant stored in ctx with
+// app.WithTenant. The statement fails with app.ErrNoTenant if ctx carries no
+// tenant, so a missing tenant never exposes every tenant's rows.
+//
+// Example:
+//
+//	var orders []Order
+//	err := db.WithContext(ctx).Scopes(gorm_client.TenantScope(ctx)).Find(&orders).Error
+func TenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return TenantScopeColumn(ctx, DefaultTenantColumn)
+}
+
+// TenantScopeColumn is like TenantScope with a custom tenant column.
+func TenantScopeColumn(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		tenant, ok := app.TenantFromContext(ctx)
+		if !ok {
+			_ = db.AddError(app.ErrNoTenant)
+			return db
+		}
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: column},
+			Value:  tenant,
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package gorm_client
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/poly-workshop/go-webmods/app"
+)
+
+type tenantOrder struct {
+	ID       uint
+	TenantID string
+	Item     string
+}
+
+func TestTenantScope(t *testing.T) {
+	db := NewDB(Config{
+		Driver: "sqlite",
+		Name:   filepath.Join(t.TempDir(), "tenant.db"),
+	})
+	if err := db.AutoMigrate(&tenantOrder{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	orders := []tenantOrder{
+		{TenantID: "acme", Item: "anvil"},
+		{TenantID: "acme", Item: "rocket"},
+		{TenantID: "globex", Item: "laser"},
+	}
+	if err := db.Create(&orders).Error; err != nil {
+		t.Fatalf("Failed to create orders: %v", err)
+	}
+
+	ctx := app.WithTenant(context.Background(), "acme")
+	var found []tenantOrder
+	if err := db.WithContext(ctx).Scopes(TenantScope(ctx)).Find(&found).Error; err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("Expected 2 orders of tenant acme, got %v", found)
+	}
+
+	result := db.WithContext(ctx).Scopes(TenantScope(ctx)).Where("item = ?", "laser").Delete(&tenantOrder{})
+	if result.Error != nil || result.RowsAffected != 0 {
+		t.Errorf("Expected delete of another tenant's row to match nothing, got %d rows, %v",
+			result.RowsAffected, result.Error)
+	}
+
+	err := db.Scopes(TenantScope(context.Background())).Find(&found).Error
+	if !errors.Is(err, app.ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant without tenant, got %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package grpc_utils
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/poly-workshop/go-webmods/app"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultAuditSummarySize  = 1024
+	defaultAuditBufferSize   = 1000
+	defaultAuditBatchSize    = 100
+	defaultAuditFlushPeriod  = time.Second
+	defaultAuditWriteTimeout = 5 * time.Second
+)
+
+// AuditRecord describes one audited call.
+type AuditRecord struct {
+	ID uint64 `json:"-" bson:"-" gorm:"primaryKey"`
+	// Time is when the call started.
+	Time time.Time `json:"time" bson:"time" gorm:"index"`
+	// Actor is the subject of the authenticated caller, empty for anonymous
+	// calls.
+	Actor string `json:"actor" bson:"actor" gorm:"index"`
+	// Tenant is the tenant stored with app.WithTenant, if any.
+	Tenant string `json:"tenant" bson:"tenant" gorm:"index"`
+	// Method is the full method name.
+	Method string `json:"method" bson:"method"`
+	// RequestID is the request ID of the call, if any.
+	RequestID string `json:"request_id" bson:"request_id"`
+	// Code is the name of the status code the call ended with.
+	Code string `json:"code" bson:"code"`
+	// Latency is how long the call took.
+	Latency time.Duration `json:"latency" bson:"latency"`
+	// Request is the request rendered as JSON with sensitive fields
+	// redacted, empty for streaming calls.
+	Request string `json:"request,omitempty" bson:"request,omitempty"`
+}
+
+// AuditSink persists audit records.
+type AuditSink interface {
+	// Write stores a batch of records.
+	Write(ctx context.Context, records []AuditRecord) error
+}
+
+// AuditConfig holds configuration for the audit interceptor.
+//
+// Example config:
+//
+//	grpc:
+//	  audit:
+//	    methods:
+//	      - /orders.v1.OrderService/Create*
+//	      - /orders.v1.OrderService/Delete*
+//	    redact_fields: [password, card_number]
+//	    summary_size: 512
+type AuditConfig struct {
+	// Methods lists the full method name patterns of audited calls, see
+	// MatchMethod. Other calls are not audited.
+	Methods []string `mapstructure:"methods"`
+	// RedactFields lists proto field names redacted from the request
+	// summary at any depth. Fields with the debug_redact option are always
+	// redacted.
+	RedactFields []string `mapstructure:"redact_fields"`
+	// SummarySize truncates request summaries to this many bytes.
+	// Optional. Defaults to 1024.
+	SummarySize int `mapstructure:"summary_size"`
+	// BufferSize is the number of records queued for the sink. Records are
+	// dropped while the buffer is full. Optional. Defaults to 1000.
+	BufferSize int `mapstructure:"buffer_size"`
+	// BatchSize is the maximum number of records written at once.
+	// Optional. Defaults to 100.
+	BatchSize int `mapstructure:"batch_size"`
+	// FlushInterval is the maximum time a record waits for its batch.
+	// Optional. Defaults to 1s.
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// WriteTimeout bounds each write to the sink. Optional. Defaults to 5s.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// Metrics configures the dropped record metric.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	// Sink stores the records. Required.
+	Sink AuditSink `mapstructure:"-"`
+	// Logger logs dropped records and sink failures. Optional. Defaults to
+	// slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+}
+
+// Auditor records audited calls to an AuditSink. Records are queued and
+// written in batches by a background goroutine, so audit writes do not delay
+// responses.
+type Auditor struct {
+	cfg     AuditConfig
+	payload *payloadLogger
+	dropped prometheus.Counter
+
+	mu      sync.RWMutex
+	closed  bool
+	records chan AuditRecord
+	done    chan struct{}
+}
+
+// NewAuditor creates an audit interceptor provider and starts writing
+// records to the sink. Call Close on shutdown to flush queued records.
+//
+// Example:
+//
+//	sink := grpc_utils.NewGormAuditSink(db, "")
+//	if err := sink.AutoMigrate(); err != nil {
+//	    panic(err)
+//	}
+//	auditor, err := grpc_utils.NewAuditor(grpc_utils.AuditConfig{
+//	    Methods: []string{"/orders.v1.OrderService/Create*"},
+//	    Sink:    sink,
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = auditor.Close(context.Background()) }()
+func NewAuditor(cfg AuditConfig) (*Auditor, error) {
+	if cfg.Sink == nil {
+		return nil, errors.New("grpc_utils: audit requires a sink")
+	}
+	if cfg.SummarySize <= 0 {
+		cfg.SummarySize = defaultAuditSummarySize
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultAuditBufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultAuditBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultAuditFlushPeriod
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultAuditWriteTimeout
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	if cfg.Metrics.Registry != nil {
+		registerer = cfg.Metrics.Registry
+	}
+	a := &Auditor{
+		cfg: cfg,
+		payload: newPayloadLogger(PayloadLogConfig{
+			MaxSize:      cfg.SummarySize,
+			RedactFields: cfg.RedactFields,
+		}),
+		dropped: registerCollector(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: cfg.Metrics.Namespace,
+			Subsystem: "grpc_server",
+			Name:      "audit_records_dropped_total",
+			Help:      "Total number of audit records dropped because the buffer was full.",
+		})),
+		records: make(chan AuditRecord, cfg.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+// Close stops accepting records and waits until the queued records are
+// written or ctx is done.
+func (a *Auditor) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.records)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// record queues r for the sink without blocking.
+func (a *Auditor) record(ctx context.Context, r AuditRecord) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.records <- r:
+	default:
+		a.dropped.Inc()
+		a.cfg.Logger.WarnContext(ctx, "audit record dropped", "method", r.Method)
+	}
+}
+
+func (a *Auditor) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AuditRecord, 0, a.cfg.BatchSize)
+	for {
+		select {
+		case r, ok := <-a.records:
+			if !ok {
+				a.write(batch)
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) >= a.cfg.BatchSize {
+				a.write(batch)
+				batch = make([]AuditRecord, 0, a.cfg.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				a.write(batch)
+				batch = make([]AuditRecord, 0, a.cfg.BatchSize)
+			}
+		}
+	}
+}
+
+func (a *Auditor) write(batch []AuditRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.WriteTimeout)
+	defer cancel()
+	if err := a.cfg.Sink.Write(ctx, batch); err != nil {
+		a.cfg.Logger.Error("failed to write audit records", "count", len(batch), "error", err)
+	}
+}
+
+// newRecord returns the record of a call to fullMethod started at start.
+func (a *Auditor) newRecord(ctx context.Context, fullMethod string, start time.Time, err error) AuditRecord {
+	tenant, _ := app.TenantFromContext(ctx)
+	requestID, _ := RequestIDFromContext(ctx)
+	return AuditRecord{
+		Time:      start,
+		Actor:     userFromContext(ctx),
+		Tenant:    tenant,
+		Method:    fullMethod,
+		RequestID: requestID,
+		Code:      status.Code(err).String(),
+		Latency:   time.Since(start),
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor that records the configured
+// unary calls, including a redacted summary of the request.
+//
+// It must run after the authentication, tenant and request ID interceptors
+// to capture the actor, tenant and request ID.
+func (a *Auditor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if !matchAnyMethod(a.cfg.Methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		r := a.newRecord(ctx, info.FullMethod, start, err)
+		r.Request, _ = a.payload.summary(req)
+		a.record(ctx, r)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that records the configured
+// streaming calls. Stream messages are not summarized.
+func (a *Auditor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if !matchAnyMethod(a.cfg.Methods, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		err := handler(srv, ss)
+		a.record(ss.Context(), a.newRecord(ss.Context(), info.FullMethod, start, err))
+		return err
+	}
+}
@@ -0,0 +1,235 @@
+package grpc_utils_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/poly-workshop/go-webmods/app"
+	gorm_client "github.com/poly-workshop/go-webmods/gorm-client"
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// memoryAuditSink collects written records, optionally blocking writes until
+// release is closed.
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	records []grpc_utils.AuditRecord
+	release chan struct{}
+}
+
+func (s *memoryAuditSink) Write(ctx context.Context, records []grpc_utils.AuditRecord) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memoryAuditSink) Records() []grpc_utils.AuditRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]grpc_utils.AuditRecord(nil), s.records...)
+}
+
+func newTestAuditor(t *testing.T, sink grpc_utils.AuditSink, cfg grpc_utils.AuditConfig) *grpc_utils.Auditor {
+	t.Helper()
+	cfg.Sink = sink
+	cfg.Metrics = grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()}
+	cfg.Logger = discardLogger
+	auditor, err := grpc_utils.NewAuditor(cfg)
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+	return auditor
+}
+
+func TestNewAuditor_RequiresSink(t *testing.T) {
+	if _, err := grpc_utils.NewAuditor(grpc_utils.AuditConfig{}); err == nil {
+		t.Error("Expected error without sink")
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	sink := &memoryAuditSink{}
+	auditor := newTestAuditor(t, sink, grpc_utils.AuditConfig{
+		Methods:      []string{"/orders.v1.OrderService/Create*"},
+		RedactFields: []string{"service"},
+	})
+	audit := auditor.UnaryServerInterceptor()
+	requestID := grpc_utils.BuildRequestIDInterceptor()
+
+	call := func(method string, err error) {
+		claims := &grpc_utils.Claims{}
+		claims.Subject = "user-1"
+		ctx := grpc_utils.ContextWithClaims(context.Background(), claims)
+		ctx = app.WithTenant(ctx, "acme")
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-request-id", "req-1"))
+		ctx = grpc.NewContextWithServerTransportStream(ctx, &fakeServerStream{method: method})
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		handler := func(ctx context.Context, req any) (any, error) {
+			time.Sleep(time.Millisecond)
+			return &healthpb.HealthCheckResponse{}, err
+		}
+		_, _ = requestID(ctx, &healthpb.HealthCheckRequest{Service: "secret"}, info,
+			func(ctx context.Context, req any) (any, error) {
+				return audit(ctx, req, info, handler)
+			})
+	}
+	call("/orders.v1.OrderService/CreateOrder", nil)
+	call("/orders.v1.OrderService/CreateOrder", status.Error(codes.FailedPrecondition, "out of stock"))
+	call("/orders.v1.OrderService/GetOrder", nil)
+
+	if err := auditor.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	records := sink.Records()
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %+v", records)
+	}
+	r := records[0]
+	if r.Actor != "user-1" || r.Tenant != "acme" || r.RequestID != "req-1" ||
+		r.Method != "/orders.v1.OrderService/CreateOrder" || r.Code != "OK" {
+		t.Errorf("Unexpected audit record %+v", r)
+	}
+	if r.Latency < time.Millisecond || r.Time.IsZero() {
+		t.Errorf("Expected time and latency to be recorded, got %v and %v", r.Time, r.Latency)
+	}
+	if !strings.Contains(r.Request, "[REDACTED]") || strings.Contains(r.Request, "secret") {
+		t.Errorf("Expected redacted request summary, got %q", r.Request)
+	}
+	if records[1].Code != "FailedPrecondition" {
+		t.Errorf("Expected FailedPrecondition code, got %q", records[1].Code)
+	}
+}
+
+func TestAuditor_Stream(t *testing.T) {
+	sink := &memoryAuditSink{}
+	auditor := newTestAuditor(t, sink, grpc_utils.AuditConfig{
+		Methods: []string{"/orders.v1.OrderService/Import*"},
+	})
+	ctx := app.WithTenant(context.Background(), "acme")
+	info := &grpc.StreamServerInfo{FullMethod: "/orders.v1.OrderService/ImportOrders", IsClientStream: true}
+	err := auditor.StreamServerInterceptor()(nil, &ctxServerStream{ctx: ctx}, info,
+		func(srv any, ss grpc.ServerStream) error { return status.Error(codes.Canceled, "canceled") })
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("Expected handler error to be returned, got %v", err)
+	}
+
+	if err := auditor.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	records := sink.Records()
+	if len(records) != 1 || records[0].Tenant != "acme" || records[0].Code != "Canceled" || records[0].Request != "" {
+		t.Errorf("Unexpected stream audit records %+v", records)
+	}
+}
+
+func TestAuditor_DropsWhenFull(t *testing.T) {
+	sink := &memoryAuditSink{release: make(chan struct{})}
+	registry := prometheus.NewRegistry()
+	auditor, err := grpc_utils.NewAuditor(grpc_utils.AuditConfig{
+		Methods:    []string{"/orders.v1.OrderService/*"},
+		BufferSize: 1,
+		BatchSize:  1,
+		Metrics:    grpc_utils.MetricsConfig{Registry: registry},
+		Sink:       sink,
+		Logger:     discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewAuditor failed: %v", err)
+	}
+	audit := auditor.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/CreateOrder"}
+	handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+
+	// The first record blocks the sink, the second fills the buffer, and
+	// the rest are dropped without delaying the calls.
+	start := time.Now()
+	for range 5 {
+		if _, err := audit(context.Background(), nil, info, handler); err != nil {
+			t.Fatalf("interceptor returned error: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected calls not to wait for the sink, took %v", elapsed)
+	}
+
+	expected := `
+# HELP grpc_server_audit_records_dropped_total Total number of audit records dropped because the buffer was full.
+# TYPE grpc_server_audit_records_dropped_total counter
+grpc_server_audit_records_dropped_total 3
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	close(sink.release)
+	if err := auditor.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if records := sink.Records(); len(records) != 2 {
+		t.Errorf("Expected 2 records written, got %d", len(records))
+	}
+}
+
+func TestGormAuditSink(t *testing.T) {
+	db := gorm_client.NewDB(gorm_client.Config{
+		Driver: "sqlite",
+		Name:   filepath.Join(t.TempDir(), "audit.db"),
+	})
+	sink := grpc_utils.NewGormAuditSink(db, "")
+	if err := sink.AutoMigrate(); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	records := []grpc_utils.AuditRecord{
+		{Time: time.Now(), Actor: "user-1", Method: "/orders.v1.OrderService/CreateOrder", Code: "OK"},
+		{Time: time.Now(), Actor: "user-2", Method: "/orders.v1.OrderService/DeleteOrder", Code: "NotFound"},
+	}
+	if err := sink.Write(context.Background(), records); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var stored []grpc_utils.AuditRecord
+	if err := db.Table(grpc_utils.DefaultAuditTable).Order("id").Find(&stored).Error; err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(stored) != 2 || stored[1].Actor != "user-2" || stored[1].Code != "NotFound" {
+		t.Errorf("Unexpected stored records %+v", stored)
+	}
+}
+
+func TestLogAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := grpc_utils.NewLogAuditSink(slog.New(slog.NewJSONHandler(&buf, nil)))
+	err := sink.Write(context.Background(), []grpc_utils.AuditRecord{
+		{Actor: "user-1", Method: "/orders.v1.OrderService/CreateOrder", Code: "OK"},
+	})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log output %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "audit" || entry["actor"] != "user-1" || entry["code"] != "OK" {
+		t.Errorf("Unexpected audit log entry %v", entry)
+	}
+}
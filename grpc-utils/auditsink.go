@@ -0,0 +1,86 @@
+package grpc_utils
+
+import (
+	"context"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"gorm.io/gorm"
+)
+
+// DefaultAuditTable is the table used by GormAuditSink when no other table is
+// configured.
+const DefaultAuditTable = "audit_records"
+
+// GormAuditSink writes audit records to a database table.
+type GormAuditSink struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewGormAuditSink returns a sink writing to table, or "audit_records" if
+// table is empty. db is typically created with gorm_client.NewDB.
+func NewGormAuditSink(db *gorm.DB, table string) *GormAuditSink {
+	if table == "" {
+		table = DefaultAuditTable
+	}
+	return &GormAuditSink{db: db, table: table}
+}
+
+// AutoMigrate creates or updates the audit table.
+func (s *GormAuditSink) AutoMigrate() error {
+	return s.db.Table(s.table).AutoMigrate(&AuditRecord{})
+}
+
+// Write inserts records into the audit table.
+func (s *GormAuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	return s.db.WithContext(ctx).Table(s.table).Create(&records).Error
+}
+
+// MongoAuditSink writes audit records to a MongoDB collection.
+type MongoAuditSink struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAuditSink returns a sink writing to collection, typically obtained
+// from a client created with mongo_client.NewClient.
+func NewMongoAuditSink(collection *mongo.Collection) *MongoAuditSink {
+	return &MongoAuditSink{collection: collection}
+}
+
+// Write inserts records into the audit collection.
+func (s *MongoAuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	_, err := s.collection.InsertMany(ctx, records)
+	return err
+}
+
+// LogAuditSink writes audit records as "audit" log entries.
+type LogAuditSink struct {
+	logger *slog.Logger
+}
+
+// NewLogAuditSink returns a sink logging with logger, or slog.Default() if
+// logger is nil.
+func NewLogAuditSink(logger *slog.Logger) *LogAuditSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogAuditSink{logger: logger}
+}
+
+// Write logs each record at info level.
+func (s *LogAuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	for _, r := range records {
+		s.logger.LogAttrs(ctx, slog.LevelInfo, "audit",
+			slog.Time("time", r.Time),
+			slog.String("actor", r.Actor),
+			slog.String("tenant", r.Tenant),
+			slog.String("method", r.Method),
+			slog.String("request_id", r.RequestID),
+			slog.String("code", r.Code),
+			slog.Duration("latency", r.Latency),
+			slog.String("request", r.Request),
+		)
+	}
+	return nil
+}
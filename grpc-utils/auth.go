@@ -0,0 +1,316 @@
+package grpc_utils
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"github.com/poly-workshop/go-webmods/app"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const claimsKey contextKey = "auth_claims"
+
+var defaultAuthAlgorithms = []string{"HS256", "RS256", "ES256"}
+
+// AuthConfig holds configuration for JWT authentication.
+//
+// At least one key source must be configured: HMACSecret for HS256 tokens,
+// PublicKeyFile for RS256/ES256 tokens signed by a single key, or a JWKS
+// document loaded from JWKSFile or JWKSURL.
+//
+// The config can be loaded from an app config section:
+//
+//	var cfg grpc_utils.AuthConfig
+//	if err := app.Config().UnmarshalKey("grpc.auth", &cfg); err != nil {
+//	    panic(err)
+//	}
+type AuthConfig struct {
+	// HMACSecret is the shared secret used to verify HMAC signed tokens.
+	HMACSecret string `mapstructure:"hmac_secret"`
+	// PublicKeyFile is the path to a PEM encoded RSA or ECDSA public key or
+	// certificate used to verify asymmetrically signed tokens.
+	PublicKeyFile string `mapstructure:"public_key_file"`
+	// JWKSFile is the path to a JWKS document.
+	JWKSFile string `mapstructure:"jwks_file"`
+	// JWKSURL is the URL of a JWKS document, e.g.
+	// "https://issuer.example.com/.well-known/jwks.json".
+	JWKSURL string `mapstructure:"jwks_url"`
+	// JWKSCacheTTL is how long a loaded JWKS document is used before it is
+	// reloaded. Optional. Defaults to 10 minutes.
+	JWKSCacheTTL time.Duration `mapstructure:"jwks_cache_ttl"`
+
+	// Issuer is the required "iss" claim. Optional.
+	Issuer string `mapstructure:"issuer"`
+	// Audience lists the accepted "aud" values; a token must contain at least
+	// one of them. Optional.
+	Audience []string `mapstructure:"audience"`
+	// Algorithms lists the accepted signing algorithms.
+	// Optional. Defaults to HS256, RS256 and ES256.
+	Algorithms []string `mapstructure:"algorithms"`
+	// Leeway is the allowed clock skew when validating time based claims.
+	Leeway time.Duration `mapstructure:"leeway"`
+
+	// PublicMethods lists full method names that do not require a token.
+	// Wildcards are supported, see MatchMethod. If a token is sent to a public
+	// method anyway it is still validated.
+	PublicMethods []string `mapstructure:"public_methods"`
+}
+
+// Claims holds the validated claims of a JWT.
+type Claims struct {
+	jwt.RegisteredClaims
+	// Roles lists the roles granted to the subject.
+	Roles []string `json:"roles,omitempty"`
+	// Scope is the space separated list of OAuth 2.0 scopes.
+	Scope string `json:"scope,omitempty"`
+	// Raw holds every claim of the token, including custom claims.
+	Raw map[string]any `json:"-"`
+}
+
+// UnmarshalJSON decodes the registered and well-known claims and keeps all
+// claims in Raw.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	type claims Claims
+	if err := json.Unmarshal(data, (*claims)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.Raw)
+}
+
+// Scopes returns the granted scopes from the "scope" claim, or from the
+// "scp" claim if the token uses the array form.
+func (c *Claims) Scopes() []string {
+	if c.Scope != "" {
+		return strings.Fields(c.Scope)
+	}
+	var scopes []string
+	switch scp := c.Raw["scp"].(type) {
+	case string:
+		scopes = strings.Fields(scp)
+	case []any:
+		for _, s := range scp {
+			if s, ok := s.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
+
+// HasRole reports whether the claims grant the given role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasScope reports whether the claims grant the given scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// ContextWithClaims returns a copy of ctx carrying the given claims.
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the claims stored in the context by the
+// authentication interceptor.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// Authenticator validates bearer JWTs sent in the authorization metadata.
+type Authenticator struct {
+	parser        *jwt.Parser
+	hmacSecret    []byte
+	publicKey     any
+	jwks          *jwksKeySet
+	publicMethods []string
+}
+
+// NewAuthenticator creates a new authenticator with the provided configuration.
+//
+// Example:
+//
+//	authenticator, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{
+//	    JWKSURL:       "https://issuer.example.com/.well-known/jwks.json",
+//	    Issuer:        "https://issuer.example.com/",
+//	    Audience:      []string{"my-service"},
+//	    PublicMethods: []string{"/grpc.health.v1.Health/*"},
+//	})
+func NewAuthenticator(cfg AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		hmacSecret:    []byte(cfg.HMACSecret),
+		publicMethods: cfg.PublicMethods,
+	}
+
+	if cfg.PublicKeyFile != "" {
+		key, err := loadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		a.publicKey = key
+	}
+
+	switch {
+	case cfg.JWKSFile != "":
+		a.jwks = newFileJWKS(cfg.JWKSFile, cfg.JWKSCacheTTL)
+		// A broken local file is a configuration error, fail fast
+		if err := a.jwks.refresh(context.Background()); err != nil {
+			return nil, err
+		}
+	case cfg.JWKSURL != "":
+		a.jwks = newURLJWKS(cfg.JWKSURL, cfg.JWKSCacheTTL, nil)
+	}
+
+	if len(a.hmacSecret) == 0 && a.publicKey == nil && a.jwks == nil {
+		return nil, errors.New("grpc_utils: no key source configured for authentication")
+	}
+
+	algorithms := cfg.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultAuthAlgorithms
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if len(cfg.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(cfg.Audience...))
+	}
+	a.parser = jwt.NewParser(opts...)
+
+	return a, nil
+}
+
+// Authenticate validates the token and returns its claims.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return a.key(ctx, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *Authenticator) key(ctx context.Context, t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(a.hmacSecret) > 0 {
+			return a.hmacSecret, nil
+		}
+	} else if a.publicKey != nil && (kid == "" || a.jwks == nil) {
+		return a.publicKey, nil
+	}
+
+	if a.jwks != nil {
+		return a.jwks.Key(ctx, kid)
+	}
+	return nil, errKeyNotFound
+}
+
+// authenticate extracts and validates the bearer token of an incoming call.
+func (a *Authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	public := matchAnyMethod(a.publicMethods, fullMethod)
+
+	token, err := auth.AuthFromMD(ctx, "bearer")
+	if err != nil {
+		if public {
+			return ctx, nil
+		}
+		return nil, err
+	}
+
+	claims, err := a.Authenticate(ctx, token)
+	if err != nil {
+		slog.DebugContext(ctx, "token validation failed", "method", fullMethod, "error", err)
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	ctx = ContextWithClaims(ctx, claims)
+	if claims.Subject != "" {
+		ctx = app.WithLogAttrs(ctx, slog.String("user_id", claims.Subject))
+	}
+	return ctx, nil
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that authenticates unary
+// calls and stores the token claims in the context.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that authenticates
+// streaming calls and stores the token claims in the stream context.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+func loadPublicKey(file string) (any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("grpc_utils: no PEM data found in %s", file)
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
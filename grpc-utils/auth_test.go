@@ -0,0 +1,326 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testIssuer = "https://issuer.example.com/"
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   b64(key.N.Bytes()),
+		"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   b64(key.X.FillBytes(make([]byte, 32))),
+		"y":   b64(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "user-1",
+		"iss":   testIssuer,
+		"aud":   "my-service",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"admin"},
+		"scope": "read write",
+		"org":   "acme",
+	}
+}
+
+func callWithToken(
+	interceptor grpc.UnaryServerInterceptor,
+	method string,
+	token string,
+) (*grpc_utils.Claims, error) {
+	ctx := context.Background()
+	if token != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+	}
+	var claims *grpc_utils.Claims
+	handler := func(ctx context.Context, req any) (any, error) {
+		claims, _ = grpc_utils.ClaimsFromContext(ctx)
+		return nil, nil
+	}
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	return claims, err
+}
+
+func TestAuthenticator_HMAC(t *testing.T) {
+	secret := []byte("test-secret")
+	authenticator, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{
+		HMACSecret:    string(secret),
+		Issuer:        testIssuer,
+		Audience:      []string{"my-service"},
+		PublicMethods: []string{"/grpc.health.v1.Health/*"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	interceptor := authenticator.UnaryServerInterceptor()
+
+	t.Run("ValidToken", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, "", secret, validClaims())
+		claims, err := callWithToken(interceptor, "/test.Service/Method", token)
+		if err != nil {
+			t.Fatalf("Expected token to be accepted, got %v", err)
+		}
+		if claims == nil || claims.Subject != "user-1" {
+			t.Fatalf("Expected claims for user-1 in context, got %+v", claims)
+		}
+		if !claims.HasRole("admin") || !claims.HasScope("write") {
+			t.Errorf("Expected admin role and write scope, got %v / %v", claims.Roles, claims.Scopes())
+		}
+		if claims.Raw["org"] != "acme" {
+			t.Errorf("Expected custom claim org=acme, got %v", claims.Raw["org"])
+		}
+	})
+
+	rejected := map[string]jwt.MapClaims{
+		"Expired": func() jwt.MapClaims {
+			c := validClaims()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+			return c
+		}(),
+		"MissingExpiry": func() jwt.MapClaims {
+			c := validClaims()
+			delete(c, "exp")
+			return c
+		}(),
+		"WrongIssuer": func() jwt.MapClaims {
+			c := validClaims()
+			c["iss"] = "https://evil.example.com/"
+			return c
+		}(),
+		"WrongAudience": func() jwt.MapClaims {
+			c := validClaims()
+			c["aud"] = "other-service"
+			return c
+		}(),
+	}
+	for name, claims := range rejected {
+		t.Run(name, func(t *testing.T) {
+			token := signToken(t, jwt.SigningMethodHS256, "", secret, claims)
+			_, err := callWithToken(interceptor, "/test.Service/Method", token)
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("Expected Unauthenticated, got %v", err)
+			}
+		})
+	}
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, "", []byte("other"), validClaims())
+		_, err := callWithToken(interceptor, "/test.Service/Method", token)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected Unauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		_, err := callWithToken(interceptor, "/test.Service/Method", "")
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected Unauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("PublicMethod", func(t *testing.T) {
+		claims, err := callWithToken(interceptor, "/grpc.health.v1.Health/Check", "")
+		if err != nil {
+			t.Fatalf("Expected public method to be allowed, got %v", err)
+		}
+		if claims != nil {
+			t.Errorf("Expected no claims for anonymous call, got %+v", claims)
+		}
+	})
+}
+
+func TestAuthenticator_JWKSFile(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+
+	jwks, _ := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			rsaJWK("rsa-1", &rsaKey.PublicKey),
+			ecJWK("ec-1", ecKey),
+		},
+	})
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, jwks, 0o644); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+
+	authenticator, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{
+		JWKSFile: file,
+		Issuer:   testIssuer,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	interceptor := authenticator.UnaryServerInterceptor()
+
+	t.Run("RS256", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims())
+		if _, err := callWithToken(interceptor, "/test.Service/Method", token); err != nil {
+			t.Errorf("Expected RS256 token to be accepted, got %v", err)
+		}
+	})
+
+	t.Run("ES256", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims())
+		if _, err := callWithToken(interceptor, "/test.Service/Method", token); err != nil {
+			t.Errorf("Expected ES256 token to be accepted, got %v", err)
+		}
+	})
+
+	t.Run("UnknownKeyID", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims())
+		_, err := callWithToken(interceptor, "/test.Service/Method", token)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected Unauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("HS256NotAllowedWithoutSecret", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, "", []byte("secret"), validClaims())
+		_, err := callWithToken(interceptor, "/test.Service/Method", token)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected Unauthenticated, got %v", err)
+		}
+	})
+}
+
+func TestAuthenticator_JWKSURL(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{ecJWK("ec-1", ecKey)},
+		})
+	}))
+	defer server.Close()
+
+	authenticator, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{
+		JWKSURL: server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	interceptor := authenticator.UnaryServerInterceptor()
+
+	token := signToken(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims())
+	for range 3 {
+		if _, err := callWithToken(interceptor, "/test.Service/Method", token); err != nil {
+			t.Fatalf("Expected token to be accepted, got %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected JWKS to be fetched once and cached, got %d requests", requests)
+	}
+}
+
+func TestAuthenticator_JWKSSkipsUnsupportedKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	okpJWK := map[string]string{
+		"kty": "OKP",
+		"kid": "ed-1",
+		"crv": "Ed25519",
+		"x":   b64(make([]byte, 32)),
+	}
+
+	writeJWKS := func(t *testing.T, keys ...map[string]string) string {
+		jwks, _ := json.Marshal(map[string]any{"keys": keys})
+		file := filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(file, jwks, 0o644); err != nil {
+			t.Fatalf("Failed to write JWKS: %v", err)
+		}
+		return file
+	}
+	token := signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims())
+
+	t.Run("MixedKeySet", func(t *testing.T) {
+		authenticator, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{
+			JWKSFile: writeJWKS(t, okpJWK, rsaJWK("rsa-1", &rsaKey.PublicKey)),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create authenticator: %v", err)
+		}
+		if _, err := callWithToken(authenticator.UnaryServerInterceptor(), "/test.Service/Method", token); err != nil {
+			t.Errorf("Expected RS256 token to be accepted, got %v", err)
+		}
+	})
+
+	t.Run("NoUsableKey", func(t *testing.T) {
+		_, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{
+			JWKSFile: writeJWKS(t, okpJWK),
+		})
+		if err == nil {
+			t.Error("Expected error for a JWKS without usable keys")
+		}
+	})
+}
+
+func TestNewAuthenticator_NoKeySource(t *testing.T) {
+	if _, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{}); err == nil {
+		t.Error("Expected error when no key source is configured")
+	}
+}
@@ -0,0 +1,147 @@
+package grpc_utils
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuthzRule maps a method pattern to the roles and scopes required to call it.
+type AuthzRule struct {
+	// Method is a full method name pattern, see MatchMethod.
+	Method string `mapstructure:"method"`
+	// Roles lists the accepted roles; the caller needs at least one of them.
+	Roles []string `mapstructure:"roles"`
+	// Scopes lists the required scopes; the caller needs all of them.
+	Scopes []string `mapstructure:"scopes"`
+	// Public allows the method to be called without claims.
+	Public bool `mapstructure:"public"`
+}
+
+// AuthzConfig holds configuration for method-level authorization.
+//
+// Example config:
+//
+//	grpc:
+//	  authz:
+//	    default_deny: true
+//	    rules:
+//	      - method: /grpc.health.v1.Health/*
+//	        public: true
+//	      - method: /admin.v1.AdminService/*
+//	        roles: [admin]
+//	      - method: /orders.v1.OrderService/Create*
+//	        roles: [admin, sales]
+//	        scopes: [orders:write]
+type AuthzConfig struct {
+	// Rules are evaluated in order and the first rule matching the method
+	// applies.
+	Rules []AuthzRule `mapstructure:"rules"`
+	// DefaultDeny rejects methods that match no rule. Otherwise such methods
+	// only require the caller to be authenticated.
+	DefaultDeny bool `mapstructure:"default_deny"`
+}
+
+// Creates a gRPC interceptor that authorizes unary calls against the claims
+// stored in the context by the authentication interceptor.
+func BuildAuthzInterceptor(cfg AuthzConfig) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := authorize(ctx, cfg, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Creates a gRPC interceptor that authorizes streaming calls against the
+// claims stored in the context by the authentication interceptor.
+func BuildAuthzStreamInterceptor(cfg AuthzConfig) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := authorize(ss.Context(), cfg, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authorize(ctx context.Context, cfg AuthzConfig, fullMethod string) error {
+	var rule *AuthzRule
+	for i := range cfg.Rules {
+		if MatchMethod(cfg.Rules[i].Method, fullMethod) {
+			rule = &cfg.Rules[i]
+			break
+		}
+	}
+	if rule != nil && rule.Public {
+		return nil
+	}
+
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	switch {
+	case rule == nil && cfg.DefaultDeny:
+		auditDenied(ctx, fullMethod, claims, "no matching rule")
+	case rule == nil:
+		return nil
+	case len(rule.Roles) > 0 && !hasAnyRole(claims, rule.Roles):
+		auditDenied(ctx, fullMethod, claims, "missing role",
+			slog.Any("required_roles", rule.Roles))
+	case !hasAllScopes(claims, rule.Scopes):
+		auditDenied(ctx, fullMethod, claims, "missing scope",
+			slog.Any("required_scopes", rule.Scopes))
+	default:
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "permission denied")
+}
+
+func auditDenied(
+	ctx context.Context,
+	fullMethod string,
+	claims *Claims,
+	reason string,
+	attrs ...slog.Attr,
+) {
+	attrs = append([]slog.Attr{
+		slog.String("method", fullMethod),
+		slog.String("subject", claims.Subject),
+		slog.Any("roles", claims.Roles),
+		slog.Any("scopes", claims.Scopes()),
+		slog.String("reason", reason),
+	}, attrs...)
+	slog.LogAttrs(ctx, slog.LevelWarn, "authorization denied", attrs...)
+}
+
+func hasAnyRole(claims *Claims, roles []string) bool {
+	for _, role := range roles {
+		if claims.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAllScopes(claims *Claims, scopes []string) bool {
+	for _, scope := range scopes {
+		if !claims.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
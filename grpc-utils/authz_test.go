@@ -0,0 +1,107 @@
+package grpc_utils_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthzInterceptor(t *testing.T) {
+	interceptor := grpc_utils.BuildAuthzInterceptor(grpc_utils.AuthzConfig{
+		DefaultDeny: true,
+		Rules: []grpc_utils.AuthzRule{
+			{Method: "/grpc.health.v1.Health/*", Public: true},
+			{Method: "/admin.v1.AdminService/*", Roles: []string{"admin"}},
+			{
+				Method: "/orders.v1.OrderService/Create*",
+				Roles:  []string{"admin", "sales"},
+				Scopes: []string{"orders:write"},
+			},
+			{Method: "/orders.v1.OrderService/*"},
+		},
+	})
+
+	admin := &grpc_utils.Claims{Roles: []string{"admin"}, Scope: "orders:write"}
+	sales := &grpc_utils.Claims{Roles: []string{"sales"}, Scope: "orders:read"}
+	viewer := &grpc_utils.Claims{Roles: []string{"viewer"}}
+
+	tests := []struct {
+		name     string
+		method   string
+		claims   *grpc_utils.Claims
+		expected codes.Code
+	}{
+		{"public_without_claims", "/grpc.health.v1.Health/Check", nil, codes.OK},
+		{"admin_allowed", "/admin.v1.AdminService/DeleteUser", admin, codes.OK},
+		{"viewer_denied", "/admin.v1.AdminService/DeleteUser", viewer, codes.PermissionDenied},
+		{"unauthenticated", "/admin.v1.AdminService/DeleteUser", nil, codes.Unauthenticated},
+		{"role_and_scope", "/orders.v1.OrderService/CreateOrder", admin, codes.OK},
+		{"missing_scope", "/orders.v1.OrderService/CreateOrder", sales, codes.PermissionDenied},
+		{"authenticated_only", "/orders.v1.OrderService/GetOrder", viewer, codes.OK},
+		{"default_deny", "/other.v1.Service/Method", admin, codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = grpc_utils.ContextWithClaims(ctx, tt.claims)
+			}
+			called := false
+			handler := func(ctx context.Context, req any) (any, error) {
+				called = true
+				return nil, nil
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if got := status.Code(err); got != tt.expected {
+				t.Fatalf("Expected code %v, got %v (%v)", tt.expected, got, err)
+			}
+			if called != (tt.expected == codes.OK) {
+				t.Errorf("Handler called = %v, want %v", called, tt.expected == codes.OK)
+			}
+		})
+	}
+}
+
+func TestAuthzInterceptor_AuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+
+	interceptor := grpc_utils.BuildAuthzInterceptor(grpc_utils.AuthzConfig{
+		Rules: []grpc_utils.AuthzRule{
+			{Method: "/admin.v1.AdminService/*", Roles: []string{"admin"}},
+		},
+	})
+
+	claims := &grpc_utils.Claims{Roles: []string{"viewer"}}
+	claims.Subject = "user-42"
+	ctx := grpc_utils.ContextWithClaims(context.Background(), claims)
+	info := &grpc.UnaryServerInfo{FullMethod: "/admin.v1.AdminService/DeleteUser"}
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected PermissionDenied, got %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{
+		"authorization denied",
+		"method=/admin.v1.AdminService/DeleteUser",
+		"subject=user-42",
+		"required_roles=[admin]",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected audit log to contain %q, got %q", want, output)
+		}
+	}
+}
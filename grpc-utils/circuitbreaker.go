@@ -0,0 +1,402 @@
+package grpc_utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultBreakerWindow           = 10 * time.Second
+	defaultBreakerMinRequests      = 20
+	defaultBreakerFailureRate      = 0.5
+	defaultBreakerOpenTimeout      = 30 * time.Second
+	defaultBreakerHalfOpenRequests = 1
+)
+
+// defaultBreakerFailureCodes are the status codes that indicate an unhealthy
+// downstream service.
+var defaultBreakerFailureCodes = []codes.Code{
+	codes.Unavailable,
+	codes.DeadlineExceeded,
+	codes.ResourceExhausted,
+	codes.Internal,
+	codes.Unknown,
+}
+
+// ErrCircuitOpen is the cause of errors returned for calls rejected by an
+// open circuit breaker, see IsCircuitOpen.
+var ErrCircuitOpen = errors.New("grpc_utils: circuit breaker open")
+
+// errCircuitOpen returns the error for calls rejected without calling the
+// service while its circuit breaker is open. It converts to
+// codes.Unavailable and wraps ErrCircuitOpen.
+func errCircuitOpen() *Error {
+	return NewError(codes.Unavailable, "service unavailable").WithCause(ErrCircuitOpen)
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all calls through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all calls, see IsCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe calls through.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig holds configuration for the circuit breaker.
+//
+// Example config:
+//
+//	grpc:
+//	  clients:
+//	    orders:
+//	      interceptors: [request_id, metrics, logging, circuit_breaker]
+//	      circuit_breaker:
+//	        window: 10s
+//	        min_requests: 20
+//	        failure_rate: 0.5
+//	        open_timeout: 30s
+type CircuitBreakerConfig struct {
+	// Window is the period over which the failure rate is computed.
+	// Optional. Defaults to 10s.
+	Window time.Duration `mapstructure:"window"`
+	// MinRequests is the number of calls in a window before the breaker can
+	// open. Optional. Defaults to 20.
+	MinRequests int `mapstructure:"min_requests"`
+	// FailureRate opens the breaker when the fraction of failed calls in a
+	// window reaches it. Optional. Defaults to 0.5.
+	FailureRate float64 `mapstructure:"failure_rate"`
+	// OpenTimeout is how long the breaker stays open before letting probe
+	// calls through. Optional. Defaults to 30s.
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+	// HalfOpenRequests is the number of probe calls that must succeed to
+	// close the breaker again. Optional. Defaults to 1.
+	HalfOpenRequests int `mapstructure:"half_open_requests"`
+	// FailureCodes lists the status codes counted as failures, e.g.
+	// "UNAVAILABLE". Optional. Defaults to UNAVAILABLE, DEADLINE_EXCEEDED,
+	// RESOURCE_EXHAUSTED, INTERNAL and UNKNOWN.
+	FailureCodes []string `mapstructure:"failure_codes"`
+	// Metrics configures the breaker state metrics.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	// Logger logs state changes. Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+}
+
+// CircuitBreaker stops calling a target that keeps failing. It keeps one
+// breaker per target, so a single instance can be shared by all connections.
+//
+// A closed breaker opens when the failure rate within a window reaches the
+// threshold. After OpenTimeout it lets HalfOpenRequests probe calls through,
+// closing again if they all succeed and reopening on the first failure.
+type CircuitBreaker struct {
+	cfg          CircuitBreakerConfig
+	failureCodes map[codes.Code]bool
+	now          func() time.Time
+
+	state       *prometheus.GaugeVec
+	transitions *prometheus.CounterVec
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the breaker state of a single target.
+type circuit struct {
+	state CircuitState
+	// generation changes with every state change so outcomes of calls
+	// admitted in an earlier state are ignored.
+	generation  int
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+// NewCircuitBreaker creates a circuit breaker interceptor provider.
+//
+// Example:
+//
+//	breaker, err := grpc_utils.NewCircuitBreaker(grpc_utils.CircuitBreakerConfig{
+//	    FailureRate: 0.5,
+//	    OpenTimeout: 30 * time.Second,
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	conn, err := grpc_utils.NewClientConn(grpc_utils.ClientConfig{
+//	    Target:            "dns:///orders:50051",
+//	    UnaryInterceptors: []grpc.UnaryClientInterceptor{breaker.UnaryClientInterceptor()},
+//	})
+func NewCircuitBreaker(cfg CircuitBreakerConfig) (*CircuitBreaker, error) {
+	if cfg.Window <= 0 {
+		cfg.Window = defaultBreakerWindow
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultBreakerMinRequests
+	}
+	if cfg.FailureRate <= 0 || cfg.FailureRate > 1 {
+		cfg.FailureRate = defaultBreakerFailureRate
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = defaultBreakerHalfOpenRequests
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	failureCodes, err := parseCodes(cfg.FailureCodes, defaultBreakerFailureCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	if cfg.Metrics.Registry != nil {
+		registerer = cfg.Metrics.Registry
+	}
+	return &CircuitBreaker{
+		cfg:          cfg,
+		failureCodes: failureCodes,
+		now:          time.Now,
+		state: registerCollector(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.Metrics.Namespace,
+			Subsystem: "grpc_client",
+			Name:      "circuit_breaker_state",
+			Help:      "Circuit breaker state per target: 0 closed, 1 open, 2 half-open.",
+		}, []string{"target"})),
+		transitions: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Metrics.Namespace,
+			Subsystem: "grpc_client",
+			Name:      "circuit_breaker_transitions_total",
+			Help:      "Total number of circuit breaker state changes, by new state.",
+		}, []string{"target", "state"})),
+		circuits: make(map[string]*circuit),
+	}, nil
+}
+
+// State returns the current state of the breaker for target.
+func (b *CircuitBreaker) State(target string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[target]
+	if !ok {
+		return CircuitClosed
+	}
+	b.refresh(target, c, b.now())
+	return c.state
+}
+
+// allow reports whether a call to target may proceed. Allowed calls must
+// report their outcome with done.
+func (b *CircuitBreaker) allow(target string) (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[target]
+	if !ok {
+		c = &circuit{windowStart: b.now()}
+		b.circuits[target] = c
+		b.state.WithLabelValues(target).Set(float64(CircuitClosed))
+	}
+	b.refresh(target, c, b.now())
+
+	switch c.state {
+	case CircuitOpen:
+		return nil, errCircuitOpen()
+	case CircuitHalfOpen:
+		if c.probes >= b.cfg.HalfOpenRequests {
+			return nil, errCircuitOpen()
+		}
+		c.probes++
+	}
+	generation := c.generation
+	return func(err error) { b.record(target, generation, err) }, nil
+}
+
+// refresh moves an open breaker to half-open once OpenTimeout has passed and
+// starts a new window when the current one has ended.
+func (b *CircuitBreaker) refresh(target string, c *circuit, now time.Time) {
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= b.cfg.OpenTimeout {
+		b.transition(target, c, CircuitHalfOpen, now)
+	}
+	if c.state == CircuitClosed && now.Sub(c.windowStart) >= b.cfg.Window {
+		c.windowStart = now
+		c.requests = 0
+		c.failures = 0
+	}
+}
+
+func (b *CircuitBreaker) record(target string, generation int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[target]
+	if c.generation != generation {
+		return
+	}
+	now := b.now()
+	code := status.Code(err)
+	// Calls canceled by the caller say nothing about the target.
+	ignored := code == codes.Canceled
+	failed := b.failureCodes[code]
+
+	switch c.state {
+	case CircuitClosed:
+		if ignored {
+			return
+		}
+		b.refresh(target, c, now)
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= b.cfg.MinRequests &&
+			float64(c.failures)/float64(c.requests) >= b.cfg.FailureRate {
+			b.transition(target, c, CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		c.probes--
+		switch {
+		case ignored:
+		case failed:
+			b.transition(target, c, CircuitOpen, now)
+		default:
+			c.successes++
+			if c.successes >= b.cfg.HalfOpenRequests {
+				b.transition(target, c, CircuitClosed, now)
+			}
+		}
+	}
+}
+
+func (b *CircuitBreaker) transition(target string, c *circuit, to CircuitState, now time.Time) {
+	from := c.state
+	failureRate := 0.0
+	if c.requests > 0 {
+		failureRate = float64(c.failures) / float64(c.requests)
+	}
+
+	c.state = to
+	c.generation++
+	c.windowStart = now
+	c.requests = 0
+	c.failures = 0
+	c.probes = 0
+	c.successes = 0
+	if to == CircuitOpen {
+		c.openedAt = now
+	}
+
+	b.state.WithLabelValues(target).Set(float64(to))
+	b.transitions.WithLabelValues(target, to.String()).Inc()
+	level := slog.LevelInfo
+	if to == CircuitOpen {
+		level = slog.LevelWarn
+	}
+	b.cfg.Logger.Log(context.Background(), level, "circuit breaker state changed",
+		"target", target,
+		"from", from.String(),
+		"to", to.String(),
+		"failure_rate", failureRate,
+	)
+}
+
+// UnaryClientInterceptor returns an interceptor that fails calls with
+// codes.Unavailable while the breaker of the connection's target is open.
+func (b *CircuitBreaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		done, err := b.allow(cc.Target())
+		if err != nil {
+			return err
+		}
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		done(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that fails new streams with
+// codes.Unavailable while the breaker of the connection's target is open. Only
+// the outcome of opening the stream is recorded.
+func (b *CircuitBreaker) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		done, err := b.allow(cc.Target())
+		if err != nil {
+			return nil, err
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		done(err)
+		return stream, err
+	}
+}
+
+// IsCircuitOpen reports whether err was returned by an open circuit breaker.
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// parseCodes converts status code names such as "UNAVAILABLE" into a set,
+// returning defaults when names is empty.
+func parseCodes(names []string, defaults []codes.Code) (map[codes.Code]bool, error) {
+	set := make(map[codes.Code]bool)
+	if len(names) == 0 {
+		for _, code := range defaults {
+			set[code] = true
+		}
+		return set, nil
+	}
+	for _, name := range names {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil {
+			return nil, fmt.Errorf("grpc_utils: unknown status code %q", name)
+		}
+		set[code] = true
+	}
+	return set, nil
+}
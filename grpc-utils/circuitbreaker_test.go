@@ -0,0 +1,159 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// idleClientConn returns a connection to target that is never used to send
+// calls, for testing client interceptors with fake invokers.
+func idleClientConn(t *testing.T, target string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func invokerReturning(err error) grpc.UnaryInvoker {
+	return func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		return err
+	}
+}
+
+func TestNewCircuitBreaker_UnknownCode(t *testing.T) {
+	_, err := grpc_utils.NewCircuitBreaker(grpc_utils.CircuitBreakerConfig{FailureCodes: []string{"BROKEN"}})
+	if err == nil {
+		t.Error("Expected error for unknown status code")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	breaker, err := grpc_utils.NewCircuitBreaker(grpc_utils.CircuitBreakerConfig{
+		MinRequests:      4,
+		FailureRate:      0.5,
+		OpenTimeout:      50 * time.Millisecond,
+		HalfOpenRequests: 2,
+		Metrics:          grpc_utils.MetricsConfig{Registry: registry},
+		Logger:           discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewCircuitBreaker failed: %v", err)
+	}
+	interceptor := breaker.UnaryClientInterceptor()
+	orders := idleClientConn(t, "passthrough:///orders")
+	payments := idleClientConn(t, "passthrough:///payments")
+	call := func(cc *grpc.ClientConn, err error) error {
+		return interceptor(context.Background(), "/orders.v1.OrderService/GetOrder", nil, nil, cc, invokerReturning(err))
+	}
+
+	// Application errors and canceled calls do not count as failures.
+	_ = call(orders, status.Error(codes.NotFound, "no such order"))
+	_ = call(orders, status.Error(codes.Canceled, "canceled"))
+	_ = call(orders, status.Error(codes.Unavailable, "down"))
+	if breaker.State(orders.Target()) != grpc_utils.CircuitClosed {
+		t.Fatalf("Expected breaker to stay closed below the minimum requests")
+	}
+	_ = call(orders, status.Error(codes.Unavailable, "down"))
+	_ = call(orders, status.Error(codes.Unavailable, "down"))
+	if got := breaker.State(orders.Target()); got != grpc_utils.CircuitOpen {
+		t.Fatalf("Expected breaker to open, got %v", got)
+	}
+
+	err = call(orders, nil)
+	if !errors.Is(err, grpc_utils.ErrCircuitOpen) || !grpc_utils.IsCircuitOpen(err) || status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected open circuit error, got %v", err)
+	}
+	// Rejections return fresh errors, so decorating one does not leak into
+	// later calls.
+	err.(*grpc_utils.Error).WithCause(errors.New("decorated"))
+	if err := call(orders, nil); !errors.Is(err, grpc_utils.ErrCircuitOpen) || strings.Contains(err.Error(), "decorated") {
+		t.Errorf("Expected an undecorated open circuit error, got %v", err)
+	}
+	if err := call(payments, nil); err != nil {
+		t.Errorf("Expected other targets to be unaffected, got %v", err)
+	}
+	expected := `
+# HELP grpc_client_circuit_breaker_transitions_total Total number of circuit breaker state changes, by new state.
+# TYPE grpc_client_circuit_breaker_transitions_total counter
+grpc_client_circuit_breaker_transitions_total{state="open",target="passthrough:///orders"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"grpc_client_circuit_breaker_transitions_total"); err != nil {
+		t.Error(err)
+	}
+
+	// A failed probe reopens the breaker.
+	time.Sleep(60 * time.Millisecond)
+	if got := breaker.State(orders.Target()); got != grpc_utils.CircuitHalfOpen {
+		t.Fatalf("Expected breaker to be half-open, got %v", got)
+	}
+	_ = call(orders, status.Error(codes.Unavailable, "still down"))
+	if got := breaker.State(orders.Target()); got != grpc_utils.CircuitOpen {
+		t.Fatalf("Expected failed probe to reopen the breaker, got %v", got)
+	}
+
+	// Enough successful probes close it again.
+	time.Sleep(60 * time.Millisecond)
+	for range 2 {
+		if err := call(orders, nil); err != nil {
+			t.Fatalf("Expected probe to pass, got %v", err)
+		}
+	}
+	if got := breaker.State(orders.Target()); got != grpc_utils.CircuitClosed {
+		t.Errorf("Expected breaker to close, got %v", got)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenLimitsProbes(t *testing.T) {
+	breaker, err := grpc_utils.NewCircuitBreaker(grpc_utils.CircuitBreakerConfig{
+		MinRequests: 1,
+		OpenTimeout: 10 * time.Millisecond,
+		Metrics:     grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()},
+		Logger:      discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewCircuitBreaker failed: %v", err)
+	}
+	interceptor := breaker.UnaryClientInterceptor()
+	cc := idleClientConn(t, "passthrough:///orders")
+	_ = interceptor(context.Background(), "/m", nil, nil, cc, invokerReturning(status.Error(codes.Unavailable, "down")))
+	time.Sleep(20 * time.Millisecond)
+
+	release := make(chan struct{})
+	probing := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- interceptor(context.Background(), "/m", nil, nil, cc,
+			func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+				close(probing)
+				<-release
+				return nil
+			})
+	}()
+	<-probing
+	if err := interceptor(context.Background(), "/m", nil, nil, cc, invokerReturning(nil)); !grpc_utils.IsCircuitOpen(err) {
+		t.Errorf("Expected concurrent probe to be rejected, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Expected probe to pass, got %v", err)
+	}
+	if got := breaker.State(cc.Target()); got != grpc_utils.CircuitClosed {
+		t.Errorf("Expected breaker to close, got %v", got)
+	}
+}
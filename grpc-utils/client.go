@@ -0,0 +1,481 @@
+package grpc_utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"github.com/poly-workshop/go-webmods/app"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// InterceptorRetry selects the client retry interceptor, see Retrier.
+	InterceptorRetry = "retry"
+	// InterceptorCircuitBreaker selects the client circuit breaker
+	// interceptor, see CircuitBreaker.
+	InterceptorCircuitBreaker = "circuit_breaker"
+)
+
+// DefaultClientInterceptors lists the client interceptors enabled when
+// ClientConfig does not select any.
+var DefaultClientInterceptors = []string{
+	InterceptorRequestID,
+	InterceptorMetrics,
+	InterceptorLogging,
+}
+
+// ClientTLSConfig holds the client TLS settings.
+type ClientTLSConfig struct {
+	// Enabled uses TLS with the system roots when no CAFile is set.
+	Enabled bool `mapstructure:"enabled"`
+	// CAFile is the PEM encoded CA bundle used to verify the server.
+	// Optional. Setting it enables TLS.
+	CAFile string `mapstructure:"ca_file"`
+	// CertFile and KeyFile are the client certificate for mutual TLS.
+	// Optional.
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ServerName overrides the name used to verify the server certificate.
+	// Optional.
+	ServerName string `mapstructure:"server_name"`
+}
+
+// RetryPolicyConfig configures transparent retries of failed calls, see
+// https://github.com/grpc/proposal/blob/master/A6-client-retries.md.
+type RetryPolicyConfig struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	// Retries are disabled when it is below 2.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// InitialBackoff is the delay before the first retry. Optional.
+	// Defaults to 100ms.
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// MaxBackoff caps the delay between retries. Optional. Defaults to 1s.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// BackoffMultiplier grows the delay after each retry. Optional.
+	// Defaults to 2.
+	BackoffMultiplier float64 `mapstructure:"backoff_multiplier"`
+	// RetryableCodes lists the status codes that are retried, e.g.
+	// "UNAVAILABLE". Optional. Defaults to UNAVAILABLE.
+	RetryableCodes []string `mapstructure:"retryable_codes"`
+}
+
+// ClientKeepaliveConfig holds the client keepalive parameters.
+type ClientKeepaliveConfig struct {
+	// Time after which the client pings an idle connection. Optional.
+	Time time.Duration `mapstructure:"time"`
+	// Timeout waiting for a ping ack before closing the connection.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// PermitWithoutStream sends pings without active streams.
+	PermitWithoutStream bool `mapstructure:"permit_without_stream"`
+}
+
+// ClientConfig holds configuration for NewClientConn.
+//
+// Example config:
+//
+//	grpc:
+//	  clients:
+//	    orders:
+//	      target: dns:///orders.default.svc.cluster.local:50051
+//	      timeout: 5s
+//	      load_balancing: round_robin
+//	      retry:
+//	        max_attempts: 3
+//	        retryable_codes: [UNAVAILABLE]
+//	      interceptors: [request_id, metrics, logging, retry, circuit_breaker]
+//	      retries:
+//	        methods:
+//	          - /orders.v1.OrderService/Get*
+//	      circuit_breaker:
+//	        failure_rate: 0.5
+//	      tls:
+//	        ca_file: /etc/tls/ca.crt
+type ClientConfig struct {
+	// Target is the gRPC target URI of the service.
+	Target string `mapstructure:"target"`
+	// TLS configures transport security. The connection is insecure when
+	// TLS is not enabled.
+	TLS ClientTLSConfig `mapstructure:"tls"`
+	// Timeout is applied to calls made without a deadline. Optional.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Retry configures transparent retries. Combined with the retry
+	// interceptor, each of its attempts is retried again, multiplying the
+	// attempts.
+	Retry RetryPolicyConfig `mapstructure:"retry"`
+	// LoadBalancing is the load balancing policy, e.g. "round_robin".
+	// Optional. Defaults to gRPC's pick_first.
+	LoadBalancing string `mapstructure:"load_balancing"`
+	// Keepalive configures connection keepalive.
+	Keepalive ClientKeepaliveConfig `mapstructure:"keepalive"`
+	// Interceptors selects the built-in client interceptors. They are
+	// always chained in the order request ID, metrics, logging, retry,
+	// circuit breaker. Optional. Defaults to DefaultClientInterceptors.
+	Interceptors []string `mapstructure:"interceptors"`
+	// RequestID configures the propagated request ID header.
+	RequestID RequestIDConfig `mapstructure:"request_id"`
+	// Metrics configures the client metrics interceptor.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+	// Retries configures the retry interceptor.
+	Retries RetryConfig `mapstructure:"retries"`
+	// CircuitBreaker configures the circuit breaker interceptor.
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+
+	// Logger is used by the logging interceptor.
+	// Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+	// UnaryInterceptors are chained after the built-in interceptors.
+	UnaryInterceptors []grpc.UnaryClientInterceptor `mapstructure:"-"`
+	// StreamInterceptors are chained after the built-in interceptors.
+	StreamInterceptors []grpc.StreamClientInterceptor `mapstructure:"-"`
+	// DialOptions are additional dial options.
+	DialOptions []grpc.DialOption `mapstructure:"-"`
+}
+
+// NewClientConn creates a client connection from cfg with the built-in client
+// interceptors, TLS, retry and load balancing policy. The retry and circuit
+// breaker interceptors are only chained when selected in cfg.Interceptors.
+//
+// Example:
+//
+//	var cfg grpc_utils.ClientConfig
+//	if err := app.Config().UnmarshalKey("grpc.clients.orders", &cfg); err != nil {
+//	    panic(err)
+//	}
+//	conn, err := grpc_utils.NewClientConn(cfg)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer conn.Close()
+//	orders := pb.NewOrderServiceClient(conn)
+func NewClientConn(cfg ClientConfig) (*grpc.ClientConn, error) {
+	if cfg.Target == "" {
+		return nil, errors.New("grpc_utils: client target is required")
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if len(cfg.Interceptors) == 0 {
+		cfg.Interceptors = DefaultClientInterceptors
+	}
+
+	enabled := make(map[string]bool, len(cfg.Interceptors))
+	for _, name := range cfg.Interceptors {
+		switch name {
+		case InterceptorRequestID, InterceptorMetrics, InterceptorLogging,
+			InterceptorRetry, InterceptorCircuitBreaker:
+			enabled[name] = true
+		default:
+			return nil, fmt.Errorf("grpc_utils: unknown client interceptor %q", name)
+		}
+	}
+
+	var unary []grpc.UnaryClientInterceptor
+	var stream []grpc.StreamClientInterceptor
+	if enabled[InterceptorRequestID] {
+		unary = append(unary, BuildRequestIDClientInterceptor(cfg.RequestID))
+		stream = append(stream, BuildRequestIDStreamClientInterceptor(cfg.RequestID))
+	}
+	if enabled[InterceptorMetrics] {
+		metrics := NewClientMetrics(cfg.Metrics)
+		unary = append(unary, metrics.UnaryClientInterceptor())
+		stream = append(stream, metrics.StreamClientInterceptor())
+	}
+	if enabled[InterceptorLogging] {
+		unary = append(unary, logging.UnaryClientInterceptor(slogLogger(cfg.Logger)))
+		stream = append(stream, logging.StreamClientInterceptor(slogLogger(cfg.Logger)))
+	}
+	// The retries come before the breaker, so every attempt is counted and
+	// an open breaker stops the retries.
+	if enabled[InterceptorRetry] {
+		if cfg.Retries.Logger == nil {
+			cfg.Retries.Logger = cfg.Logger
+		}
+		retrier, err := NewRetrier(cfg.Retries)
+		if err != nil {
+			return nil, err
+		}
+		unary = append(unary, retrier.UnaryClientInterceptor())
+	}
+	if enabled[InterceptorCircuitBreaker] {
+		if cfg.CircuitBreaker.Logger == nil {
+			cfg.CircuitBreaker.Logger = cfg.Logger
+		}
+		breaker, err := NewCircuitBreaker(cfg.CircuitBreaker)
+		if err != nil {
+			return nil, err
+		}
+		unary = append(unary, breaker.UnaryClientInterceptor())
+		stream = append(stream, breaker.StreamClientInterceptor())
+	}
+	// The timeout wraps the retries, so it bounds the whole call.
+	if cfg.Timeout > 0 {
+		unary = append([]grpc.UnaryClientInterceptor{BuildTimeoutClientInterceptor(cfg.Timeout)}, unary...)
+	}
+	unary = append(unary, cfg.UnaryInterceptors...)
+	stream = append(stream, cfg.StreamInterceptors...)
+
+	creds, err := clientCredentials(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	serviceConfig, err := clientServiceConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	}
+	if serviceConfig != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(serviceConfig))
+	}
+	if cfg.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.Keepalive.Time,
+			Timeout:             cfg.Keepalive.Timeout,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		}))
+	}
+	opts = append(opts, cfg.DialOptions...)
+
+	conn, err := grpc.NewClient(cfg.Target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("grpc_utils: failed to create client for %s: %w", cfg.Target, err)
+	}
+	return conn, nil
+}
+
+func clientCredentials(cfg ClientTLSConfig) (credentials.TransportCredentials, error) {
+	if !cfg.Enabled && cfg.CAFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: cfg.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("grpc_utils: failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("grpc_utils: no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("grpc_utils: failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// clientServiceConfig renders the load balancing and retry policy as a gRPC
+// service config.
+func clientServiceConfig(cfg ClientConfig) (string, error) {
+	serviceConfig := map[string]any{}
+	if cfg.LoadBalancing != "" {
+		serviceConfig["loadBalancingConfig"] = []map[string]any{{cfg.LoadBalancing: map[string]any{}}}
+	}
+
+	retry := cfg.Retry
+	if retry.MaxAttempts >= 2 {
+		if retry.InitialBackoff <= 0 {
+			retry.InitialBackoff = 100 * time.Millisecond
+		}
+		if retry.MaxBackoff <= 0 {
+			retry.MaxBackoff = time.Second
+		}
+		if retry.BackoffMultiplier <= 0 {
+			retry.BackoffMultiplier = 2
+		}
+		codes := make([]string, 0, len(retry.RetryableCodes))
+		for _, code := range retry.RetryableCodes {
+			codes = append(codes, strings.ToUpper(code))
+		}
+		if len(codes) == 0 {
+			codes = []string{"UNAVAILABLE"}
+		}
+		serviceConfig["methodConfig"] = []map[string]any{{
+			"name": []map[string]any{{}},
+			"retryPolicy": map[string]any{
+				"maxAttempts":          retry.MaxAttempts,
+				"initialBackoff":       durationJSON(retry.InitialBackoff),
+				"maxBackoff":           durationJSON(retry.MaxBackoff),
+				"backoffMultiplier":    retry.BackoffMultiplier,
+				"retryableStatusCodes": codes,
+			},
+		}}
+	}
+
+	if len(serviceConfig) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(serviceConfig)
+	if err != nil {
+		return "", fmt.Errorf("grpc_utils: failed to render service config: %w", err)
+	}
+	return string(data), nil
+}
+
+// durationJSON formats d as a protobuf JSON duration, e.g. "0.100s".
+func durationJSON(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// Creates a gRPC client interceptor that applies timeout to calls made
+// without a deadline.
+func BuildTimeoutClientInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Creates a gRPC client interceptor that propagates the request ID stored in
+// the context by the server request ID interceptor to outgoing calls.
+func BuildRequestIDClientInterceptor(cfg RequestIDConfig) grpc.UnaryClientInterceptor {
+	header := requestIDHeader(cfg)
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		return invoker(outgoingRequestIDContext(ctx, header), method, req, reply, cc, opts...)
+	}
+}
+
+// Creates a gRPC client interceptor that propagates the request ID stored in
+// the context to outgoing streams.
+func BuildRequestIDStreamClientInterceptor(cfg RequestIDConfig) grpc.StreamClientInterceptor {
+	header := requestIDHeader(cfg)
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		return streamer(outgoingRequestIDContext(ctx, header), desc, cc, method, opts...)
+	}
+}
+
+func outgoingRequestIDContext(ctx context.Context, header string) context.Context {
+	requestID, ok := RequestIDFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(header)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, header, requestID)
+}
+
+// ClientRegistry lazily creates and reuses named client connections.
+type ClientRegistry struct {
+	mu      sync.Mutex
+	configs map[string]ClientConfig
+	conns   map[string]*grpc.ClientConn
+}
+
+// NewClientRegistry creates a registry of the given client configurations.
+func NewClientRegistry(configs map[string]ClientConfig) *ClientRegistry {
+	return &ClientRegistry{
+		configs: configs,
+		conns:   make(map[string]*grpc.ClientConn),
+	}
+}
+
+// LoadClientRegistry creates a registry from the client configurations in
+// the given app config section, e.g. "grpc.clients".
+func LoadClientRegistry(key string) (*ClientRegistry, error) {
+	var configs map[string]ClientConfig
+	if err := app.Config().UnmarshalKey(key, &configs); err != nil {
+		return nil, fmt.Errorf("grpc_utils: failed to load client configs from %s: %w", key, err)
+	}
+	return NewClientRegistry(configs), nil
+}
+
+// Conn returns the connection of the named client, creating it on first use.
+//
+// Example:
+//
+//	clients, err := grpc_utils.LoadClientRegistry("grpc.clients")
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer clients.Close()
+//	conn, err := clients.Conn("orders")
+//	if err != nil {
+//	    return err
+//	}
+//	orders := pb.NewOrderServiceClient(conn)
+func (r *ClientRegistry) Conn(name string) (*grpc.ClientConn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if conn, ok := r.conns[name]; ok {
+		return conn, nil
+	}
+	cfg, ok := r.configs[name]
+	if !ok {
+		return nil, fmt.Errorf("grpc_utils: unknown client %q", name)
+	}
+	conn, err := NewClientConn(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.conns[name] = conn
+	return conn, nil
+}
+
+// Close closes all connections created by the registry.
+func (r *ClientRegistry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for name, conn := range r.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("grpc_utils: close client %s: %w", name, err))
+		}
+		delete(r.conns, name)
+	}
+	return errors.Join(errs...)
+}
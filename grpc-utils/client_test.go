@@ -0,0 +1,244 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// flakyHealthServer fails Check with Unavailable until failures reaches zero
+// and records the request ID of the last call.
+type flakyHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	failures  atomic.Int32
+	calls     atomic.Int32
+	requestID atomic.Value
+}
+
+func (s *flakyHealthServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.calls.Add(1)
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("x-request-id")) > 0 {
+		s.requestID.Store(md.Get("x-request-id")[0])
+	}
+	if s.failures.Add(-1) >= 0 {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *flakyHealthServer) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+// clientConfig returns a client config dialing a plain gRPC server with
+// health on a bufconn listener.
+func clientConfig(t *testing.T, health healthpb.HealthServer) grpc_utils.ClientConfig {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	return grpc_utils.ClientConfig{
+		Target:  "passthrough:///bufnet",
+		Metrics: grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()},
+		Logger:  discardLogger,
+		DialOptions: []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+		},
+	}
+}
+
+func TestNewClientConn_Validation(t *testing.T) {
+	if _, err := grpc_utils.NewClientConn(grpc_utils.ClientConfig{}); err == nil {
+		t.Error("Expected error without target")
+	}
+	_, err := grpc_utils.NewClientConn(grpc_utils.ClientConfig{
+		Target:       "passthrough:///bufnet",
+		Interceptors: []string{"unknown"},
+	})
+	if err == nil {
+		t.Error("Expected error for unknown interceptor")
+	}
+	_, err = grpc_utils.NewClientConn(grpc_utils.ClientConfig{
+		Target: "passthrough:///bufnet",
+		TLS:    grpc_utils.ClientTLSConfig{CAFile: "missing.pem"},
+	})
+	if err == nil {
+		t.Error("Expected error for missing CA file")
+	}
+}
+
+func TestNewClientConn_Retry(t *testing.T) {
+	health := &flakyHealthServer{}
+	health.failures.Store(2)
+	cfg := clientConfig(t, health)
+	cfg.Retry = grpc_utils.RetryPolicyConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		RetryableCodes: []string{"unavailable"},
+	}
+	cfg.LoadBalancing = "round_robin"
+
+	conn, err := grpc_utils.NewClientConn(cfg)
+	if err != nil {
+		t.Fatalf("NewClientConn failed: %v", err)
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Expected call to succeed after retries, got %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING || health.calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", health.calls.Load())
+	}
+}
+
+func TestNewClientConn_Retries(t *testing.T) {
+	health := &flakyHealthServer{}
+	health.failures.Store(2)
+	cfg := clientConfig(t, health)
+	cfg.Interceptors = []string{grpc_utils.InterceptorRetry, grpc_utils.InterceptorCircuitBreaker}
+	cfg.Retries = grpc_utils.RetryConfig{
+		Methods:        []string{"/grpc.health.v1.Health/Check"},
+		InitialBackoff: time.Millisecond,
+	}
+	cfg.CircuitBreaker = grpc_utils.CircuitBreakerConfig{Metrics: cfg.Metrics}
+
+	conn, err := grpc_utils.NewClientConn(cfg)
+	if err != nil {
+		t.Fatalf("NewClientConn failed: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Expected call to succeed after retries, got %v", err)
+	}
+	if health.calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", health.calls.Load())
+	}
+
+	cfg.Retries.RetryableCodes = []string{"bogus"}
+	if _, err := grpc_utils.NewClientConn(cfg); err == nil {
+		t.Error("Expected error for invalid retry config")
+	}
+}
+
+func TestNewClientConn_Timeout(t *testing.T) {
+	cfg := clientConfig(t, &flakyHealthServer{})
+	cfg.Timeout = 50 * time.Millisecond
+	conn, err := grpc_utils.NewClientConn(cfg)
+	if err != nil {
+		t.Fatalf("NewClientConn failed: %v", err)
+	}
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	// Streams are not bound by the per-call timeout.
+	recvErr := make(chan error, 1)
+	go func() {
+		_, err := stream.Recv()
+		recvErr <- err
+	}()
+	select {
+	case err := <-recvErr:
+		t.Errorf("Expected stream to stay open, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	timeoutInterceptor := grpc_utils.BuildTimeoutClientInterceptor(50 * time.Millisecond)
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > 50*time.Millisecond {
+			t.Errorf("Expected default deadline, got %v", deadline)
+		}
+		return nil
+	}
+	_ = timeoutInterceptor(context.Background(), "/test", nil, nil, nil, invoker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_ = timeoutInterceptor(ctx, "/test", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			if deadline, _ := ctx.Deadline(); time.Until(deadline) < time.Second {
+				t.Errorf("Expected caller deadline to be kept, got %v", deadline)
+			}
+			return nil
+		})
+}
+
+func TestNewClientConn_RequestIDPropagation(t *testing.T) {
+	health := &flakyHealthServer{}
+	conn, err := grpc_utils.NewClientConn(clientConfig(t, health))
+	if err != nil {
+		t.Fatalf("NewClientConn failed: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	// Simulate a server handler calling another service.
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-123"))
+	_, err = grpc_utils.BuildRequestIDInterceptor()(ctx, nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, _ any) (any, error) {
+			return client.Check(ctx, &healthpb.HealthCheckRequest{})
+		})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if got := health.requestID.Load(); got != "req-123" {
+		t.Errorf("Expected request ID req-123 to be propagated, got %v", got)
+	}
+}
+
+func TestClientRegistry(t *testing.T) {
+	registry := grpc_utils.NewClientRegistry(map[string]grpc_utils.ClientConfig{
+		"health": clientConfig(t, &flakyHealthServer{}),
+	})
+	defer registry.Close()
+
+	first, err := registry.Conn("health")
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	second, err := registry.Conn("health")
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	if first != second {
+		t.Error("Expected the connection to be reused")
+	}
+	if _, err := registry.Conn("orders"); err == nil {
+		t.Error("Expected error for unknown client")
+	}
+
+	if err := registry.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	third, err := registry.Conn("health")
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	if third == first {
+		t.Error("Expected a new connection after Close")
+	}
+}
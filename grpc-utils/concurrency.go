@@ -0,0 +1,295 @@
+package grpc_utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// CriticalityCritical calls may use the whole concurrency limit.
+	CriticalityCritical = "critical"
+	// CriticalityDefault calls may use 90% of the concurrency limit.
+	CriticalityDefault = "default"
+	// CriticalitySheddable calls may use 70% of the concurrency limit, so
+	// they are shed first.
+	CriticalitySheddable = "sheddable"
+
+	defaultConcurrencyMinLimit     = 10
+	defaultConcurrencyMaxLimit     = 1000
+	defaultConcurrencyBackoffRatio = 0.9
+)
+
+// criticalityShare is the fraction of the limit each criticality may use.
+var criticalityShare = map[string]float64{
+	CriticalityCritical:  1,
+	CriticalityDefault:   0.9,
+	CriticalitySheddable: 0.7,
+}
+
+// errOverloaded returns the error for shed calls.
+func errOverloaded() *Error {
+	return &Error{Code: codes.Unavailable, Message: "server overloaded", Retryable: true}
+}
+
+// MethodCriticality sets the criticality of methods matching a pattern.
+type MethodCriticality struct {
+	// Method is a full method name pattern, see MatchMethod.
+	Method string `mapstructure:"method"`
+	// Criticality is one of "critical", "default" or "sheddable".
+	Criticality string `mapstructure:"criticality"`
+}
+
+// ConcurrencyLimitConfig holds configuration for the concurrency limiter.
+//
+// Example config:
+//
+//	grpc:
+//	  server:
+//	    concurrency_limit:
+//	      limit: 200
+//	      adaptive: true
+//	      target_latency: 250ms
+//	      methods:
+//	        - method: /grpc.health.v1.Health/*
+//	          criticality: critical
+//	        - method: /reports.v1.ReportService/*
+//	          criticality: sheddable
+type ConcurrencyLimitConfig struct {
+	// Limit is the maximum number of calls in flight, or the initial limit
+	// when Adaptive is set. The limiter is disabled when it is unset.
+	Limit int `mapstructure:"limit"`
+	// Adaptive adjusts the limit with AIMD: it grows by about one per limit
+	// successful calls while the limit is in use, and shrinks by
+	// BackoffRatio when a call exceeds TargetLatency or its deadline.
+	Adaptive bool `mapstructure:"adaptive"`
+	// MinLimit bounds the adaptive limit from below. It must not exceed
+	// Limit. Optional. Defaults to 10, or Limit if it is lower.
+	MinLimit int `mapstructure:"min_limit"`
+	// MaxLimit bounds the adaptive limit from above. It must not be below
+	// Limit. Optional. Defaults to 1000, or Limit if it is higher.
+	MaxLimit int `mapstructure:"max_limit"`
+	// TargetLatency is the latency above which a call signals overload.
+	// Optional. Only deadline exceeded calls signal overload when unset.
+	TargetLatency time.Duration `mapstructure:"target_latency"`
+	// BackoffRatio multiplies the limit on overload. Optional. Defaults to 0.9.
+	BackoffRatio float64 `mapstructure:"backoff_ratio"`
+	// Methods set the criticality per method. The first match applies, and
+	// other methods are "default".
+	Methods []MethodCriticality `mapstructure:"methods"`
+	// Metrics configures the limit and shed call metrics.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	// Logger logs shed calls at debug level. Optional. Defaults to
+	// slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+}
+
+func (cfg ConcurrencyLimitConfig) enabled() bool {
+	return cfg.Limit > 0
+}
+
+func (cfg ConcurrencyLimitConfig) criticality(fullMethod string) string {
+	for _, m := range cfg.Methods {
+		if MatchMethod(m.Method, fullMethod) {
+			return m.Criticality
+		}
+	}
+	return CriticalityDefault
+}
+
+// ConcurrencyLimiter sheds calls with codes.Unavailable once the number of
+// calls in flight reaches the limit for their criticality.
+type ConcurrencyLimiter struct {
+	cfg ConcurrencyLimitConfig
+
+	limitGauge *prometheus.GaugeVec
+	shed       *prometheus.CounterVec
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+}
+
+// NewConcurrencyLimiter creates a concurrency limiter interceptor provider.
+//
+// Example:
+//
+//	limiter, err := grpc_utils.NewConcurrencyLimiter(grpc_utils.ConcurrencyLimitConfig{
+//	    Limit:         200,
+//	    Adaptive:      true,
+//	    TargetLatency: 250 * time.Millisecond,
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(limiter.UnaryServerInterceptor()),
+//	    grpc.ChainStreamInterceptor(limiter.StreamServerInterceptor()),
+//	)
+func NewConcurrencyLimiter(cfg ConcurrencyLimitConfig) (*ConcurrencyLimiter, error) {
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("grpc_utils: concurrency limit must be positive, got %d", cfg.Limit)
+	}
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = min(defaultConcurrencyMinLimit, cfg.Limit)
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = max(defaultConcurrencyMaxLimit, cfg.Limit)
+	}
+	if cfg.Limit < cfg.MinLimit || cfg.Limit > cfg.MaxLimit {
+		return nil, fmt.Errorf("grpc_utils: concurrency limit %d is outside [%d, %d]", cfg.Limit, cfg.MinLimit, cfg.MaxLimit)
+	}
+	if cfg.BackoffRatio <= 0 || cfg.BackoffRatio >= 1 {
+		cfg.BackoffRatio = defaultConcurrencyBackoffRatio
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	for _, m := range cfg.Methods {
+		if _, ok := criticalityShare[m.Criticality]; !ok {
+			return nil, fmt.Errorf("grpc_utils: unknown criticality %q for %s", m.Criticality, m.Method)
+		}
+	}
+
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	if cfg.Metrics.Registry != nil {
+		registerer = cfg.Metrics.Registry
+	}
+	l := &ConcurrencyLimiter{
+		cfg:   cfg,
+		limit: float64(cfg.Limit),
+		limitGauge: registerCollector(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.Metrics.Namespace,
+			Subsystem: "grpc_server",
+			Name:      "concurrency_limit",
+			Help:      "Current maximum number of RPCs in flight.",
+		}, nil)),
+		shed: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Metrics.Namespace,
+			Subsystem: "grpc_server",
+			Name:      "shed_requests_total",
+			Help:      "Total number of RPCs rejected by the concurrency limiter.",
+		}, []string{"grpc_service", "grpc_method", "criticality"})),
+	}
+	l.limitGauge.WithLabelValues().Set(l.limit)
+	return l, nil
+}
+
+// Limit returns the current concurrency limit.
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of calls in flight.
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// acquire admits a call to fullMethod, returning errOverloaded() if it is
+// shed.
+// It returns the number of calls in flight including the admitted one.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, fullMethod string) (int, error) {
+	criticality := l.cfg.criticality(fullMethod)
+
+	l.mu.Lock()
+	allowed := math.Max(1, math.Floor(l.limit*criticalityShare[criticality]))
+	if float64(l.inFlight) >= allowed {
+		inFlight := l.inFlight
+		l.mu.Unlock()
+
+		service, method := splitFullMethod(fullMethod)
+		l.shed.WithLabelValues(service, method, criticality).Inc()
+		l.cfg.Logger.DebugContext(ctx, "request shed",
+			"method", fullMethod,
+			"criticality", criticality,
+			"in_flight", inFlight,
+		)
+		return 0, errOverloaded()
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.mu.Unlock()
+	return inFlight, nil
+}
+
+func (l *ConcurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+}
+
+// observe adapts the limit to the outcome of a unary call.
+func (l *ConcurrencyLimiter) observe(latency time.Duration, err error, inFlight int) {
+	if !l.cfg.Adaptive {
+		return
+	}
+	overloaded := status.Code(err) == codes.DeadlineExceeded ||
+		(l.cfg.TargetLatency > 0 && latency > l.cfg.TargetLatency)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case overloaded:
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.BackoffRatio)
+	case float64(inFlight)*2 >= l.limit:
+		// Grow only while the limit is in use, otherwise it would drift
+		// up while idle and stop protecting the server.
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+	default:
+		return
+	}
+	l.limitGauge.WithLabelValues().Set(l.limit)
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that sheds unary calls
+// above the concurrency limit with codes.Unavailable.
+func (l *ConcurrencyLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		inFlight, err := l.acquire(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer l.release()
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		l.observe(time.Since(start), err, inFlight)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that sheds streams above
+// the concurrency limit with codes.Unavailable. Streams hold a slot until they
+// end but do not adapt the limit, since their duration says nothing about
+// load.
+func (l *ConcurrencyLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if _, err := l.acquire(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		defer l.release()
+		return handler(srv, ss)
+	}
+}
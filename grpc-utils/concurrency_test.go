@@ -0,0 +1,228 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func newTestLimiter(t *testing.T, cfg grpc_utils.ConcurrencyLimitConfig) *grpc_utils.ConcurrencyLimiter {
+	t.Helper()
+	cfg.Metrics = grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()}
+	cfg.Logger = discardLogger
+	limiter, err := grpc_utils.NewConcurrencyLimiter(cfg)
+	if err != nil {
+		t.Fatalf("NewConcurrencyLimiter failed: %v", err)
+	}
+	return limiter
+}
+
+func TestNewConcurrencyLimiter_Validation(t *testing.T) {
+	if _, err := grpc_utils.NewConcurrencyLimiter(grpc_utils.ConcurrencyLimitConfig{}); err == nil {
+		t.Error("Expected error without limit")
+	}
+	_, err := grpc_utils.NewConcurrencyLimiter(grpc_utils.ConcurrencyLimitConfig{
+		Limit:   10,
+		Methods: []grpc_utils.MethodCriticality{{Method: "*", Criticality: "urgent"}},
+	})
+	if err == nil {
+		t.Error("Expected error for unknown criticality")
+	}
+	_, err = grpc_utils.NewConcurrencyLimiter(grpc_utils.ConcurrencyLimitConfig{Limit: 5, MinLimit: 10})
+	if err == nil {
+		t.Error("Expected error for a limit below MinLimit")
+	}
+	_, err = grpc_utils.NewConcurrencyLimiter(grpc_utils.ConcurrencyLimitConfig{Limit: 2000, MaxLimit: 1000})
+	if err == nil {
+		t.Error("Expected error for a limit above MaxLimit")
+	}
+}
+
+func TestConcurrencyLimiter_DefaultBounds(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/GetOrder"}
+	slow := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.DeadlineExceeded, "too slow")
+	}
+
+	// The default MinLimit of 10 is lowered to a smaller limit.
+	small := newTestLimiter(t, grpc_utils.ConcurrencyLimitConfig{Limit: 5, Adaptive: true})
+	_, _ = small.UnaryServerInterceptor()(context.Background(), nil, info, slow)
+	if small.Limit() != 5 {
+		t.Errorf("Expected limit 5 to stay at the minimum, got %d", small.Limit())
+	}
+
+	// The default MaxLimit of 1000 is raised to a larger limit.
+	large := newTestLimiter(t, grpc_utils.ConcurrencyLimitConfig{Limit: 2000, Adaptive: true})
+	if large.Limit() != 2000 {
+		t.Errorf("Expected limit 2000, got %d", large.Limit())
+	}
+	_, _ = large.UnaryServerInterceptor()(context.Background(), nil, info, slow)
+	if large.Limit() != 1800 {
+		t.Errorf("Expected limit 2000 to shrink to 1800, got %d", large.Limit())
+	}
+}
+
+func TestConcurrencyLimiter_PanicReleases(t *testing.T) {
+	limiter := newTestLimiter(t, grpc_utils.ConcurrencyLimitConfig{Limit: 1})
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/GetOrder"}
+	func() {
+		defer func() { _ = recover() }()
+		_, _ = limiter.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			panic("boom")
+		})
+	}()
+	if limiter.InFlight() != 0 {
+		t.Errorf("Expected panicking call to release its slot, got %d in flight", limiter.InFlight())
+	}
+}
+
+func TestConcurrencyLimiter_Criticality(t *testing.T) {
+	limiter := newTestLimiter(t, grpc_utils.ConcurrencyLimitConfig{
+		Limit: 10,
+		Methods: []grpc_utils.MethodCriticality{
+			{Method: "/grpc.health.v1.Health/*", Criticality: grpc_utils.CriticalityCritical},
+			{Method: "/reports.v1.ReportService/*", Criticality: grpc_utils.CriticalitySheddable},
+		},
+	})
+	interceptor := limiter.UnaryServerInterceptor()
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	block := func(method string) error {
+		started := make(chan error, 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+				func(ctx context.Context, req any) (any, error) {
+					started <- nil
+					<-release
+					return nil, nil
+				})
+			if err != nil {
+				started <- err
+			}
+		}()
+		return <-started
+	}
+	defer func() {
+		close(release)
+		wg.Wait()
+	}()
+
+	for range 7 {
+		if err := block("/orders.v1.OrderService/GetOrder"); err != nil {
+			t.Fatalf("Expected call below the limit to be admitted, got %v", err)
+		}
+	}
+	err := block("/reports.v1.ReportService/Export")
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected sheddable call to be shed at 70%% of the limit, got %v", err)
+	}
+	if !hasRetryInfo(status.Convert(err)) {
+		t.Error("Expected shed call to carry RetryInfo")
+	}
+
+	for range 2 {
+		if err := block("/orders.v1.OrderService/GetOrder"); err != nil {
+			t.Fatalf("Expected default call to be admitted, got %v", err)
+		}
+	}
+	if err := block("/orders.v1.OrderService/GetOrder"); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected default call to be shed at 90%% of the limit, got %v", err)
+	}
+	if err := block("/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("Expected critical call to use the whole limit, got %v", err)
+	}
+	if err := block("/grpc.health.v1.Health/Check"); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected critical call to be shed at the limit, got %v", err)
+	}
+	if limiter.InFlight() != 10 {
+		t.Errorf("Expected 10 calls in flight, got %d", limiter.InFlight())
+	}
+}
+
+func TestConcurrencyLimiter_Adaptive(t *testing.T) {
+	limiter := newTestLimiter(t, grpc_utils.ConcurrencyLimitConfig{
+		Limit:         20,
+		Adaptive:      true,
+		MinLimit:      15,
+		TargetLatency: 5 * time.Millisecond,
+	})
+	interceptor := limiter.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/GetOrder"}
+
+	for range 5 {
+		_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+	}
+	if limiter.Limit() != 20 {
+		t.Errorf("Expected limit not to grow while mostly idle, got %d", limiter.Limit())
+	}
+
+	_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		time.Sleep(10 * time.Millisecond)
+		return nil, nil
+	})
+	if limiter.Limit() != 18 {
+		t.Errorf("Expected slow call to shrink the limit to 18, got %d", limiter.Limit())
+	}
+	for range 10 {
+		_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, status.Error(codes.DeadlineExceeded, "too slow")
+		})
+	}
+	if limiter.Limit() != 15 {
+		t.Errorf("Expected limit to stop at the minimum, got %d", limiter.Limit())
+	}
+
+	growing := newTestLimiter(t, grpc_utils.ConcurrencyLimitConfig{Limit: 1, MinLimit: 1, Adaptive: true})
+	_, _ = growing.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	if growing.Limit() != 2 {
+		t.Errorf("Expected limit in use to grow, got %d", growing.Limit())
+	}
+}
+
+func TestNewServer_ConcurrencyLimit(t *testing.T) {
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Metrics:          grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()},
+		ConcurrencyLimit: grpc_utils.ConcurrencyLimitConfig{Limit: 1},
+		Logger:           discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if server.ConcurrencyLimiter == nil {
+		t.Fatal("Expected concurrency limiter to be created")
+	}
+	healthpb.RegisterHealthServer(server, panickingHealthServer{})
+	client := healthpb.NewHealthClient(startServer(t, server, grpc.WithTransportCredentials(insecure.NewCredentials())))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	// The open stream holds the only slot.
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected call to be shed while the stream is open, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package grpc_utils
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+)
+
+// MethodDeadline overrides the deadlines for methods matching a pattern.
+type MethodDeadline struct {
+	// Method is a full method name pattern, see MatchMethod.
+	Method string `mapstructure:"method"`
+	// Timeout applied when the client sent no deadline.
+	// Optional. Defaults to DeadlineConfig.Default.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Max caps client deadlines. Optional. Defaults to DeadlineConfig.Max.
+	Max time.Duration `mapstructure:"max"`
+}
+
+// DeadlineConfig holds configuration for the deadline interceptor.
+//
+// Example config:
+//
+//	grpc:
+//	  server:
+//	    deadlines:
+//	      default: 5s
+//	      max: 30s
+//	      methods:
+//	        - method: /reports.v1.ReportService/*
+//	          timeout: 60s
+//	          max: 120s
+type DeadlineConfig struct {
+	// Default is the timeout applied when the client sent no deadline.
+	// Optional. Calls without deadline are not limited when unset.
+	Default time.Duration `mapstructure:"default"`
+	// Max caps client deadlines that are further away. Optional.
+	Max time.Duration `mapstructure:"max"`
+	// Methods override the deadlines per method. The first match applies.
+	Methods []MethodDeadline `mapstructure:"methods"`
+}
+
+func (cfg DeadlineConfig) enabled() bool {
+	return cfg.Default > 0 || cfg.Max > 0 || len(cfg.Methods) > 0
+}
+
+func (cfg DeadlineConfig) forMethod(fullMethod string) (time.Duration, time.Duration) {
+	for _, m := range cfg.Methods {
+		if MatchMethod(m.Method, fullMethod) {
+			timeout, limit := m.Timeout, m.Max
+			if timeout <= 0 {
+				timeout = cfg.Default
+			}
+			if limit <= 0 {
+				limit = cfg.Max
+			}
+			return timeout, limit
+		}
+	}
+	return cfg.Default, cfg.Max
+}
+
+// applyDeadline returns ctx with the default deadline applied, or with the
+// client deadline capped. The returned timeout is zero if ctx was kept.
+func applyDeadline(
+	ctx context.Context,
+	cfg DeadlineConfig,
+	fullMethod string,
+) (context.Context, context.CancelFunc, time.Duration) {
+	timeout, limit := cfg.forMethod(fullMethod)
+	if deadline, ok := ctx.Deadline(); ok {
+		if limit > 0 && time.Until(deadline) > limit {
+			ctx, cancel := context.WithTimeout(ctx, limit)
+			return ctx, cancel, limit
+		}
+		return ctx, func() {}, 0
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, timeout
+	}
+	return ctx, func() {}, 0
+}
+
+func logDeadlineExceeded(ctx context.Context, fullMethod string, timeout time.Duration) {
+	if timeout == 0 || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	slog.WarnContext(ctx, "request cut off by deadline",
+		slog.String("method", fullMethod),
+		slog.Duration("timeout", timeout),
+	)
+}
+
+// Creates a gRPC interceptor that applies the configured timeout to unary
+// calls without deadline and caps client deadlines that exceed the maximum.
+// The deadline propagates to downstream calls made with the request context.
+func BuildDeadlineInterceptor(cfg DeadlineConfig) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, cancel, timeout := applyDeadline(ctx, cfg, info.FullMethod)
+		defer cancel()
+
+		resp, err := handler(ctx, req)
+		logDeadlineExceeded(ctx, info.FullMethod, timeout)
+		return resp, err
+	}
+}
+
+// Creates a gRPC interceptor that applies the configured timeout to streaming
+// calls without deadline and caps client deadlines that exceed the maximum.
+func BuildDeadlineStreamInterceptor(cfg DeadlineConfig) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, cancel, timeout := applyDeadline(ss.Context(), cfg, info.FullMethod)
+		defer cancel()
+
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		err := handler(srv, wrapped)
+		logDeadlineExceeded(ctx, info.FullMethod, timeout)
+		return err
+	}
+}
@@ -0,0 +1,104 @@
+package grpc_utils_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/grpc"
+)
+
+func deadlineOf(t *testing.T, cfg grpc_utils.DeadlineConfig, ctx context.Context, method string) (time.Duration, bool) {
+	t.Helper()
+	interceptor := grpc_utils.BuildDeadlineInterceptor(cfg)
+	var remaining time.Duration
+	var ok bool
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		var deadline time.Time
+		deadline, ok = ctx.Deadline()
+		remaining = time.Until(deadline)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor returned error: %v", err)
+	}
+	return remaining, ok
+}
+
+func TestDeadlineInterceptor(t *testing.T) {
+	cfg := grpc_utils.DeadlineConfig{
+		Default: 5 * time.Second,
+		Max:     30 * time.Second,
+		Methods: []grpc_utils.MethodDeadline{
+			{Method: "/reports.v1.ReportService/*", Timeout: time.Minute, Max: 2 * time.Minute},
+		},
+	}
+	within := func(d, want time.Duration) bool { return d <= want && d > want-time.Second }
+
+	if d, ok := deadlineOf(t, cfg, context.Background(), "/orders.v1.OrderService/Get"); !ok || !within(d, 5*time.Second) {
+		t.Errorf("Expected default deadline of 5s, got %v (set=%v)", d, ok)
+	}
+	if d, ok := deadlineOf(t, cfg, context.Background(), "/reports.v1.ReportService/Build"); !ok || !within(d, time.Minute) {
+		t.Errorf("Expected method deadline of 1m, got %v (set=%v)", d, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if d, _ := deadlineOf(t, cfg, ctx, "/orders.v1.OrderService/Get"); !within(d, 30*time.Second) {
+		t.Errorf("Expected client deadline to be capped at 30s, got %v", d)
+	}
+	if d, _ := deadlineOf(t, cfg, ctx, "/reports.v1.ReportService/Build"); !within(d, 2*time.Minute) {
+		t.Errorf("Expected client deadline to be capped at the method max, got %v", d)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelShort()
+	if d, _ := deadlineOf(t, cfg, short, "/orders.v1.OrderService/Get"); !within(d, 2*time.Second) {
+		t.Errorf("Expected shorter client deadline to be kept, got %v", d)
+	}
+
+	if _, ok := deadlineOf(t, grpc_utils.DeadlineConfig{}, context.Background(), "/orders.v1.OrderService/Get"); ok {
+		t.Error("Expected no deadline without configuration")
+	}
+}
+
+func TestDeadlineInterceptor_LogsCutOff(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+
+	interceptor := grpc_utils.BuildDeadlineInterceptor(grpc_utils.DeadlineConfig{Default: 20 * time.Millisecond})
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/Slow"}
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err == nil {
+		t.Fatal("Expected handler to fail once the deadline passed")
+	}
+	if output := buf.String(); !strings.Contains(output, "request cut off by deadline") ||
+		!strings.Contains(output, "/orders.v1.OrderService/Slow") {
+		t.Errorf("Expected cut off to be logged, got %q", output)
+	}
+}
+
+func TestDeadlineStreamInterceptor(t *testing.T) {
+	interceptor := grpc_utils.BuildDeadlineStreamInterceptor(grpc_utils.DeadlineConfig{Default: time.Second})
+	stream := &recvServerStream{}
+	info := &grpc.StreamServerInfo{FullMethod: "/orders.v1.OrderService/Watch", IsServerStream: true}
+
+	err := interceptor(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+		if _, ok := ss.Context().Deadline(); !ok {
+			t.Error("Expected stream context to have a deadline")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor returned error: %v", err)
+	}
+}
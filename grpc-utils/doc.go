@@ -3,9 +3,30 @@
 //
 // # Interceptors
 //
-// This package provides two main interceptors:
+// This package provides the following interceptors:
 //   - BuildLogInterceptor: Structured logging for gRPC requests
 //   - BuildRequestIDInterceptor: Request ID generation and propagation
+//   - Authenticator: JWT bearer token authentication
+//   - BuildAuthzInterceptor: Method-level role and scope authorization
+//   - BuildTenantInterceptor: Tenant resolution from claims, metadata or host
+//   - RateLimiter: Distributed per-method and per-caller rate limiting
+//   - ServerMetrics, ClientMetrics: Prometheus RED metrics
+//   - TracingServerOption, TracingDialOption: OpenTelemetry tracing
+//   - BuildErrorInterceptor: Error translation to gRPC statuses
+//   - BuildValidationInterceptor: Request validation with field violations
+//   - BuildRecoveryInterceptor: Panic recovery returning codes.Internal
+//   - BuildDeadlineInterceptor: Default and maximum call deadlines
+//   - Idempotency: Redis-backed replay of retried calls
+//   - ResponseCache: Opt-in caching of read-only responses
+//   - Auditor: Asynchronous audit trail of mutating calls
+//   - DrainController: Graceful draining of calls in flight on shutdown
+//   - BuildPayloadLogInterceptor: Opt-in request and response payload logging
+//   - ConcurrencyLimiter: Static or adaptive in-flight limit with load shedding
+//   - CircuitBreaker: Per-target client circuit breaking
+//   - Retrier: Client retries of idempotent methods with jittered backoff
+//
+// NewServer assembles a server with the common interceptors from config, and
+// NewClientConn does the same for client connections.
 //
 // # Log Interceptor
 //
@@ -30,6 +51,21 @@
 //   - Response status
 //   - Any errors
 //
+// # Payload Logging
+//
+// BuildPayloadLogInterceptor logs request and response payloads of selected
+// methods as JSON for debugging. Payloads are truncated, a fraction of calls
+// can be sampled, and fields listed by name or marked with the debug_redact
+// proto option are redacted, also inside google.protobuf.Any values such as
+// error details:
+//
+//	payloadLog := grpc_utils.BuildPayloadLogInterceptor(grpc_utils.PayloadLogConfig{
+//	    Methods:      []string{"/orders.v1.OrderService/*"},
+//	    MaxSize:      2048,
+//	    SampleRate:   0.1,
+//	    RedactFields: []string{"password", "card_number"},
+//	})
+//
 // # Request ID Interceptor
 //
 // The request ID interceptor ensures every request has a unique ID:
@@ -42,12 +78,499 @@
 //
 // Request ID handling:
 //   - Checks incoming metadata for existing x-request-id
-//   - Generates a new UUID if not present
+//   - Falls back to the trace ID of a W3C traceparent header
+//   - Generates a new UUID if neither is present
 //   - Adds request_id to log context via app.WithLogAttrs
 //   - Returns x-request-id in response headers
 //
 // This enables request tracing across service boundaries.
 //
+// The header name and traceparent handling can be configured:
+//
+//	grpc_utils.BuildRequestIDInterceptorWithConfig(grpc_utils.RequestIDConfig{
+//	    Header:            "x-correlation-id",
+//	    IgnoreTraceParent: true,
+//	})
+//
+// Handlers can read the request ID from the context:
+//
+//	requestID, ok := grpc_utils.RequestIDFromContext(ctx)
+//
+// # Authentication
+//
+// The Authenticator validates bearer JWTs (HS256, RS256, ES256) sent in the
+// authorization metadata against a shared secret, a PEM public key or a JWKS
+// document loaded from a file or URL:
+//
+//	authenticator, err := grpc_utils.NewAuthenticator(grpc_utils.AuthConfig{
+//	    JWKSURL:       "https://issuer.example.com/.well-known/jwks.json",
+//	    Issuer:        "https://issuer.example.com/",
+//	    Audience:      []string{"my-service"},
+//	    PublicMethods: []string{"/grpc.health.v1.Health/*"},
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(
+//	        grpc_utils.BuildRequestIDInterceptor(),
+//	        authenticator.UnaryServerInterceptor(),
+//	    ),
+//	    grpc.ChainStreamInterceptor(authenticator.StreamServerInterceptor()),
+//	)
+//
+// Tokens must not be expired and must match the configured issuer and
+// audience. Invalid or missing tokens are rejected with codes.Unauthenticated,
+// except for methods listed in PublicMethods. The JWKS document is cached and
+// reloaded after JWKSCacheTTL or when a token references an unknown key ID.
+//
+// Validated claims are available to handlers, and the token subject is added
+// to the log context as user_id:
+//
+//	claims, ok := grpc_utils.ClaimsFromContext(ctx)
+//	if ok && claims.HasRole("admin") {
+//	    // ...
+//	}
+//
+// # Authorization
+//
+// The authorization interceptor maps full method names to the roles and
+// scopes required to call them, evaluated against the claims stored by the
+// Authenticator. It must run after the authentication interceptor:
+//
+//	var authzConfig grpc_utils.AuthzConfig
+//	if err := app.Config().UnmarshalKey("grpc.authz", &authzConfig); err != nil {
+//	    panic(err)
+//	}
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(
+//	        authenticator.UnaryServerInterceptor(),
+//	        grpc_utils.BuildAuthzInterceptor(authzConfig),
+//	    ),
+//	)
+//
+// Rules are evaluated in order and the first match applies. A caller needs
+// one of the listed roles and all of the listed scopes. Rejected calls return
+// codes.PermissionDenied and are recorded with an "authorization denied"
+// warning carrying the method, subject and missing requirement.
+//
+// # Multi-Tenancy
+//
+// The tenant interceptor resolves the tenant of each call from a JWT claim,
+// a metadata header or the subdomain of the host, tried in the configured
+// order, and stores it with app.WithTenant so that it appears in every log
+// record. It must run after the authentication interceptor when the claims
+// source is used:
+//
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(
+//	        authenticator.UnaryServerInterceptor(),
+//	        grpc_utils.BuildTenantInterceptor(grpc_utils.TenantConfig{
+//	            HostSuffix: ".api.example.com",
+//	            Required:   true,
+//	        }),
+//	    ),
+//	)
+//
+// Calls whose tenant is missing or malformed return codes.InvalidArgument,
+// and calls naming a tenant other than the one in the token, or outside the
+// configured Tenants, return codes.PermissionDenied. An authenticated caller
+// whose token has no tenant claim could otherwise pick any tenant through the
+// header or host, so such calls are rejected with codes.PermissionDenied too
+// unless AllowTokensWithoutTenant is set. Handlers read the tenant
+// with app.TenantFromContext, or pass the context to gorm_client.TenantScope,
+// mongo_client.TenantDatabase and object_storage.TenantKey.
+//
+// # Rate Limiting
+//
+// The RateLimiter enforces per-method limits counted per caller (user, API key
+// or peer IP) using the generic cell rate algorithm. The methods matching a
+// limit pattern share its budget. Limits are stored in Redis so they hold
+// across replicas, and fall back to in-memory limits when Redis is not
+// configured or unavailable:
+//
+//	var cfg grpc_utils.RateLimitConfig
+//	if err := app.Config().UnmarshalKey("grpc.ratelimit", &cfg); err != nil {
+//	    panic(err)
+//	}
+//	cfg.Redis = redis_client.NewRDB(redis_client.Config{
+//	    Urls: []string{"localhost:6379"},
+//	})
+//	limiter := grpc_utils.NewRateLimiter(cfg)
+//
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(
+//	        authenticator.UnaryServerInterceptor(),
+//	        limiter.UnaryServerInterceptor(),
+//	    ),
+//	)
+//
+// Calls over the limit are rejected with codes.ResourceExhausted, a
+// retry-after response header in seconds and an errdetails.RetryInfo detail.
+// Place the limiter after the Authenticator so limits can be counted per user.
+//
+// # Metrics
+//
+// ServerMetrics and ClientMetrics record Prometheus metrics per service and
+// method: completed requests and errors by status code, a latency histogram
+// and an in-flight gauge. Server metrics are named grpc_server_* and client
+// metrics grpc_client_*, optionally prefixed with MetricsConfig.Namespace:
+//
+//	registry := prometheus.NewRegistry()
+//	metrics := grpc_utils.NewServerMetrics(grpc_utils.MetricsConfig{
+//	    Registry: registry,
+//	})
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor()),
+//	    grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor()),
+//	)
+//
+//	// Expose /metrics over HTTP alongside the gRPC server
+//	metricsServer := grpc_utils.NewMetricsServer(":9090", registry)
+//	go func() { _ = metricsServer.ListenAndServe() }()
+//
+// If no registry is configured the metrics are registered on the default
+// Prometheus registry.
+//
+// # Tracing
+//
+// TracingServerOption and TracingDialOption install OpenTelemetry stats
+// handlers that create server and client spans, propagate the W3C trace
+// context through metadata and record the status code:
+//
+//	tracingConfig := grpc_utils.TracingConfig{
+//	    IgnoredMethods: []string{"/grpc.health.v1.Health/*"},
+//	}
+//	server := grpc.NewServer(grpc_utils.TracingServerOption(tracingConfig))
+//	conn, err := grpc.NewClient(target, grpc_utils.TracingDialOption(tracingConfig))
+//
+// Spans are exported by the tracer provider app.Init sets up from the otel
+// config section. Logs written with the request context include trace_id and
+// span_id, and the request ID interceptor reuses the trace ID as request ID
+// when the client sends no x-request-id.
+//
+// # Error Translation
+//
+// BuildErrorInterceptor converts errors returned by handlers into gRPC
+// statuses. Known errors of the storage clients are translated, e.g.
+// gorm.ErrRecordNotFound, mongo.ErrNoDocuments, redis.Nil and missing objects
+// become codes.NotFound, duplicate keys become codes.AlreadyExists and timeouts
+// become codes.DeadlineExceeded. Unknown errors become codes.Internal with a
+// generic message, and the original error is only logged:
+//
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(
+//	        grpc_utils.BuildErrorInterceptor(grpc_utils.ErrorConfig{Domain: "orders"}),
+//	    ),
+//	)
+//
+// Handlers return domain errors with NewError, which keeps the code, message
+// and details while hiding the cause from clients:
+//
+//	return nil, grpc_utils.NewError(codes.FailedPrecondition, "order already shipped").
+//	    WithCause(err)
+//
+// # Request Validation
+//
+// BuildValidationInterceptor calls ValidateAll, or Validate, on requests that
+// implement it, as generated by protoc-gen-validate. Failures are rejected
+// with codes.InvalidArgument and an errdetails.BadRequest listing the field
+// violations, using dotted paths for nested messages:
+//
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(
+//	        grpc_utils.BuildValidationInterceptor(grpc_utils.ValidationConfig{}),
+//	    ),
+//	    grpc.ChainStreamInterceptor(
+//	        grpc_utils.BuildValidationStreamInterceptor(grpc_utils.ValidationConfig{}),
+//	    ),
+//	)
+//
+// Messages without a validation method can be checked with a custom
+// ValidationConfig.Validator, e.g. backed by protovalidate.
+//
+// # Server Builder
+//
+// NewServer creates a server from a config section, wiring the request ID,
+// metrics, logging and recovery interceptors in that order for unary and
+// streaming calls. TLS, mutual TLS, keepalive, message sizes and reflection
+// are configured from the same section:
+//
+//	grpc:
+//	  server:
+//	    address: :50051
+//	    reflection: true
+//	    shutdown_timeout: 15s
+//	    tls:
+//	      cert_file: /etc/tls/tls.crt
+//	      key_file: /etc/tls/tls.key
+//
+//	var cfg grpc_utils.ServerConfig
+//	if err := app.Config().UnmarshalKey("grpc.server", &cfg); err != nil {
+//	    panic(err)
+//	}
+//	cfg.UnaryInterceptors = []grpc.UnaryServerInterceptor{
+//	    authenticator.UnaryServerInterceptor(),
+//	}
+//	server, err := grpc_utils.NewServer(cfg)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	pb.RegisterYourServiceServer(server, &yourService{})
+//	go func() {
+//	    if err := server.ListenAndServe(); err != nil {
+//	        slog.Error("server stopped", "error", err)
+//	    }
+//	}()
+//
+//	<-ctx.Done()
+//	_ = server.Shutdown(context.Background())
+//
+// Shutdown waits for pending RPCs up to shutdown_timeout and cancels the
+// remaining ones afterwards, see Graceful Shutdown.
+//
+// # Graceful Shutdown
+//
+// Every server built by NewServer has a DrainController that counts the
+// calls in flight. Server.Drain, which Shutdown calls, drains the server in
+// steps so rollouts do not fail calls:
+//
+//  1. The health checker reports NOT_SERVING.
+//  2. Calls are still served for the grace period, while load balancers
+//     notice the status change.
+//  3. New calls are rejected with a retryable codes.Unavailable, and GOAWAY
+//     is sent so clients reconnect to other replicas.
+//  4. Calls in flight are awaited up to the drain timeout, then canceled.
+//
+// Health checks are still served while draining. The returned DrainReport
+// counts the rejected calls and the unary and streaming calls abandoned at
+// the timeout:
+//
+//	grpc:
+//	  server:
+//	    drain:
+//	      grace_period: 5s
+//	      timeout: 30s
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+//	defer stop()
+//	<-ctx.Done()
+//	report, err := server.Drain(context.Background())
+//	if err != nil {
+//	    slog.Warn("calls abandoned", "unary", report.AbandonedUnary, "streams", report.AbandonedStreams)
+//	}
+//
+// The grace period should exceed the readiness probe period, and the drain
+// timeout should fit within the pod termination grace period.
+//
+// # Health Checks
+//
+// HealthChecker serves the standard grpc.health.v1 service for Kubernetes
+// probes. Statuses are driven by component checks that run periodically with
+// a timeout; the overall status requires all checks to pass, and a named
+// service only the checks registered for it:
+//
+//	checker := grpc_utils.NewHealthChecker(grpc_utils.HealthConfig{
+//	    Interval: 10 * time.Second,
+//	    Timeout:  2 * time.Second,
+//	})
+//	checker.AddCheck("postgres", grpc_utils.GormHealthCheck(db), "orders.v1.OrderService")
+//	checker.AddCheck("redis", grpc_utils.RedisHealthCheck(rdb))
+//	checker.AddCheck("mongo", grpc_utils.MongoHealthCheck(mongoClient))
+//	checker.AddCheck("storage", grpc_utils.ObjectStorageHealthCheck(storage, ".health"))
+//	checker.Start(ctx)
+//
+//	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{HealthChecker: checker})
+//
+// Server.Shutdown sets every service to NOT_SERVING before draining, so
+// readiness probes fail while pending RPCs complete.
+//
+// # Deadlines
+//
+// BuildDeadlineInterceptor applies a default timeout to calls sent without a
+// deadline and caps client deadlines that are too far away, so downstream
+// database and redis calls made with the request context cannot hang:
+//
+//	deadlines := grpc_utils.DeadlineConfig{
+//	    Default: 5 * time.Second,
+//	    Max:     30 * time.Second,
+//	    Methods: []grpc_utils.MethodDeadline{
+//	        {Method: "/reports.v1.ReportService/*", Timeout: time.Minute},
+//	    },
+//	}
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(grpc_utils.BuildDeadlineInterceptor(deadlines)),
+//	)
+//
+// Calls cut off by an applied deadline are logged as warnings. NewServer
+// installs the interceptor when the deadlines config section is set.
+//
+// # Load Shedding
+//
+// ConcurrencyLimiter bounds the calls in flight and sheds the excess with
+// codes.Unavailable and a RetryInfo detail, instead of queueing work until
+// the server runs out of memory. With Adaptive set the limit follows AIMD,
+// shrinking when calls exceed TargetLatency or their deadline. Methods are
+// prioritized by criticality: sheddable calls may use 70% of the limit,
+// default calls 90% and critical calls all of it:
+//
+//	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+//	    ConcurrencyLimit: grpc_utils.ConcurrencyLimitConfig{
+//	        Limit:         200,
+//	        Adaptive:      true,
+//	        TargetLatency: 250 * time.Millisecond,
+//	        Methods: []grpc_utils.MethodCriticality{
+//	            {Method: "/grpc.health.v1.Health/*", Criticality: grpc_utils.CriticalityCritical},
+//	            {Method: "/reports.v1.ReportService/*", Criticality: grpc_utils.CriticalitySheddable},
+//	        },
+//	    },
+//	})
+//
+// # Idempotency Keys
+//
+// Idempotency executes mutating calls once per idempotency-key metadata value
+// and replays the stored response or status for retries. The result is kept
+// in Redis, so duplicates are detected across replicas:
+//
+//	idempotency, err := grpc_utils.NewIdempotency(grpc_utils.IdempotencyConfig{
+//	    Methods: []string{"/orders.v1.OrderService/Create*"},
+//	    TTL:     24 * time.Hour,
+//	    Redis:   rdb,
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(idempotency.UnaryServerInterceptor()),
+//	)
+//
+// A duplicate sent while the first call is still running fails with
+// codes.Aborted, and reusing a key for a different request fails with
+// codes.InvalidArgument. Keys are scoped to the authenticated caller, and
+// transient failures are not stored so the call can be retried. The
+// in-progress lock is refreshed while the handler runs, so LockTTL only
+// bounds how long a crashed replica blocks the key.
+//
+// # Response Caching
+//
+// ResponseCache serves repeated calls of read-heavy methods from a
+// redis_client.Cache. Responses are keyed on the method, the serialized
+// request, the tenant and the configured metadata, and kept for the TTL of
+// the first matching method. TTLs below the LocalCacheTTL of the cache skip
+// its in-process layer:
+//
+//	responseCache, err := grpc_utils.NewResponseCache(grpc_utils.ResponseCacheConfig{
+//	    Methods: []grpc_utils.CachedMethod{
+//	        {Method: "/catalog.v1.CatalogService/ListProducts", TTL: 5 * time.Minute},
+//	    },
+//	    MetadataKeys: []string{"accept-language"},
+//	    Cache:        redis_client.NewCache(redis_client.CacheConfig{Redis: rdb}),
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(responseCache.UnaryServerInterceptor()),
+//	)
+//
+// Only successful responses are cached. Handlers that change cached data call
+// Invalidate, which also publishes on the refresh event channel of the cache
+// so that every replica drops its local copy.
+//
+// # Audit Trail
+//
+// Auditor records the actor, tenant, method, request ID, status code, latency
+// and a redacted request summary of the configured calls. Records are queued
+// and written in batches by a background goroutine, so a slow sink does not
+// delay responses; records are dropped, and counted, while the queue is full:
+//
+//	sink := grpc_utils.NewGormAuditSink(db, "audit_records")
+//	if err := sink.AutoMigrate(); err != nil {
+//	    panic(err)
+//	}
+//	auditor, err := grpc_utils.NewAuditor(grpc_utils.AuditConfig{
+//	    Methods:      []string{"/orders.v1.OrderService/Create*"},
+//	    RedactFields: []string{"card_number"},
+//	    Sink:         sink,
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = auditor.Close(context.Background()) }()
+//
+// NewMongoAuditSink writes to a MongoDB collection and NewLogAuditSink to a
+// logger. Other stores implement AuditSink. The audit interceptor must run
+// after the authentication, tenant and request ID interceptors.
+//
+// # Client Connections
+//
+// NewClientConn dials another service from a config section with TLS, a
+// default call timeout, retry and load balancing policy, keepalive and the
+// request ID, metrics and logging client interceptors. The request ID of the
+// incoming call is propagated to outgoing calls. ClientRegistry creates named
+// connections on first use and shares them across the process:
+//
+//	clients, err := grpc_utils.LoadClientRegistry("grpc.clients")
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer clients.Close()
+//	conn, err := clients.Conn("orders")
+//	if err != nil {
+//	    panic(err)
+//	}
+//	orders := pb.NewOrderServiceClient(conn)
+//
+// # Circuit Breaking and Retries
+//
+// CircuitBreaker keeps a closed, open or half-open breaker per target. A
+// breaker opens when the failure rate within a window reaches the threshold,
+// failing calls fast with codes.Unavailable wrapping ErrCircuitOpen, and
+// lets probe calls through after the open timeout. State changes are logged
+// and exported as the grpc_client_circuit_breaker_state and
+// grpc_client_circuit_breaker_transitions_total metrics.
+//
+// Retrier retries idempotent methods, and calls carrying an idempotency key in
+// RetryConfig.IdempotencyHeader, with jittered exponential backoff. A
+// RetryInfo delay sent by the server, e.g. by RateLimiter, is honored.
+// NewClientConn chains both when "retry" and "circuit_breaker" are selected
+// in ClientConfig.Interceptors, with the retries before the breaker so every
+// attempt is counted and an open breaker stops the retries:
+//
+//	grpc:
+//	  clients:
+//	    orders:
+//	      target: dns:///orders:50051
+//	      interceptors: [request_id, metrics, logging, retry, circuit_breaker]
+//	      retries:
+//	        methods:
+//	          - /orders.v1.OrderService/Get*
+//	      circuit_breaker:
+//	        failure_rate: 0.5
+//
+// ClientConfig.Retry enables the transparent retries of gRPC instead, which
+// apply to every method but ignore RetryInfo. Do not combine it with the
+// retry interceptor: each interceptor attempt is retried again by gRPC, so the
+// attempts multiply, e.g. 3 x 3 = 9 calls.
+//
+// # HTTP/JSON Gateway
+//
+// NewGateway mounts grpc-gateway handlers on an http.Server that forwards
+// calls to the gRPC server. The x-request-id, Authorization, traceparent and
+// configured headers are forwarded into the call metadata, so the server
+// interceptors log, measure and authenticate gateway calls like any other
+// call. Errors are written as JSON statuses with the HTTP code matching the
+// gRPC code:
+//
+//	gateway, err := grpc_utils.NewGateway(ctx, grpc_utils.GatewayConfig{
+//	    Address:  ":8080",
+//	    Endpoint: "localhost:50051",
+//	}, pb.RegisterOrderServiceHandler)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	go func() { _ = gateway.ListenAndServe() }()
+//
 // # Combined Usage
 //
 // Typically, both interceptors are used together:
@@ -0,0 +1,255 @@
+package grpc_utils
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const defaultDrainTimeout = 30 * time.Second
+
+// errDraining returns the error for calls arriving while the server drains,
+// so clients retry them on another replica.
+func errDraining() *Error {
+	return &Error{Code: codes.Unavailable, Message: "server is shutting down", Retryable: true}
+}
+
+// DefaultDrainExemptMethods lists the methods still served while draining
+// when DrainConfig does not configure any, so load balancers can observe the
+// NOT_SERVING health status.
+var DefaultDrainExemptMethods = []string{"/grpc.health.v1.Health/*"}
+
+// DrainConfig holds configuration for the drain controller.
+//
+// Example config:
+//
+//	grpc:
+//	  server:
+//	    drain:
+//	      grace_period: 5s
+//	      timeout: 30s
+type DrainConfig struct {
+	// GracePeriod is how long the server keeps accepting calls after
+	// reporting NOT_SERVING, so load balancers stop routing to it first.
+	// Optional. Calls are rejected right away when unset.
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+	// Timeout bounds the wait for calls in flight once new calls are
+	// rejected. Remaining calls are then canceled. Optional. Defaults to 30s.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// ExemptMethods lists the full method name patterns still served while
+	// draining, see MatchMethod. Optional. Defaults to
+	// DefaultDrainExemptMethods.
+	ExemptMethods []string `mapstructure:"exempt_methods"`
+
+	// HealthChecker is set to NOT_SERVING when draining starts. Optional.
+	HealthChecker *HealthChecker `mapstructure:"-"`
+	// Logger logs the drain progress. Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+}
+
+// DrainReport summarizes a drain.
+type DrainReport struct {
+	// Rejected is the number of calls rejected while draining.
+	Rejected int
+	// AbandonedUnary is the number of unary calls still in flight when the
+	// timeout elapsed.
+	AbandonedUnary int
+	// AbandonedStreams is the number of streaming calls still in flight
+	// when the timeout elapsed.
+	AbandonedStreams int
+	// Duration is how long the drain took.
+	Duration time.Duration
+}
+
+// DrainController tracks calls in flight and drains a server on shutdown.
+// Its interceptors must be chained on the drained server.
+type DrainController struct {
+	cfg DrainConfig
+
+	mu        sync.Mutex
+	rejecting bool
+	unary     int
+	streams   int
+	rejected  int
+}
+
+// NewDrainController creates a drain controller.
+//
+// Example:
+//
+//	drain := grpc_utils.NewDrainController(grpc_utils.DrainConfig{
+//	    GracePeriod:   5 * time.Second,
+//	    HealthChecker: checker,
+//	})
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(drain.UnaryServerInterceptor()),
+//	    grpc.ChainStreamInterceptor(drain.StreamServerInterceptor()),
+//	)
+func NewDrainController(cfg DrainConfig) *DrainController {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultDrainTimeout
+	}
+	if len(cfg.ExemptMethods) == 0 {
+		cfg.ExemptMethods = DefaultDrainExemptMethods
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &DrainController{cfg: cfg}
+}
+
+// InFlight returns the number of unary and streaming calls in flight.
+func (d *DrainController) InFlight() (unary, streams int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.unary, d.streams
+}
+
+// Draining reports whether new calls are rejected.
+func (d *DrainController) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rejecting
+}
+
+// acquire admits a call to fullMethod, returning errDraining() if new calls
+// are rejected.
+func (d *DrainController) acquire(fullMethod string, stream bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rejecting && !matchAnyMethod(d.cfg.ExemptMethods, fullMethod) {
+		d.rejected++
+		return errDraining()
+	}
+	if stream {
+		d.streams++
+	} else {
+		d.unary++
+	}
+	return nil
+}
+
+func (d *DrainController) release(stream bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stream {
+		d.streams--
+	} else {
+		d.unary--
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor that counts unary calls in
+// flight and rejects new calls with codes.Unavailable while draining.
+func (d *DrainController) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := d.acquire(info.FullMethod, false); err != nil {
+			return nil, err
+		}
+		defer d.release(false)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that counts streaming calls
+// in flight and rejects new calls with codes.Unavailable while draining.
+func (d *DrainController) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := d.acquire(info.FullMethod, true); err != nil {
+			return err
+		}
+		defer d.release(true)
+		return handler(srv, ss)
+	}
+}
+
+// Drain stops server gracefully:
+//
+//  1. The health checker reports NOT_SERVING.
+//  2. Calls are still served for GracePeriod, so load balancers notice.
+//  3. New calls are rejected with codes.Unavailable, and GOAWAY is sent to
+//     clients so they reconnect elsewhere.
+//  4. Calls in flight are awaited for up to Timeout, then canceled.
+//
+// It returns the report with the abandoned calls, and the context error if
+// calls were canceled because ctx was done or Timeout elapsed.
+//
+// Example:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+//	defer stop()
+//	<-ctx.Done()
+//	report, err := drain.Drain(context.Background(), server)
+func (d *DrainController) Drain(ctx context.Context, server *grpc.Server) (DrainReport, error) {
+	start := time.Now()
+	d.cfg.Logger.InfoContext(ctx, "drain started", slog.Duration("grace_period", d.cfg.GracePeriod))
+	if d.cfg.HealthChecker != nil {
+		d.cfg.HealthChecker.Shutdown()
+	}
+
+	if d.cfg.GracePeriod > 0 {
+		timer := time.NewTimer(d.cfg.GracePeriod)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	d.mu.Lock()
+	d.rejecting = true
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		// GracefulStop sends GOAWAY, closes the listeners and waits for the
+		// calls in flight.
+		server.GracefulStop()
+		close(done)
+	}()
+
+	var report DrainReport
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		report.AbandonedUnary, report.AbandonedStreams = d.InFlight()
+		err = ctx.Err()
+		server.Stop()
+		<-done
+	}
+
+	d.mu.Lock()
+	report.Rejected = d.rejected
+	d.mu.Unlock()
+	report.Duration = time.Since(start)
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	d.cfg.Logger.LogAttrs(ctx, level, "drain finished",
+		slog.Int("rejected", report.Rejected),
+		slog.Int("abandoned_unary", report.AbandonedUnary),
+		slog.Int("abandoned_streams", report.AbandonedStreams),
+		slog.Duration("duration", report.Duration),
+	)
+	return report, err
+}
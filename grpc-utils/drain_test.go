@@ -0,0 +1,177 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// blockingInterceptor holds calls sent with the "block" metadata key until
+// release is closed or the call is canceled.
+func blockingInterceptor(release <-chan struct{}) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("block")) > 0 {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServer_Drain(t *testing.T) {
+	checker := grpc_utils.NewHealthChecker(grpc_utils.HealthConfig{})
+	checker.CheckNow(context.Background())
+	release := make(chan struct{})
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Interceptors:      []string{grpc_utils.InterceptorRecovery},
+		Logger:            discardLogger,
+		HealthChecker:     checker,
+		Drain:             grpc_utils.DrainConfig{GracePeriod: 300 * time.Millisecond, Timeout: 2 * time.Second},
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{blockingInterceptor(release)},
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	client := healthpb.NewHealthClient(startServer(t, server, grpc.WithTransportCredentials(insecure.NewCredentials())))
+
+	pending := make(chan error, 1)
+	go func() {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "block", "1")
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		pending <- err
+	}()
+	waitFor(t, "pending call", func() bool {
+		unary, _ := server.DrainController.InFlight()
+		return unary == 1
+	})
+
+	reports := make(chan grpc_utils.DrainReport, 1)
+	go func() {
+		report, err := server.Drain(context.Background())
+		if err != nil {
+			t.Errorf("Expected drain to finish in time, got %v", err)
+		}
+		reports <- report
+	}()
+
+	// Calls are still served during the grace period, reporting NOT_SERVING
+	waitFor(t, "NOT_SERVING", func() bool {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_NOT_SERVING
+	})
+	if server.DrainController.Draining() {
+		t.Error("Expected calls to be accepted during the grace period")
+	}
+
+	waitFor(t, "draining", server.DrainController.Draining)
+	close(release)
+	if err := <-pending; err != nil {
+		t.Errorf("Expected pending call to complete, got %v", err)
+	}
+
+	report := <-reports
+	if report.AbandonedUnary != 0 || report.AbandonedStreams != 0 {
+		t.Errorf("Expected no abandoned calls, got %+v", report)
+	}
+	if report.Duration < 300*time.Millisecond {
+		t.Errorf("Expected drain to wait for the grace period, took %v", report.Duration)
+	}
+}
+
+func TestServer_DrainTimeout(t *testing.T) {
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Interceptors:      []string{grpc_utils.InterceptorRecovery},
+		Logger:            discardLogger,
+		Drain:             grpc_utils.DrainConfig{Timeout: 100 * time.Millisecond},
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{blockingInterceptor(nil)},
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	healthpb.RegisterHealthServer(server, panickingHealthServer{})
+	client := healthpb.NewHealthClient(startServer(t, server, grpc.WithTransportCredentials(insecure.NewCredentials())))
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	pending := make(chan error, 1)
+	go func() {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "block", "1")
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		pending <- err
+	}()
+	waitFor(t, "pending calls", func() bool {
+		unary, streams := server.DrainController.InFlight()
+		return unary == 1 && streams == 1
+	})
+
+	report, err := server.Drain(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected drain to hit the deadline, got %v", err)
+	}
+	if report.AbandonedUnary != 1 || report.AbandonedStreams != 1 {
+		t.Errorf("Expected one abandoned unary and stream call, got %+v", report)
+	}
+	if err := <-pending; err == nil {
+		t.Error("Expected abandoned call to be canceled")
+	}
+}
+
+func TestDrainController_RejectsNewCalls(t *testing.T) {
+	drain := grpc_utils.NewDrainController(grpc_utils.DrainConfig{Logger: discardLogger})
+	if _, err := drain.Drain(context.Background(), grpc.NewServer()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	called := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return nil, nil
+	}
+	unary := drain.UnaryServerInterceptor()
+	_, err := unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/GetOrder"}, handler)
+	st := status.Convert(err)
+	if st.Code() != codes.Unavailable || !hasRetryInfo(st) || called {
+		t.Errorf("Expected retryable Unavailable while draining, got %v (handler called: %v)", err, called)
+	}
+
+	_, err = unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	if err != nil || !called {
+		t.Errorf("Expected health checks to be served while draining, got %v", err)
+	}
+
+	stream := drain.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/orders.v1.OrderService/Watch", IsServerStream: true}
+	err = stream(nil, &ctxServerStream{ctx: context.Background()}, info,
+		func(srv any, ss grpc.ServerStream) error { return nil })
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable for streams while draining, got %v", err)
+	}
+}
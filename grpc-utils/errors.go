@@ -0,0 +1,233 @@
+package grpc_utils
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+
+	object_storage "github.com/poly-workshop/go-webmods/object-storage"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"gorm.io/gorm"
+)
+
+// Error is a structured error carrying the gRPC status returned to clients.
+// Handlers can return it directly, and the error interceptor converts known
+// errors from the storage clients into it.
+//
+// Message and Details are sent to the client, while Cause is only logged.
+type Error struct {
+	// Code is the gRPC status code returned to the client.
+	Code codes.Code
+	// Message is the client facing error message.
+	Message string
+	// Details are attached to the status, e.g. errdetails payloads.
+	Details []protoadapt.MessageV1
+	// Retryable marks the call as safe to retry. A RetryInfo detail is
+	// attached to the status.
+	Retryable bool
+	// Cause is the internal error that is logged but hidden from clients.
+	Cause error
+}
+
+// NewError creates a new error with the given code and client facing message.
+//
+// Example:
+//
+//	return nil, grpc_utils.NewError(codes.FailedPrecondition, "order already shipped").
+//	    WithDetails(&errdetails.PreconditionFailure{...}).
+//	    WithCause(err)
+func NewError(code codes.Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// WithDetails attaches details to the status returned to the client.
+func (e *Error) WithDetails(details ...protoadapt.MessageV1) *Error {
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+// WithCause records the internal error that caused e.
+func (e *Error) WithCause(err error) *Error {
+	e.Cause = err
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// GRPCStatus returns the status sent to the client, without the cause.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+	if e.Code == codes.OK {
+		return st
+	}
+
+	details := e.Details
+	if e.Retryable {
+		details = append(details[:len(details):len(details)], &errdetails.RetryInfo{})
+	}
+	if len(details) == 0 {
+		return st
+	}
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// ErrorMapper converts an error into an Error, or returns nil if it does not
+// recognize the error.
+type ErrorMapper func(err error) *Error
+
+// ErrorConfig holds configuration for the error interceptor.
+type ErrorConfig struct {
+	// Domain is set on the errdetails.ErrorInfo attached to translated
+	// errors, typically the service name. Optional.
+	Domain string `mapstructure:"domain"`
+	// Mappers are tried in order before the built-in translations.
+	Mappers []ErrorMapper `mapstructure:"-"`
+}
+
+// TranslateError converts err into an Error with the status code matching
+// known errors of the gorm, mongo, redis and object storage clients. Errors
+// that already carry a gRPC status keep it, and unknown errors become
+// codes.Internal with a generic message.
+func TranslateError(cfg ErrorConfig, err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr
+	}
+	for _, mapper := range cfg.Mappers {
+		if mapped := mapper(err); mapped != nil {
+			if mapped.Cause == nil {
+				mapped.Cause = err
+			}
+			return mapped
+		}
+	}
+	if st, ok := status.FromError(err); ok {
+		return &Error{Code: st.Code(), Message: st.Message(), Details: statusDetails(st)}
+	}
+
+	translated := func(code codes.Code, message, reason string, retryable bool) *Error {
+		return &Error{
+			Code:      code,
+			Message:   message,
+			Details:   []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: reason, Domain: cfg.Domain}},
+			Retryable: retryable,
+			Cause:     err,
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return &Error{Code: codes.Canceled, Message: "request canceled", Cause: err}
+	case errors.Is(err, context.DeadlineExceeded), mongo.IsTimeout(err):
+		return translated(codes.DeadlineExceeded, "deadline exceeded", "TIMEOUT", true)
+	case errors.Is(err, gorm.ErrRecordNotFound),
+		errors.Is(err, mongo.ErrNoDocuments),
+		errors.Is(err, redis.Nil),
+		object_storage.IsNotExist(err):
+		return translated(codes.NotFound, "resource not found", "NOT_FOUND", false)
+	case errors.Is(err, gorm.ErrDuplicatedKey), mongo.IsDuplicateKeyError(err):
+		return translated(codes.AlreadyExists, "resource already exists", "ALREADY_EXISTS", false)
+	case errors.Is(err, gorm.ErrForeignKeyViolated), errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return translated(codes.FailedPrecondition, "constraint violated", "CONSTRAINT_VIOLATED", false)
+	case mongo.IsNetworkError(err), errors.As(err, &netErr):
+		return translated(codes.Unavailable, "service unavailable", "UNAVAILABLE", true)
+	default:
+		return translated(codes.Internal, "internal error", "INTERNAL", false)
+	}
+}
+
+func statusDetails(st *status.Status) []protoadapt.MessageV1 {
+	var details []protoadapt.MessageV1
+	for _, detail := range st.Proto().GetDetails() {
+		msg, err := detail.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+		details = append(details, protoadapt.MessageV1Of(msg))
+	}
+	return details
+}
+
+// translateAndLog converts err for the client and logs its internal cause.
+func translateAndLog(ctx context.Context, cfg ErrorConfig, fullMethod string, err error) error {
+	translated := TranslateError(cfg, err)
+	if translated == nil {
+		return nil
+	}
+
+	level := slog.LevelDebug
+	switch translated.Code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded:
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("method", fullMethod),
+		slog.String("code", translated.Code.String()),
+	}
+	if translated.Cause != nil {
+		attrs = append(attrs, slog.String("error", translated.Cause.Error()))
+	}
+	slog.LogAttrs(ctx, level, "request failed", attrs...)
+
+	return translated.GRPCStatus().Err()
+}
+
+// Creates a gRPC interceptor that converts errors returned by unary handlers
+// into gRPC statuses, logging internal causes without exposing them to
+// clients.
+func BuildErrorInterceptor(cfg ErrorConfig) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, translateAndLog(ctx, cfg, info.FullMethod, err)
+		}
+		return resp, nil
+	}
+}
+
+// Creates a gRPC interceptor that converts errors returned by streaming
+// handlers into gRPC statuses, logging internal causes without exposing them
+// to clients.
+func BuildErrorStreamInterceptor(cfg ErrorConfig) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := handler(srv, ss); err != nil {
+			return translateAndLog(ss.Context(), cfg, info.FullMethod, err)
+		}
+		return nil
+	}
+}
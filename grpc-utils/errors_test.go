@@ -0,0 +1,163 @@
+package grpc_utils_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+var errSecret = errors.New("dial tcp 10.0.0.7:5432: password authentication failed")
+
+func callWithError(t *testing.T, cfg grpc_utils.ErrorConfig, handlerErr error) *status.Status {
+	t.Helper()
+	interceptor := grpc_utils.BuildErrorInterceptor(cfg)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.v1.Service/Get"}
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, handlerErr
+	})
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("Expected a gRPC status error, got %v", err)
+	}
+	return st
+}
+
+func errorInfo(st *status.Status) *errdetails.ErrorInfo {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+func hasRetryInfo(st *status.Status) bool {
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.RetryInfo); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestErrorInterceptor_KnownErrors(t *testing.T) {
+	_, notExistErr := os.Open("/does/not/exist")
+
+	tests := []struct {
+		name      string
+		err       error
+		code      codes.Code
+		reason    string
+		retryable bool
+	}{
+		{"gorm_not_found", fmt.Errorf("load user: %w", gorm.ErrRecordNotFound), codes.NotFound, "NOT_FOUND", false},
+		{"mongo_no_documents", mongo.ErrNoDocuments, codes.NotFound, "NOT_FOUND", false},
+		{"redis_nil", redis.Nil, codes.NotFound, "NOT_FOUND", false},
+		{"object_not_exist", notExistErr, codes.NotFound, "NOT_FOUND", false},
+		{"gorm_duplicated_key", gorm.ErrDuplicatedKey, codes.AlreadyExists, "ALREADY_EXISTS", false},
+		{"gorm_foreign_key", gorm.ErrForeignKeyViolated, codes.FailedPrecondition, "CONSTRAINT_VIOLATED", false},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded, "TIMEOUT", true},
+		{"unknown", errSecret, codes.Internal, "INTERNAL", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := callWithError(t, grpc_utils.ErrorConfig{Domain: "test.example.com"}, tt.err)
+			if st.Code() != tt.code {
+				t.Errorf("Expected code %v, got %v", tt.code, st.Code())
+			}
+			info := errorInfo(st)
+			if info == nil || info.Reason != tt.reason || info.Domain != "test.example.com" {
+				t.Errorf("Expected ErrorInfo with reason %s, got %v", tt.reason, info)
+			}
+			if hasRetryInfo(st) != tt.retryable {
+				t.Errorf("Expected retryable=%v, got details %v", tt.retryable, st.Details())
+			}
+			if strings.Contains(st.Message(), tt.err.Error()) {
+				t.Errorf("Internal cause leaked to client: %q", st.Message())
+			}
+		})
+	}
+}
+
+func TestErrorInterceptor_DomainError(t *testing.T) {
+	violation := &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "quantity", Description: "must be positive"},
+		},
+	}
+	err := grpc_utils.NewError(codes.InvalidArgument, "invalid order").
+		WithDetails(violation).
+		WithCause(errSecret)
+
+	st := callWithError(t, grpc_utils.ErrorConfig{}, fmt.Errorf("create order: %w", err))
+	if st.Code() != codes.InvalidArgument || st.Message() != "invalid order" {
+		t.Errorf("Expected InvalidArgument 'invalid order', got %v %q", st.Code(), st.Message())
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("Expected BadRequest detail, got %v", st.Details())
+	}
+	if br, ok := st.Details()[0].(*errdetails.BadRequest); !ok || br.FieldViolations[0].Field != "quantity" {
+		t.Errorf("Expected BadRequest detail for quantity, got %v", st.Details()[0])
+	}
+	if !errors.Is(err, errSecret) {
+		t.Error("Expected Error to unwrap to its cause")
+	}
+
+	retryable := &grpc_utils.Error{Code: codes.Aborted, Message: "conflict", Retryable: true}
+	if st := callWithError(t, grpc_utils.ErrorConfig{}, retryable); !hasRetryInfo(st) {
+		t.Error("Expected RetryInfo detail for retryable error")
+	}
+}
+
+func TestErrorInterceptor_StatusPassThrough(t *testing.T) {
+	st := callWithError(t, grpc_utils.ErrorConfig{}, status.Error(codes.PermissionDenied, "denied"))
+	if st.Code() != codes.PermissionDenied || st.Message() != "denied" {
+		t.Errorf("Expected status to pass through, got %v %q", st.Code(), st.Message())
+	}
+}
+
+func TestErrorInterceptor_CustomMapper(t *testing.T) {
+	errQuota := errors.New("quota exceeded")
+	cfg := grpc_utils.ErrorConfig{
+		Mappers: []grpc_utils.ErrorMapper{
+			func(err error) *grpc_utils.Error {
+				if errors.Is(err, errQuota) {
+					return grpc_utils.NewError(codes.ResourceExhausted, "quota exceeded")
+				}
+				return nil
+			},
+		},
+	}
+	if st := callWithError(t, cfg, errQuota); st.Code() != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted, got %v", st.Code())
+	}
+}
+
+func TestErrorInterceptor_LogsCause(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+
+	_ = callWithError(t, grpc_utils.ErrorConfig{}, errSecret)
+
+	output := buf.String()
+	if !strings.Contains(output, "level=ERROR") || !strings.Contains(output, "password authentication failed") {
+		t.Errorf("Expected internal cause to be logged at error level, got %q", output)
+	}
+}
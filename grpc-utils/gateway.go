@@ -0,0 +1,261 @@
+package grpc_utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/poly-workshop/go-webmods/app"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultGatewayAddress  = ":8080"
+	defaultGatewayEndpoint = "localhost:50051"
+)
+
+// GatewayRegisterFunc registers gateway handlers on mux, matching the
+// Register<Service>Handler functions generated by protoc-gen-grpc-gateway.
+type GatewayRegisterFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+// GatewayConfig holds configuration for NewGateway.
+//
+// Example config:
+//
+//	grpc:
+//	  gateway:
+//	    address: :8080
+//	    endpoint: localhost:50051
+//	    forward_headers: [x-tenant-id]
+type GatewayConfig struct {
+	// Address is the HTTP listen address. Optional. Defaults to ":8080".
+	Address string `mapstructure:"address"`
+	// Endpoint is the gRPC server the gateway forwards calls to.
+	// Optional. Defaults to "localhost:50051".
+	Endpoint string `mapstructure:"endpoint"`
+	// RequestIDHeader is the header carrying the request ID. Optional.
+	// Defaults to "x-request-id".
+	RequestIDHeader string `mapstructure:"request_id_header"`
+	// ForwardHeaders lists additional HTTP headers forwarded into the call
+	// metadata. The request ID, Authorization and traceparent headers are
+	// always forwarded.
+	ForwardHeaders []string `mapstructure:"forward_headers"`
+	// ReadHeaderTimeout bounds reading the request headers.
+	// Optional. Defaults to 10s.
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	// Errors translates errors that do not carry a gRPC status, e.g.
+	// connection failures, like the error interceptor does.
+	Errors ErrorConfig `mapstructure:"errors"`
+
+	// Logger logs HTTP requests. Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+	// Metrics records the forwarded calls as client metrics. Optional.
+	Metrics *ClientMetrics `mapstructure:"-"`
+	// DialOptions are appended to the options used to dial Endpoint, e.g.
+	// TLS credentials. The connection is insecure by default.
+	DialOptions []grpc.DialOption `mapstructure:"-"`
+	// MuxOptions are appended to the options of the gateway mux.
+	MuxOptions []runtime.ServeMuxOption `mapstructure:"-"`
+}
+
+// Gateway is an HTTP server transcoding JSON requests to gRPC calls.
+type Gateway struct {
+	*http.Server
+	// Mux is the gateway mux the handlers are registered on.
+	Mux *runtime.ServeMux
+
+	conn *grpc.ClientConn
+}
+
+// NewGateway creates an HTTP/JSON gateway forwarding to the gRPC server at
+// cfg.Endpoint and registers the generated handlers on it.
+//
+// Example:
+//
+//	var cfg grpc_utils.GatewayConfig
+//	if err := app.Config().UnmarshalKey("grpc.gateway", &cfg); err != nil {
+//	    panic(err)
+//	}
+//	gateway, err := grpc_utils.NewGateway(ctx, cfg, pb.RegisterOrderServiceHandler)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	go func() { _ = gateway.ListenAndServe() }()
+//	defer gateway.Shutdown(context.Background())
+func NewGateway(ctx context.Context, cfg GatewayConfig, register ...GatewayRegisterFunc) (*Gateway, error) {
+	if cfg.Address == "" {
+		cfg.Address = defaultGatewayAddress
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultGatewayEndpoint
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = DefaultRequestIDHeader
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = 10 * time.Second
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if cfg.Metrics != nil {
+		dialOptions = append(dialOptions,
+			grpc.WithChainUnaryInterceptor(cfg.Metrics.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(cfg.Metrics.StreamClientInterceptor()),
+		)
+	}
+	dialOptions = append(dialOptions, cfg.DialOptions...)
+	conn, err := grpc.NewClient(cfg.Endpoint, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("grpc_utils: failed to create gateway client: %w", err)
+	}
+
+	muxOptions := []runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(gatewayHeaderMatcher(cfg)),
+		runtime.WithOutgoingHeaderMatcher(gatewayOutgoingHeaderMatcher(cfg)),
+		runtime.WithErrorHandler(gatewayErrorHandler(cfg)),
+	}
+	mux := runtime.NewServeMux(append(muxOptions, cfg.MuxOptions...)...)
+	for _, fn := range register {
+		if err := fn(ctx, mux, conn); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("grpc_utils: failed to register gateway handler: %w", err)
+		}
+	}
+
+	return &Gateway{
+		Server: &http.Server{
+			Addr:              cfg.Address,
+			Handler:           gatewayHandler(cfg, mux),
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		},
+		Mux:  mux,
+		conn: conn,
+	}, nil
+}
+
+// Shutdown gracefully shuts down the HTTP server and closes the connection to
+// the gRPC server.
+func (g *Gateway) Shutdown(ctx context.Context) error {
+	err := g.Server.Shutdown(ctx)
+	return errors.Join(err, g.conn.Close())
+}
+
+// gatewayHeaderMatcher forwards the request ID, authorization, trace context
+// and configured headers into the call metadata.
+func gatewayHeaderMatcher(cfg GatewayConfig) runtime.HeaderMatcherFunc {
+	forwarded := map[string]bool{
+		textproto.CanonicalMIMEHeaderKey(cfg.RequestIDHeader):          true,
+		textproto.CanonicalMIMEHeaderKey(TraceParentHeader):            true,
+		textproto.CanonicalMIMEHeaderKey("tracestate"):                 true,
+		textproto.CanonicalMIMEHeaderKey(defaultRateLimitAPIKeyHeader): true,
+	}
+	for _, header := range cfg.ForwardHeaders {
+		forwarded[textproto.CanonicalMIMEHeaderKey(header)] = true
+	}
+	return func(key string) (string, bool) {
+		if forwarded[textproto.CanonicalMIMEHeaderKey(key)] {
+			return strings.ToLower(key), true
+		}
+		return runtime.DefaultHeaderMatcher(key)
+	}
+}
+
+// gatewayOutgoingHeaderMatcher returns the request ID and retry-after headers
+// unprefixed, and all other response metadata with the Grpc-Metadata- prefix.
+func gatewayOutgoingHeaderMatcher(cfg GatewayConfig) runtime.HeaderMatcherFunc {
+	return func(key string) (string, bool) {
+		switch strings.ToLower(key) {
+		case strings.ToLower(cfg.RequestIDHeader):
+			// The request ID is set by the gateway handler.
+			return "", false
+		case rateLimitRetryAfterHeader:
+			return "Retry-After", true
+		}
+		return runtime.MetadataHeaderPrefix + key, true
+	}
+}
+
+// gatewayErrorHandler writes errors as JSON statuses with the HTTP code
+// matching the gRPC code. Errors without a status are translated first so
+// internal causes are not exposed.
+func gatewayErrorHandler(cfg GatewayConfig) runtime.ErrorHandlerFunc {
+	return func(
+		ctx context.Context,
+		mux *runtime.ServeMux,
+		marshaler runtime.Marshaler,
+		w http.ResponseWriter,
+		r *http.Request,
+		err error,
+	) {
+		if _, ok := status.FromError(err); !ok {
+			var httpErr *runtime.HTTPStatusError
+			if !errors.As(err, &httpErr) {
+				err = translateAndLog(ctx, cfg.Errors, r.URL.Path, err)
+			}
+		}
+		runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+	}
+}
+
+// gatewayHandler assigns a request ID to every HTTP request, returns it in
+// the response and logs the request.
+func gatewayHandler(cfg GatewayConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(cfg.RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+			r.Header.Set(cfg.RequestIDHeader, requestID)
+		}
+		w.Header().Set(cfg.RequestIDHeader, requestID)
+
+		ctx := app.WithLogAttrs(r.Context(), slog.String("request_id", requestID))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		cfg.Logger.LogAttrs(ctx, level, "finished http request",
+			slog.String("http.method", r.Method),
+			slog.String("http.path", r.URL.Path),
+			slog.Int("http.status", rec.status),
+			slog.Float64("http.time_ms", float64(time.Since(start).Microseconds())/1000),
+		)
+	})
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
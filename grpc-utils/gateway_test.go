@@ -0,0 +1,131 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// registerHealthHandler mounts GET /v1/health/{service} the way generated
+// gateway code does.
+func registerHealthHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	client := healthpb.NewHealthClient(conn)
+	return mux.HandlePath(http.MethodGet, "/v1/health/{service}", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, "/grpc.health.v1.Health/Check",
+			runtime.WithHTTPPathPattern("/v1/health/{service}"))
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+		var md runtime.ServerMetadata
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: params["service"]},
+			grpc.Header(&md.HeaderMD), grpc.Trailer(&md.TrailerMD))
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+		runtime.ForwardResponseMessage(ctx, mux, outbound, w, r, resp)
+	})
+}
+
+func TestGateway(t *testing.T) {
+	var incoming metadata.MD
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("orders.v1.OrderService", healthpb.HealthCheckResponse_SERVING)
+
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Interceptors: []string{grpc_utils.InterceptorRequestID},
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{func(
+			ctx context.Context,
+			req any,
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (any, error) {
+			incoming, _ = metadata.FromIncomingContext(ctx)
+			return handler(ctx, req)
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	healthpb.RegisterHealthServer(server, healthServer)
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	gateway, err := grpc_utils.NewGateway(context.Background(), grpc_utils.GatewayConfig{
+		Endpoint:       "passthrough:///bufnet",
+		ForwardHeaders: []string{"X-Tenant-ID"},
+		Logger:         discardLogger,
+		DialOptions: []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+		},
+	}, registerHealthHandler)
+	if err != nil {
+		t.Fatalf("NewGateway failed: %v", err)
+	}
+	httpServer := httptest.NewServer(gateway.Handler)
+	defer httpServer.Close()
+	defer func() { _ = gateway.Shutdown(context.Background()) }()
+
+	req, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/v1/health/orders.v1.OrderService", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Tenant-ID", "acme")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body["status"] != "SERVING" {
+		t.Errorf("Expected 200 SERVING, got %d %v", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("X-Request-ID"); got != "req-123" {
+		t.Errorf("Expected request ID in response, got %q", got)
+	}
+	if got := incoming.Get("x-request-id"); len(got) != 1 || got[0] != "req-123" {
+		t.Errorf("Expected request ID forwarded into metadata, got %v", got)
+	}
+	if got := incoming.Get("authorization"); len(got) != 1 || got[0] != "Bearer token" {
+		t.Errorf("Expected authorization forwarded into metadata, got %v", got)
+	}
+	if got := incoming.Get("x-tenant-id"); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("Expected configured header forwarded into metadata, got %v", got)
+	}
+
+	resp, err = http.Get(httpServer.URL + "/v1/health/unknown.v1.Service")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body = nil
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound || body["code"] != float64(5) {
+		t.Errorf("Expected 404 with NotFound status, got %d %v", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("Expected generated request ID in response")
+	}
+}
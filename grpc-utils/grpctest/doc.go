@@ -0,0 +1,64 @@
+// Package grpctest provides an in-process gRPC test harness for services and
+// interceptors built with grpc_utils.
+//
+// # Harness
+//
+// Start serves a grpc.Server with the chosen interceptor chain on a bufconn
+// listener and returns a Harness holding a ready client connection. No
+// network port is used, and the server and connection are cleaned up with
+// t.Cleanup:
+//
+//	func TestRequestID(t *testing.T) {
+//	    h := grpctest.Start(t, grpctest.Config{
+//	        UnaryInterceptors: []grpc.UnaryServerInterceptor{
+//	            grpc_utils.BuildRequestIDInterceptor(),
+//	        },
+//	    }, func(s grpc.ServiceRegistrar) {
+//	        pb.RegisterOrderServiceServer(s, &orderService{})
+//	    })
+//
+//	    var header metadata.MD
+//	    _, err := pb.NewOrderServiceClient(h.Conn).GetOrder(ctx, req, grpc.Header(&header))
+//	    ...
+//	}
+//
+// StartServer does the same for a server built with grpc_utils.NewServer, so
+// the built-in interceptor chain of a ServerConfig can be tested as is.
+//
+// # Captured Calls
+//
+// Every call reaching the server is recorded, including calls rejected by an
+// interceptor, with the incoming metadata, the response header and trailer
+// and the returned error:
+//
+//	call := h.LastCall()
+//	if call.Header.Get("x-request-id")[0] != "req-1" {
+//	    t.Error("Expected request ID to be echoed")
+//	}
+//
+// # Captured Logs
+//
+// A LogRecorder records every entry of its logger, enriched with the context
+// attributes added via app.WithLogAttrs. Pass its logger to the interceptors
+// under test and inspect the entries afterwards:
+//
+//	logs := grpctest.NewLogRecorder()
+//	h := grpctest.Start(t, grpctest.Config{
+//	    Logs: logs,
+//	    UnaryInterceptors: []grpc.UnaryServerInterceptor{
+//	        grpc_utils.BuildRequestIDInterceptor(),
+//	        grpc_utils.BuildLogInterceptor(logs.Logger()),
+//	    },
+//	}, register)
+//	...
+//	for _, entry := range logs.Find("finished call") {
+//	    if entry.Attrs["request_id"] != "req-1" {
+//	        t.Error("Expected request ID in log entry")
+//	    }
+//	}
+//
+// Set Config.CaptureDefaultLogger to also record entries written to
+// slog.Default() in Harness.Logs. It replaces the process wide default
+// logger for the duration of the test, so such tests must not run in
+// parallel.
+package grpctest
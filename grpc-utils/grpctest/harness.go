@@ -0,0 +1,261 @@
+package grpctest
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	// Target is the target of connections dialed through the harness.
+	Target = "passthrough:///bufnet"
+
+	bufferSize   = 1024 * 1024
+	readyTimeout = 5 * time.Second
+	// settleTimeout bounds how long Calls waits for calls that were
+	// answered but are still finishing on the server.
+	settleTimeout = time.Second
+)
+
+// Config holds configuration for Start.
+type Config struct {
+	// UnaryInterceptors are chained on the server in order.
+	UnaryInterceptors []grpc.UnaryServerInterceptor
+	// StreamInterceptors are chained on the server in order.
+	StreamInterceptors []grpc.StreamServerInterceptor
+	// ServerOptions are additional server options.
+	ServerOptions []grpc.ServerOption
+	// DialOptions are additional options for Harness.Conn, e.g. client
+	// interceptors. The connection is insecure unless credentials are set.
+	DialOptions []grpc.DialOption
+	// Logs records the log entries. Pass its logger to the interceptors
+	// under test. Optional. A new recorder is created when unset.
+	Logs *LogRecorder
+	// CaptureDefaultLogger records entries written to slog.Default() in
+	// Logs too. The default logger is restored on cleanup.
+	CaptureDefaultLogger bool
+}
+
+// Call is a call received by the server.
+type Call struct {
+	// Method is the full method name, e.g. "/orders.v1.OrderService/GetOrder".
+	Method string
+	// Metadata is the incoming metadata as sent by the client.
+	Metadata metadata.MD
+	// Header is the response header sent by the server.
+	Header metadata.MD
+	// Trailer is the response trailer sent by the server.
+	Trailer metadata.MD
+	// Err is the error returned to the client, or nil.
+	Err error
+}
+
+// Harness is a gRPC server running in-process for a test.
+type Harness struct {
+	// Server is the running server.
+	Server *grpc.Server
+	// Conn is a ready client connection to Server.
+	Conn *grpc.ClientConn
+	// Logs records log entries, see Config.Logs.
+	Logs *LogRecorder
+	// Registry is a fresh metrics registry for the test.
+	Registry *prometheus.Registry
+
+	t        testing.TB
+	listener *bufconn.Listener
+	recorder *callRecorder
+}
+
+// Start starts a server with the interceptors of cfg, registers services with
+// register and connects a client to it.
+func Start(t testing.TB, cfg Config, register func(s grpc.ServiceRegistrar)) *Harness {
+	t.Helper()
+	h := newHarness(t, cfg)
+
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(h.recorder),
+		grpc.ChainUnaryInterceptor(cfg.UnaryInterceptors...),
+		grpc.ChainStreamInterceptor(cfg.StreamInterceptors...),
+	}
+	h.Server = grpc.NewServer(append(opts, cfg.ServerOptions...)...)
+	h.serve(t, cfg, register)
+	return h
+}
+
+// StartServer starts a server built with grpc_utils.NewServer from serverCfg.
+// The server logs to Harness.Logs and registers its metrics on
+// Harness.Registry unless serverCfg sets them. The interceptor and server
+// option fields of cfg are ignored.
+func StartServer(
+	t testing.TB,
+	serverCfg grpc_utils.ServerConfig,
+	cfg Config,
+	register func(s grpc.ServiceRegistrar),
+) *Harness {
+	t.Helper()
+	h := newHarness(t, cfg)
+
+	if serverCfg.Logger == nil {
+		serverCfg.Logger = h.Logs.Logger()
+	}
+	if serverCfg.Metrics.Registry == nil {
+		serverCfg.Metrics.Registry = h.Registry
+	}
+	serverCfg.Options = append([]grpc.ServerOption{grpc.StatsHandler(h.recorder)}, serverCfg.Options...)
+	server, err := grpc_utils.NewServer(serverCfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	h.Server = server.Server
+	h.serve(t, cfg, register)
+	return h
+}
+
+func newHarness(t testing.TB, cfg Config) *Harness {
+	if cfg.Logs == nil {
+		cfg.Logs = NewLogRecorder()
+	}
+	h := &Harness{
+		Logs:     cfg.Logs,
+		Registry: prometheus.NewRegistry(),
+		t:        t,
+		listener: bufconn.Listen(bufferSize),
+		recorder: &callRecorder{},
+	}
+	if cfg.CaptureDefaultLogger {
+		previous := slog.Default()
+		slog.SetDefault(h.Logs.Logger())
+		t.Cleanup(func() { slog.SetDefault(previous) })
+	}
+	return h
+}
+
+func (h *Harness) serve(t testing.TB, cfg Config, register func(s grpc.ServiceRegistrar)) {
+	t.Helper()
+	if register != nil {
+		register(h.Server)
+	}
+	go func() { _ = h.Server.Serve(h.listener) }()
+	t.Cleanup(h.Server.Stop)
+
+	conn, err := h.Dial(cfg.DialOptions...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if !conn.WaitForStateChange(ctx, state) {
+			t.Fatalf("Client did not become ready: %v", state)
+		}
+	}
+	h.Conn = conn
+}
+
+// Dial creates another client connection to the server, e.g. with different
+// client interceptors. The caller must close it.
+func (h *Harness) Dial(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return grpc.NewClient(Target, append(h.DialOptions(), opts...)...)
+}
+
+// DialOptions returns the options connecting to the server through the
+// in-memory listener, e.g. for grpc_utils.ClientConfig.DialOptions with
+// Target as target.
+func (h *Harness) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return h.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+}
+
+// Calls returns the calls received by the server so far, in the order they
+// completed. The server finishes a call just after the client received the
+// response, so Calls briefly waits for calls in flight to complete.
+func (h *Harness) Calls() []Call {
+	return h.recorder.calls()
+}
+
+// LastCall returns the last completed call. It fails the test if there is
+// none.
+func (h *Harness) LastCall() Call {
+	h.t.Helper()
+	calls := h.recorder.calls()
+	if len(calls) == 0 {
+		h.t.Fatal("No calls recorded")
+	}
+	return calls[len(calls)-1]
+}
+
+// callRecorder is a stats.Handler recording every call, so calls rejected by
+// an interceptor are seen too.
+type callRecorder struct {
+	mu        sync.Mutex
+	pending   int
+	completed []Call
+}
+
+type callKey struct{}
+
+func (r *callRecorder) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	r.mu.Lock()
+	r.pending++
+	r.mu.Unlock()
+	return context.WithValue(ctx, callKey{}, &Call{Method: info.FullMethodName})
+}
+
+func (r *callRecorder) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	call, ok := ctx.Value(callKey{}).(*Call)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.InHeader:
+		call.Metadata = s.Header.Copy()
+	case *stats.OutHeader:
+		call.Header = s.Header.Copy()
+	case *stats.OutTrailer:
+		call.Trailer = s.Trailer.Copy()
+	case *stats.End:
+		call.Err = s.Error
+		r.mu.Lock()
+		r.pending--
+		r.completed = append(r.completed, *call)
+		r.mu.Unlock()
+	}
+}
+
+func (r *callRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *callRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func (r *callRecorder) calls() []Call {
+	deadline := time.Now().Add(settleTimeout)
+	for {
+		r.mu.Lock()
+		if r.pending == 0 || time.Now().After(deadline) {
+			defer r.mu.Unlock()
+			return append([]Call(nil), r.completed...)
+		}
+		r.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,112 @@
+package grpctest_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/poly-workshop/go-webmods/grpc-utils/grpctest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func registerHealth(s grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(s, health.NewServer())
+}
+
+func TestStart_RequestIDEcho(t *testing.T) {
+	logs := grpctest.NewLogRecorder()
+	h := grpctest.Start(t, grpctest.Config{
+		Logs: logs,
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{
+			grpc_utils.BuildRequestIDInterceptor(),
+			grpc_utils.BuildLogInterceptor(logs.Logger()),
+		},
+	}, registerHealth)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-1")
+	var header metadata.MD
+	_, err := healthpb.NewHealthClient(h.Conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("Expected request ID to be echoed, got %v", got)
+	}
+
+	call := h.LastCall()
+	if call.Method != "/grpc.health.v1.Health/Check" || call.Err != nil {
+		t.Errorf("Expected successful Check call, got %+v", call)
+	}
+	if got := call.Metadata.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("Expected incoming request ID to be recorded, got %v", got)
+	}
+	if got := call.Header.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("Expected response header to be recorded, got %v", got)
+	}
+
+	entries := logs.Find("finished call")
+	if len(entries) != 1 {
+		t.Fatalf("Expected one finished call entry, got %v", logs.Entries())
+	}
+	if entries[0].Attrs["request_id"] != "req-1" || entries[0].Attrs["grpc.code"] != "OK" {
+		t.Errorf("Expected request ID and code in log entry, got %v", entries[0].Attrs)
+	}
+}
+
+func TestStart_RecordsRejectedCalls(t *testing.T) {
+	reject := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		_ = grpc.SetTrailer(ctx, metadata.Pairs("reason", "denied"))
+		return nil, status.Error(codes.PermissionDenied, "denied")
+	}
+	h := grpctest.Start(t, grpctest.Config{
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{reject},
+	}, registerHealth)
+
+	client := healthpb.NewHealthClient(h.Conn)
+	for range 2 {
+		_, _ = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	}
+
+	calls := h.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 calls, got %d", len(calls))
+	}
+	if status.Code(calls[1].Err) != codes.PermissionDenied {
+		t.Errorf("Expected rejected call error, got %v", calls[1].Err)
+	}
+	if got := calls[1].Trailer.Get("reason"); len(got) != 1 || got[0] != "denied" {
+		t.Errorf("Expected trailer to be recorded, got %v", got)
+	}
+}
+
+func TestStartServer(t *testing.T) {
+	h := grpctest.StartServer(t, grpc_utils.ServerConfig{}, grpctest.Config{
+		CaptureDefaultLogger: true,
+	}, registerHealth)
+
+	slog.Info("from default logger")
+	if _, err := healthpb.NewHealthClient(h.Conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if len(h.Logs.Find("from default logger")) != 1 {
+		t.Error("Expected default logger entries to be recorded")
+	}
+	entries := h.Logs.Find("finished call")
+	if len(entries) != 1 || entries[0].Attrs["request_id"] == nil {
+		t.Errorf("Expected server log entry with request ID, got %v", h.Logs.Entries())
+	}
+	if h.LastCall().Header.Get("x-request-id") == nil {
+		t.Error("Expected built-in request ID interceptor to set the header")
+	}
+	if n := testutil.CollectAndCount(h.Registry, "grpc_server_requests_total"); n != 1 {
+		t.Errorf("Expected server metrics on the harness registry, got %d series", n)
+	}
+}
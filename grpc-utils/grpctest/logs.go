@@ -0,0 +1,140 @@
+package grpctest
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/poly-workshop/go-webmods/app"
+)
+
+// LogEntry is a recorded log entry.
+type LogEntry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	// Attrs holds the attribute values by key. Keys of grouped attributes
+	// are joined with dots, e.g. "grpc.code".
+	Attrs map[string]any
+}
+
+// LogRecorder records the entries of its logger.
+type LogRecorder struct {
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	logged []LogEntry
+}
+
+// NewLogRecorder creates a recorder whose logger records entries of all
+// levels, enriched with the context attributes added via app.WithLogAttrs.
+func NewLogRecorder() *LogRecorder {
+	r := &LogRecorder{}
+	r.logger = slog.New(app.NewLogHandler(&logHandler{recorder: r}))
+	return r
+}
+
+// Logger returns the logger writing to r.
+func (r *LogRecorder) Logger() *slog.Logger {
+	return r.logger
+}
+
+// Entries returns the entries logged so far.
+func (r *LogRecorder) Entries() []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]LogEntry(nil), r.logged...)
+}
+
+// Find returns the entries with the given message.
+func (r *LogRecorder) Find(message string) []LogEntry {
+	var found []LogEntry
+	for _, entry := range r.Entries() {
+		if entry.Message == message {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+func (r *LogRecorder) add(entry LogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logged = append(r.logged, entry)
+}
+
+// logHandler is a slog.Handler recording entries of all levels.
+type logHandler struct {
+	recorder *LogRecorder
+	attrs    []slog.Attr
+	group    string
+}
+
+func (h *logHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *logHandler) Handle(_ context.Context, r slog.Record) error {
+	entry := LogEntry{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Attrs:   make(map[string]any),
+	}
+	for _, attr := range h.attrs {
+		addAttr(entry.Attrs, "", attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		addAttr(entry.Attrs, h.group, attr)
+		return true
+	})
+	h.recorder.add(entry)
+	return nil
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	qualified := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	qualified = append(qualified, h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		qualified = append(qualified, attr)
+	}
+	return &logHandler{recorder: h.recorder, attrs: qualified, group: h.group}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &logHandler{recorder: h.recorder, attrs: h.attrs, group: group}
+}
+
+func addAttr(attrs map[string]any, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	key := attr.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if value.Kind() == slog.KindGroup {
+		for _, nested := range value.Group() {
+			// Attributes of inline groups have no key of their own.
+			nestedPrefix := key
+			if attr.Key == "" {
+				nestedPrefix = prefix
+			}
+			addAttr(attrs, nestedPrefix, nested)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+	attrs[key] = value.Any()
+}
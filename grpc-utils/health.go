@@ -0,0 +1,252 @@
+package grpc_utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	object_storage "github.com/poly-workshop/go-webmods/object-storage"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 2 * time.Second
+)
+
+// HealthCheck reports whether a component is healthy by returning nil.
+type HealthCheck func(ctx context.Context) error
+
+// HealthConfig holds configuration for the health checker.
+type HealthConfig struct {
+	// Interval between check runs. Optional. Defaults to 10s.
+	Interval time.Duration `mapstructure:"interval"`
+	// Timeout of a single check. Optional. Defaults to 2s.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type healthComponent struct {
+	name     string
+	check    HealthCheck
+	services []string
+}
+
+// HealthChecker serves the standard grpc.health.v1 service with statuses
+// driven by periodic component checks.
+//
+// The overall server status, the empty service name, is SERVING when all
+// checks pass. A named service is SERVING when the checks registered for it
+// pass.
+type HealthChecker struct {
+	cfg    HealthConfig
+	server *health.Server
+
+	mu         sync.Mutex
+	components []healthComponent
+	services   map[string]bool
+	shutdown   bool
+}
+
+// NewHealthChecker creates a health checker. All services report
+// NOT_SERVING until the first check run.
+func NewHealthChecker(cfg HealthConfig) *HealthChecker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultHealthInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHealthTimeout
+	}
+	h := &HealthChecker{
+		cfg:      cfg,
+		server:   health.NewServer(),
+		services: map[string]bool{"": true},
+	}
+	h.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+// AddCheck registers a component check. The check affects the overall status
+// and the status of the given services.
+//
+// Example:
+//
+//	checker.AddCheck("postgres", grpc_utils.GormHealthCheck(db), "orders.v1.OrderService")
+//	checker.AddCheck("redis", grpc_utils.RedisHealthCheck(rdb))
+func (h *HealthChecker) AddCheck(name string, check HealthCheck, services ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.components = append(h.components, healthComponent{name: name, check: check, services: services})
+	for _, service := range services {
+		if !h.services[service] {
+			h.services[service] = true
+			h.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+	}
+}
+
+// Register registers the grpc.health.v1 service on s.
+func (h *HealthChecker) Register(s grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(s, h.server)
+}
+
+// Start runs the checks immediately and then every interval until ctx is
+// done or the checker is shut down.
+func (h *HealthChecker) Start(ctx context.Context) {
+	h.CheckNow(ctx)
+	go func() {
+		ticker := time.NewTicker(h.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if h.isShutdown() {
+					return
+				}
+				h.CheckNow(ctx)
+			}
+		}
+	}()
+}
+
+// CheckNow runs all checks concurrently and updates the service statuses.
+// It returns the errors of the failed checks by component name.
+func (h *HealthChecker) CheckNow(ctx context.Context) map[string]error {
+	h.mu.Lock()
+	components := append([]healthComponent(nil), h.components...)
+	h.mu.Unlock()
+
+	results := make([]error, len(components))
+	var wg sync.WaitGroup
+	for i, component := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+			defer cancel()
+			results[i] = component.check(checkCtx)
+		}()
+	}
+	wg.Wait()
+
+	failures := make(map[string]error)
+	unhealthy := make(map[string]bool)
+	for i, component := range components {
+		if results[i] == nil {
+			continue
+		}
+		failures[component.name] = results[i]
+		slog.WarnContext(ctx, "health check failed",
+			slog.String("component", component.name),
+			slog.String("error", results[i].Error()),
+		)
+		unhealthy[""] = true
+		for _, service := range component.services {
+			unhealthy[service] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shutdown {
+		return failures
+	}
+	for service := range h.services {
+		servingStatus := healthpb.HealthCheckResponse_SERVING
+		if unhealthy[service] {
+			servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		h.server.SetServingStatus(service, servingStatus)
+	}
+	return failures
+}
+
+// Shutdown sets all services to NOT_SERVING and ignores later check results,
+// so load balancers stop routing new calls while the server drains.
+func (h *HealthChecker) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = true
+	h.server.Shutdown()
+}
+
+func (h *HealthChecker) isShutdown() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.shutdown
+}
+
+// GormHealthCheck returns a check that pings the database behind db.
+func GormHealthCheck(db *gorm.DB) HealthCheck {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// RedisHealthCheck returns a check that sends PING to rdb.
+func RedisHealthCheck(rdb redis.UniversalClient) HealthCheck {
+	return func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+// MongoHealthCheck returns a check that pings the primary of client.
+func MongoHealthCheck(client *mongo.Client) HealthCheck {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx, nil)
+	}
+}
+
+// ObjectStorageHealthCheck returns a check that stats the sentinel key in
+// storage. The key must exist. Storages that also implement
+// object_storage.ContextObjectStorage are called with the check context.
+func ObjectStorageHealthCheck(storage object_storage.ObjectStorage, key string) HealthCheck {
+	switch s := storage.(type) {
+	case object_storage.ContextObjectStorage:
+		return ContextObjectStorageHealthCheck(s, key)
+	case *object_storage.ObjectStorageAdapter:
+		return ContextObjectStorageHealthCheck(s.Storage, key)
+	}
+	return func(ctx context.Context) error {
+		// Stat does not accept a context, so the timeout is enforced by
+		// abandoning the call.
+		result := make(chan error, 1)
+		go func() {
+			_, err := storage.Stat(key)
+			result <- err
+		}()
+		select {
+		case err := <-result:
+			if err != nil {
+				return fmt.Errorf("grpc_utils: stat sentinel key %s: %w", key, err)
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// ContextObjectStorageHealthCheck returns a check that stats the sentinel key
+// in storage with the check context. The key must exist.
+func ContextObjectStorageHealthCheck(storage object_storage.ContextObjectStorage, key string) HealthCheck {
+	return func(ctx context.Context) error {
+		if _, err := storage.StatContext(ctx, key); err != nil {
+			return fmt.Errorf("grpc_utils: stat sentinel key %s: %w", key, err)
+		}
+		return nil
+	}
+}
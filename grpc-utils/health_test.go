@@ -0,0 +1,166 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	object_storage "github.com/poly-workshop/go-webmods/object-storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func healthStatus(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q) failed: %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestHealthChecker(t *testing.T) {
+	var dbDown atomic.Bool
+	dbDown.Store(true)
+
+	checker := grpc_utils.NewHealthChecker(grpc_utils.HealthConfig{})
+	checker.AddCheck("postgres", func(ctx context.Context) error {
+		if dbDown.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, "orders.v1.OrderService")
+	checker.AddCheck("redis", func(ctx context.Context) error { return nil }, "cart.v1.CartService")
+
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Interceptors:  []string{grpc_utils.InterceptorRecovery},
+		Logger:        discardLogger,
+		HealthChecker: checker,
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	client := healthpb.NewHealthClient(startServer(t, server, grpc.WithTransportCredentials(insecure.NewCredentials())))
+
+	if got := healthStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING before the first check, got %v", got)
+	}
+
+	failures := checker.CheckNow(context.Background())
+	if _, ok := failures["postgres"]; !ok || len(failures) != 1 {
+		t.Errorf("Expected postgres failure, got %v", failures)
+	}
+	if got := healthStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected overall NOT_SERVING with a failed check, got %v", got)
+	}
+	if got := healthStatus(t, client, "orders.v1.OrderService"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected order service NOT_SERVING, got %v", got)
+	}
+	if got := healthStatus(t, client, "cart.v1.CartService"); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected cart service SERVING, got %v", got)
+	}
+
+	dbDown.Store(false)
+	checker.CheckNow(context.Background())
+	if got := healthStatus(t, client, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected SERVING once all checks pass, got %v", got)
+	}
+
+	checker.Shutdown()
+	checker.CheckNow(context.Background())
+	if got := healthStatus(t, client, "cart.v1.CartService"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING after shutdown, got %v", got)
+	}
+}
+
+func TestHealthChecker_Timeout(t *testing.T) {
+	checker := grpc_utils.NewHealthChecker(grpc_utils.HealthConfig{Timeout: 50 * time.Millisecond})
+	checker.AddCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	failures := checker.CheckNow(context.Background())
+	if !errors.Is(failures["slow"], context.DeadlineExceeded) {
+		t.Errorf("Expected slow check to time out, got %v", failures)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CheckNow took %v, expected the timeout to apply", elapsed)
+	}
+}
+
+func TestHealthChecker_Start(t *testing.T) {
+	var runs atomic.Int32
+	checker := grpc_utils.NewHealthChecker(grpc_utils.HealthConfig{Interval: 10 * time.Millisecond})
+	checker.AddCheck("counter", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	checker.Start(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runs.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if runs.Load() < 3 {
+		t.Errorf("Expected periodic checks, got %d runs", runs.Load())
+	}
+}
+
+func TestObjectStorageHealthCheck(t *testing.T) {
+	storage, err := object_storage.NewObjectStorage(object_storage.Config{
+		ProviderType: object_storage.ProviderLocal,
+		ProviderConfig: object_storage.ProviderConfig{
+			BasePath: t.TempDir(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	check := grpc_utils.ObjectStorageHealthCheck(storage, "health/sentinel")
+	if err := check(context.Background()); !object_storage.IsNotExist(err) {
+		t.Errorf("Expected missing sentinel key to fail the check, got %v", err)
+	}
+
+	if _, err := storage.Save("health/sentinel", strings.NewReader("ok")); err != nil {
+		t.Fatalf("Failed to save sentinel: %v", err)
+	}
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected check to pass, got %v", err)
+	}
+}
+
+func TestContextObjectStorageHealthCheck(t *testing.T) {
+	storage, err := object_storage.NewContextObjectStorage(object_storage.Config{
+		ProviderType: object_storage.ProviderLocal,
+		ProviderConfig: object_storage.ProviderConfig{
+			BasePath: t.TempDir(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if _, err := storage.SaveContext(context.Background(), "health/sentinel", strings.NewReader("ok")); err != nil {
+		t.Fatalf("Failed to save sentinel: %v", err)
+	}
+
+	check := grpc_utils.ContextObjectStorageHealthCheck(storage, "health/sentinel")
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected check to pass, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := check(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
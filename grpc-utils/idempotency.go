@@ -0,0 +1,355 @@
+package grpc_utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// DefaultIdempotencyHeader is the metadata key carrying the idempotency
+	// key when no other header is configured.
+	DefaultIdempotencyHeader = "idempotency-key"
+	// IdempotencyReplayedHeader is set to "true" on replayed responses.
+	IdempotencyReplayedHeader = "idempotency-replayed"
+
+	defaultIdempotencyKeyPrefix = "idempotency:"
+	defaultIdempotencyTTL       = 24 * time.Hour
+	defaultIdempotencyLockTTL   = 30 * time.Second
+	idempotencyLockPrefix       = "lock:"
+)
+
+// idempotencyCompleteScript replaces the in-progress lock with the stored
+// result, or deletes it if ARGV[2] is empty, only if the caller still owns the
+// lock.
+//
+// KEYS[1] - record key
+// ARGV[1] - lock value
+// ARGV[2] - stored result
+// ARGV[3] - TTL in milliseconds
+var idempotencyCompleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "" then
+	redis.call("DEL", KEYS[1])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+end
+return 1
+`)
+
+// idempotencyRefreshScript extends the in-progress lock only if the caller
+// still owns it.
+//
+// KEYS[1] - record key
+// ARGV[1] - lock value
+// ARGV[2] - TTL in milliseconds
+var idempotencyRefreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+`)
+
+// IdempotencyConfig holds configuration for the idempotency interceptor.
+//
+// Example config:
+//
+//	grpc:
+//	  idempotency:
+//	    ttl: 24h
+//	    lock_ttl: 30s
+//	    methods:
+//	      - /orders.v1.OrderService/Create*
+//	      - /payments.v1.PaymentService/Charge
+type IdempotencyConfig struct {
+	// Methods lists the full method name patterns that honor idempotency
+	// keys, see MatchMethod. Optional. Defaults to all methods.
+	Methods []string `mapstructure:"methods"`
+	// Header is the metadata key carrying the idempotency key.
+	// Optional. Defaults to "idempotency-key".
+	Header string `mapstructure:"header"`
+	// TTL is how long results are kept for replay. Optional. Defaults to 24h.
+	TTL time.Duration `mapstructure:"ttl"`
+	// LockTTL bounds how long a crashed call holds the key. The lock is
+	// refreshed every LockTTL/2 while the handler runs, so calls may take
+	// longer than LockTTL. Optional. Defaults to 30s.
+	LockTTL time.Duration `mapstructure:"lock_ttl"`
+	// KeyPrefix is prepended to Redis keys. Optional. Defaults to
+	// "idempotency:".
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// Redis stores the results, typically created with redis_client.NewRDB.
+	// Required.
+	Redis redis.UniversalClient `mapstructure:"-"`
+}
+
+// idempotencyRecord is the result stored for an idempotency key.
+type idempotencyRecord struct {
+	// Fingerprint is the hash of the request that produced the result.
+	Fingerprint string `json:"fingerprint"`
+	// Status is the serialized google.rpc.Status of the call.
+	Status []byte `json:"status"`
+	// Response is the serialized response wrapped in an Any.
+	Response []byte `json:"response,omitempty"`
+}
+
+// Idempotency replays the stored result of unary calls retried with the same
+// idempotency key.
+type Idempotency struct {
+	cfg IdempotencyConfig
+}
+
+// NewIdempotency creates an idempotency interceptor provider.
+//
+// Example:
+//
+//	idempotency, err := grpc_utils.NewIdempotency(grpc_utils.IdempotencyConfig{
+//	    Methods: []string{"/orders.v1.OrderService/CreateOrder"},
+//	    Redis:   redis_client.NewRDB(redis_client.Config{Urls: []string{"localhost:6379"}}),
+//	})
+func NewIdempotency(cfg IdempotencyConfig) (*Idempotency, error) {
+	if cfg.Redis == nil {
+		return nil, errors.New("grpc_utils: idempotency requires a redis client")
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultIdempotencyHeader
+	}
+	cfg.Header = strings.ToLower(cfg.Header)
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultIdempotencyTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = defaultIdempotencyLockTTL
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultIdempotencyKeyPrefix
+	}
+	return &Idempotency{cfg: cfg}, nil
+}
+
+// UnaryServerInterceptor returns an interceptor that executes a call once per
+// idempotency key and replays its response or status for duplicates.
+//
+// A duplicate sent while the first call is still running is rejected with
+// codes.Aborted, and reusing a key with a different request is rejected with
+// codes.InvalidArgument. Transient failures are not stored, so the call can
+// be retried with the same key.
+func (i *Idempotency) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if len(i.cfg.Methods) > 0 && !matchAnyMethod(i.cfg.Methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		idempotencyKey := metadataValue(ctx, i.cfg.Header)
+		if idempotencyKey == "" {
+			return handler(ctx, req)
+		}
+
+		fingerprint, err := requestFingerprint(req)
+		if err != nil {
+			slog.WarnContext(ctx, "idempotency disabled for request", "error", err)
+			return handler(ctx, req)
+		}
+		key := i.recordKey(ctx, info.FullMethod, idempotencyKey)
+		lock := idempotencyLockPrefix + uuid.New().String()
+
+		acquired, err := i.cfg.Redis.SetNX(ctx, key, lock, i.cfg.LockTTL).Result()
+		if err != nil {
+			// Redis being unavailable must not block writes.
+			slog.ErrorContext(ctx, "failed to acquire idempotency key", "error", err)
+			return handler(ctx, req)
+		}
+		if !acquired {
+			return i.replay(ctx, key, fingerprint)
+		}
+
+		stopRefresh := i.refreshLock(ctx, key, lock)
+		resp, handlerErr := handler(ctx, req)
+		stopRefresh()
+
+		// The result is stored even if the client went away.
+		storeCtx := context.WithoutCancel(ctx)
+		record, err := newIdempotencyRecord(fingerprint, resp, handlerErr)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to serialize idempotent result", "error", err)
+		}
+		var value []byte
+		if record != nil {
+			value, _ = json.Marshal(record)
+		}
+		if err := idempotencyCompleteScript.Run(storeCtx, i.cfg.Redis, []string{key},
+			lock, string(value), i.cfg.TTL.Milliseconds()).Err(); err != nil {
+			slog.ErrorContext(ctx, "failed to store idempotent result", "error", err)
+		}
+		return resp, handlerErr
+	}
+}
+
+// refreshLock extends the lock on key every LockTTL/2 until the returned
+// function is called, so a running handler keeps the key however long it
+// takes.
+func (i *Idempotency) refreshLock(ctx context.Context, key, lock string) func() {
+	refreshCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(i.cfg.LockTTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-refreshCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			owned, err := idempotencyRefreshScript.Run(refreshCtx, i.cfg.Redis, []string{key},
+				lock, i.cfg.LockTTL.Milliseconds()).Int()
+			if err != nil {
+				if refreshCtx.Err() == nil {
+					slog.WarnContext(ctx, "failed to refresh idempotency lock", "error", err)
+				}
+				continue
+			}
+			if owned == 0 {
+				slog.WarnContext(ctx, "lost idempotency lock")
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (i *Idempotency) recordKey(ctx context.Context, fullMethod, idempotencyKey string) string {
+	// Keys are scoped per caller so one caller cannot replay another
+	// caller's response.
+	scope := userFromContext(ctx)
+	return i.cfg.KeyPrefix + fullMethod + ":" + scope + ":" + idempotencyKey
+}
+
+func (i *Idempotency) replay(ctx context.Context, key, fingerprint string) (any, error) {
+	value, err := i.cfg.Redis.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		// The first call failed transiently and released the key.
+		return nil, status.Error(codes.Aborted, "request with this idempotency key was retried concurrently")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load idempotent result", "error", err)
+		return nil, status.Error(codes.Unavailable, "idempotency store unavailable")
+	}
+	if strings.HasPrefix(value, idempotencyLockPrefix) {
+		return nil, status.Error(codes.Aborted, "request with this idempotency key is in progress")
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		slog.ErrorContext(ctx, "failed to decode idempotent result", "error", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if record.Fingerprint != fingerprint {
+		return nil, status.Error(codes.InvalidArgument, "idempotency key was used with a different request")
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(IdempotencyReplayedHeader, "true")); err != nil {
+		slog.DebugContext(ctx, "failed to set idempotency header", "error", err)
+	}
+	return record.result()
+}
+
+// newIdempotencyRecord serializes the result of a call. It returns nil for
+// results that must not be replayed.
+func newIdempotencyRecord(fingerprint string, resp any, handlerErr error) (*idempotencyRecord, error) {
+	st := status.Convert(handlerErr)
+	switch st.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted,
+		codes.Unavailable, codes.Internal, codes.Unknown:
+		return nil, nil
+	}
+
+	statusBytes, err := proto.Marshal(st.Proto())
+	if err != nil {
+		return nil, err
+	}
+	record := &idempotencyRecord{Fingerprint: fingerprint, Status: statusBytes}
+	if handlerErr == nil {
+		msg, ok := resp.(proto.Message)
+		if !ok {
+			return nil, errors.New("grpc_utils: response is not a protobuf message")
+		}
+		wrapped, err := anypb.New(msg)
+		if err != nil {
+			return nil, err
+		}
+		if record.Response, err = proto.Marshal(wrapped); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+func (r *idempotencyRecord) result() (any, error) {
+	var stProto spb.Status
+	if err := proto.Unmarshal(r.Status, &stProto); err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if err := status.FromProto(&stProto).Err(); err != nil {
+		return nil, err
+	}
+
+	var wrapped anypb.Any
+	if err := proto.Unmarshal(r.Response, &wrapped); err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	resp, err := wrapped.UnmarshalNew()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return resp, nil
+}
+
+// requestFingerprint hashes the deterministic encoding of req.
+func requestFingerprint(req any) (string, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return "", errors.New("grpc_utils: request is not a protobuf message")
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(proto.MessageName(msg)+":"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// metadataValue returns the first value of key in the incoming metadata.
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
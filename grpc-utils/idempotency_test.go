@@ -0,0 +1,227 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	redis_client "github.com/poly-workshop/go-webmods/redis-client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func idempotentContext(key string) (context.Context, *fakeServerStream) {
+	stream := &fakeServerStream{method: "/orders.v1.OrderService/CreateOrder"}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(grpc_utils.DefaultIdempotencyHeader, key)), stream
+}
+
+func callIdempotent(
+	interceptor grpc.UnaryServerInterceptor,
+	ctx context.Context,
+	req *healthpb.HealthCheckRequest,
+	handler grpc.UnaryHandler,
+) (*healthpb.HealthCheckResponse, error) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/CreateOrder"}
+	resp, err := interceptor(ctx, req, info, handler)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*healthpb.HealthCheckResponse), nil
+}
+
+func TestNewIdempotency_RequiresRedis(t *testing.T) {
+	if _, err := grpc_utils.NewIdempotency(grpc_utils.IdempotencyConfig{}); err == nil {
+		t.Error("Expected error without redis client")
+	}
+}
+
+func TestIdempotency_RedisUnavailable(t *testing.T) {
+	idempotency, err := grpc_utils.NewIdempotency(grpc_utils.IdempotencyConfig{
+		// Nothing listens on this port, so every Redis call fails
+		Redis: redis_client.NewRDB(redis_client.Config{Urls: []string{"127.0.0.1:1"}}),
+	})
+	if err != nil {
+		t.Fatalf("NewIdempotency failed: %v", err)
+	}
+	interceptor := idempotency.UnaryServerInterceptor()
+
+	var calls atomic.Int32
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls.Add(1)
+		return &healthpb.HealthCheckResponse{}, nil
+	}
+	ctx, _ := idempotentContext("key-1")
+	for range 2 {
+		if _, err := callIdempotent(interceptor, ctx, &healthpb.HealthCheckRequest{}, handler); err != nil {
+			t.Fatalf("Expected call to pass while redis is unavailable, got %v", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected handler to run for every call, got %d", calls.Load())
+	}
+}
+
+func TestIdempotency_Redis(t *testing.T) {
+	addr := startRedisContainer(t)
+	idempotency, err := grpc_utils.NewIdempotency(grpc_utils.IdempotencyConfig{
+		Redis: redis_client.NewRDB(redis_client.Config{Urls: []string{addr}}),
+	})
+	if err != nil {
+		t.Fatalf("NewIdempotency failed: %v", err)
+	}
+	interceptor := idempotency.UnaryServerInterceptor()
+	req := &healthpb.HealthCheckRequest{Service: "order-1"}
+
+	var calls atomic.Int32
+	handler := func(ctx context.Context, req any) (any, error) {
+		n := calls.Add(1)
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_ServingStatus(n)}, nil
+	}
+
+	ctx, _ := idempotentContext("create-1")
+	first, err := callIdempotent(interceptor, ctx, req, handler)
+	if err != nil {
+		t.Fatalf("First call failed: %v", err)
+	}
+	ctx, stream := idempotentContext("create-1")
+	replayed, err := callIdempotent(interceptor, ctx, req, handler)
+	if err != nil {
+		t.Fatalf("Duplicate call failed: %v", err)
+	}
+	if calls.Load() != 1 || replayed.Status != first.Status {
+		t.Errorf("Expected stored response to be replayed, got %v after %d calls", replayed, calls.Load())
+	}
+	if got := stream.header.Get(grpc_utils.IdempotencyReplayedHeader); len(got) != 1 || got[0] != "true" {
+		t.Errorf("Expected replay header, got %v", got)
+	}
+
+	ctx, _ = idempotentContext("create-1")
+	_, err = callIdempotent(interceptor, ctx, &healthpb.HealthCheckRequest{Service: "order-2"}, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a different request, got %v", err)
+	}
+
+	// Errors from the handler are replayed too.
+	failing := func(ctx context.Context, req any) (any, error) {
+		calls.Add(1)
+		return nil, status.Error(codes.FailedPrecondition, "out of stock")
+	}
+	for range 2 {
+		ctx, _ := idempotentContext("create-2")
+		if _, err := callIdempotent(interceptor, ctx, req, failing); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("Expected stored FailedPrecondition, got %v", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected failed call to run once, got %d calls", calls.Load())
+	}
+
+	// Transient errors release the key so the call can be retried.
+	ctx, _ = idempotentContext("create-3")
+	unavailable := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.Unavailable, "database down")
+	}
+	if _, err := callIdempotent(interceptor, ctx, req, unavailable); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected Unavailable, got %v", err)
+	}
+	if _, err := callIdempotent(interceptor, ctx, req, handler); err != nil {
+		t.Errorf("Expected retry after transient error to run, got %v", err)
+	}
+}
+
+func TestIdempotency_RedisConcurrent(t *testing.T) {
+	addr := startRedisContainer(t)
+	idempotency, err := grpc_utils.NewIdempotency(grpc_utils.IdempotencyConfig{
+		Redis: redis_client.NewRDB(redis_client.Config{Urls: []string{addr}}),
+	})
+	if err != nil {
+		t.Fatalf("NewIdempotency failed: %v", err)
+	}
+	interceptor := idempotency.UnaryServerInterceptor()
+	req := &healthpb.HealthCheckRequest{Service: "order-1"}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		ctx, _ := idempotentContext("create-1")
+		_, err := callIdempotent(interceptor, ctx, req, func(ctx context.Context, req any) (any, error) {
+			close(started)
+			<-release
+			return &healthpb.HealthCheckResponse{}, nil
+		})
+		done <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("First call did not start")
+	}
+	ctx, _ := idempotentContext("create-1")
+	_, err = callIdempotent(interceptor, ctx, req, func(ctx context.Context, req any) (any, error) {
+		t.Error("Expected concurrent duplicate not to run")
+		return nil, nil
+	})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("Expected Aborted for concurrent duplicate, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("First call failed: %v", err)
+	}
+}
+
+func TestIdempotency_RedisLockRefreshed(t *testing.T) {
+	addr := startRedisContainer(t)
+	idempotency, err := grpc_utils.NewIdempotency(grpc_utils.IdempotencyConfig{
+		LockTTL: 200 * time.Millisecond,
+		Redis:   redis_client.NewRDB(redis_client.Config{Urls: []string{addr}}),
+	})
+	if err != nil {
+		t.Fatalf("NewIdempotency failed: %v", err)
+	}
+	interceptor := idempotency.UnaryServerInterceptor()
+	req := &healthpb.HealthCheckRequest{Service: "order-1"}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		ctx, _ := idempotentContext("create-1")
+		_, err := callIdempotent(interceptor, ctx, req, func(ctx context.Context, req any) (any, error) {
+			close(started)
+			<-release
+			return &healthpb.HealthCheckResponse{}, nil
+		})
+		done <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("First call did not start")
+	}
+	// Outlive the lock TTL several times over.
+	time.Sleep(time.Second)
+	ctx, _ := idempotentContext("create-1")
+	_, err = callIdempotent(interceptor, ctx, req, func(ctx context.Context, req any) (any, error) {
+		t.Error("Expected duplicate of a long running call not to run")
+		return nil, nil
+	})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("Expected Aborted while the first call is running, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("First call failed: %v", err)
+	}
+}
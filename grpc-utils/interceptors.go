@@ -3,55 +3,181 @@ package grpc_utils
 import (
 	"context"
 	"log/slog"
+	"strings"
 
 	"github.com/google/uuid"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
-	"github.com/oj-lab/go-webmods/app"
+	"github.com/poly-workshop/go-webmods/app"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
 
+type contextKey string
+
+const (
+	// DefaultRequestIDHeader is the metadata key used to read and return the
+	// request ID when no other header is configured.
+	DefaultRequestIDHeader = "x-request-id"
+	// TraceParentHeader is the W3C Trace Context header carrying the trace ID.
+	TraceParentHeader = "traceparent"
+
+	requestIDKey contextKey = "request_id"
+)
+
+// RequestIDConfig holds configuration for the request ID interceptor.
+type RequestIDConfig struct {
+	// Header is the metadata key used to read the incoming request ID and to
+	// return it in response headers. Optional. Defaults to "x-request-id".
+	Header string `mapstructure:"header"`
+	// IgnoreTraceParent disables deriving the request ID from the trace ID of
+	// an incoming W3C traceparent header when no request ID header is present.
+	IgnoreTraceParent bool `mapstructure:"ignore_trace_parent"`
+}
+
 // Creates a gRPC interceptor that logs messages using the provided slog.Logger.
 func BuildLogInterceptor(l *slog.Logger) grpc.UnaryServerInterceptor {
-	return logging.UnaryServerInterceptor(logging.LoggerFunc(
+	return logging.UnaryServerInterceptor(slogLogger(l))
+}
+
+// Creates a gRPC interceptor that logs streaming calls using the provided
+// slog.Logger.
+func BuildLogStreamInterceptor(l *slog.Logger) grpc.StreamServerInterceptor {
+	return logging.StreamServerInterceptor(slogLogger(l))
+}
+
+func slogLogger(l *slog.Logger) logging.Logger {
+	return logging.LoggerFunc(
 		func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
 			l.Log(ctx, slog.Level(lvl), msg, fields...)
 		},
-	))
+	)
 }
 
 // Creates a gRPC interceptor that adds a unique request ID to the context.
 func BuildRequestIDInterceptor() grpc.UnaryServerInterceptor {
+	return BuildRequestIDInterceptorWithConfig(RequestIDConfig{})
+}
+
+// Creates a gRPC interceptor that adds a unique request ID to the context,
+// using the provided configuration.
+//
+// The request ID is taken from the configured header, then from the trace ID
+// of a W3C traceparent header, and is generated as a UUID otherwise.
+func BuildRequestIDInterceptorWithConfig(cfg RequestIDConfig) grpc.UnaryServerInterceptor {
+	header := requestIDHeader(cfg)
+
 	return func(
 		ctx context.Context,
 		req any,
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (any, error) {
-		var requestID string
+		ctx, requestID := contextWithRequestID(ctx, cfg, header)
 
-		// Check if request ID is already present in incoming metadata
-		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			if ids := md.Get("x-request-id"); len(ids) > 0 && ids[0] != "" {
-				requestID = ids[0]
-			}
+		// Call the handler
+		resp, err := handler(ctx, req)
+
+		// Set the request ID in response metadata
+		if err := grpc.SetHeader(ctx, metadata.Pairs(header, requestID)); err != nil {
+			slog.ErrorContext(ctx, "failed to set response header", "error", err)
 		}
 
-		// Generate new request ID if not found in metadata
-		if requestID == "" {
-			requestID = uuid.New().String()
+		return resp, err
+	}
+}
+
+// Creates a gRPC interceptor that adds a unique request ID to the context of
+// streaming calls, using the provided configuration.
+func BuildRequestIDStreamInterceptor(cfg RequestIDConfig) grpc.StreamServerInterceptor {
+	header := requestIDHeader(cfg)
+
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, requestID := contextWithRequestID(ss.Context(), cfg, header)
+
+		// Headers are sent with the first response message, so the request
+		// ID is set before calling the handler.
+		if err := ss.SetHeader(metadata.Pairs(header, requestID)); err != nil {
+			slog.ErrorContext(ctx, "failed to set response header", "error", err)
 		}
 
-		ctx = app.WithLogAttrs(ctx, slog.String("request_id", requestID))
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
 
-		// Call the handler
-		resp, err := handler(ctx, req)
+func requestIDHeader(cfg RequestIDConfig) string {
+	if cfg.Header == "" {
+		return DefaultRequestIDHeader
+	}
+	return strings.ToLower(cfg.Header)
+}
 
-		// Set the request ID in response metadata
-		if err := grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID)); err != nil {
-			slog.Error("failed to set response header", "error", err)
+// contextWithRequestID stores the request ID of the incoming call in ctx and
+// its log attributes.
+func contextWithRequestID(ctx context.Context, cfg RequestIDConfig, header string) (context.Context, string) {
+	var requestID string
+
+	// Check if request ID is already present in incoming metadata
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(header); len(ids) > 0 && ids[0] != "" {
+			requestID = ids[0]
+		} else if !cfg.IgnoreTraceParent {
+			if tps := md.Get(TraceParentHeader); len(tps) > 0 {
+				requestID = traceIDFromTraceParent(tps[0])
+			}
 		}
+	}
 
-		return resp, err
+	// Generate new request ID if not found in metadata
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+	ctx = app.WithLogAttrs(ctx, slog.String("request_id", requestID))
+	return ctx, requestID
+}
+
+// RequestIDFromContext returns the request ID stored in the context by the
+// request ID interceptor.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey).(string)
+	return requestID, ok && requestID != ""
+}
+
+// traceIDFromTraceParent extracts the trace ID from a W3C traceparent value
+// of the form "version-traceid-parentid-flags". It returns an empty string
+// if the value is malformed or the trace ID is all zeros.
+func traceIDFromTraceParent(traceParent string) string {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+	traceID := strings.ToLower(parts[1])
+	if len(traceID) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	if !isHex(parts[0]) || !isHex(traceID) || !isHex(parts[2]) {
+		return ""
+	}
+	if traceID == strings.Repeat("0", 32) {
+		return ""
+	}
+	return traceID
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
 	}
+	return s != ""
 }
@@ -0,0 +1,172 @@
+package grpc_utils_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/poly-workshop/go-webmods/app"
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeServerStream records the headers set by interceptors.
+type fakeServerStream struct {
+	method string
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Method() string { return s.method }
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *fakeServerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *fakeServerStream) SetTrailer(metadata.MD) error { return nil }
+
+func runRequestIDInterceptor(
+	t *testing.T,
+	interceptor grpc.UnaryServerInterceptor,
+	md metadata.MD,
+) (string, *fakeServerStream, map[string]any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger := slog.New(app.NewLogHandler(slog.NewJSONHandler(&buf, nil)))
+
+	stream := &fakeServerStream{method: "/test.Service/Method"}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	if md != nil {
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+
+	var requestID string
+	handler := func(ctx context.Context, req any) (any, error) {
+		requestID, _ = grpc_utils.RequestIDFromContext(ctx)
+		logger.InfoContext(ctx, "handled")
+		return "ok", nil
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: stream.method}
+	if _, err := interceptor(ctx, nil, info, handler); err != nil {
+		t.Fatalf("interceptor returned error: %v", err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log output %q: %v", buf.String(), err)
+	}
+	return requestID, stream, entry
+}
+
+func TestRequestIDInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      grpc_utils.RequestIDConfig
+		md       metadata.MD
+		header   string
+		expected string
+	}{
+		{
+			name:     "existing_request_id",
+			md:       metadata.Pairs("x-request-id", "req-123"),
+			header:   "x-request-id",
+			expected: "req-123",
+		},
+		{
+			name:     "custom_header",
+			cfg:      grpc_utils.RequestIDConfig{Header: "X-Correlation-ID"},
+			md:       metadata.Pairs("x-correlation-id", "corr-456", "x-request-id", "ignored"),
+			header:   "x-correlation-id",
+			expected: "corr-456",
+		},
+		{
+			name: "traceparent",
+			md: metadata.Pairs(
+				"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			),
+			header:   "x-request-id",
+			expected: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "request_id_takes_precedence_over_traceparent",
+			md: metadata.Pairs(
+				"x-request-id", "req-789",
+				"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			),
+			header:   "x-request-id",
+			expected: "req-789",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := grpc_utils.BuildRequestIDInterceptorWithConfig(tt.cfg)
+			requestID, stream, entry := runRequestIDInterceptor(t, interceptor, tt.md)
+
+			if requestID != tt.expected {
+				t.Errorf("RequestIDFromContext() = %q, want %q", requestID, tt.expected)
+			}
+			if got := entry["request_id"]; got != tt.expected {
+				t.Errorf("Log field request_id = %v, want %q", got, tt.expected)
+			}
+			if got := stream.header.Get(tt.header); len(got) != 1 || got[0] != tt.expected {
+				t.Errorf("Response header %s = %v, want [%s]", tt.header, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequestIDInterceptor_Generated(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  grpc_utils.RequestIDConfig
+		md   metadata.MD
+	}{
+		{name: "no_metadata"},
+		{name: "empty_request_id", md: metadata.Pairs("x-request-id", "")},
+		{name: "invalid_traceparent", md: metadata.Pairs("traceparent", "not-a-traceparent")},
+		{
+			name: "zero_trace_id",
+			md: metadata.Pairs(
+				"traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			),
+		},
+		{
+			name: "traceparent_ignored",
+			cfg:  grpc_utils.RequestIDConfig{IgnoreTraceParent: true},
+			md: metadata.Pairs(
+				"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := grpc_utils.BuildRequestIDInterceptorWithConfig(tt.cfg)
+			requestID, stream, entry := runRequestIDInterceptor(t, interceptor, tt.md)
+
+			if len(requestID) != 36 {
+				t.Fatalf("Expected generated UUID request ID, got %q", requestID)
+			}
+			if got := entry["request_id"]; got != requestID {
+				t.Errorf("Log field request_id = %v, want %q", got, requestID)
+			}
+			if got := stream.header.Get("x-request-id"); len(got) != 1 || got[0] != requestID {
+				t.Errorf("Response header x-request-id = %v, want [%s]", got, requestID)
+			}
+		})
+	}
+}
+
+func TestRequestIDFromContext_Missing(t *testing.T) {
+	if id, ok := grpc_utils.RequestIDFromContext(context.Background()); ok {
+		t.Errorf("Expected no request ID, got %q", id)
+	}
+}
@@ -0,0 +1,269 @@
+package grpc_utils
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSCacheTTL         = 10 * time.Minute
+	defaultJWKSMinRefreshPeriod = 30 * time.Second
+	defaultJWKSFetchTimeout     = 10 * time.Second
+)
+
+var errKeyNotFound = errors.New("grpc_utils: signing key not found")
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// Symmetric
+	K string `json:"k"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jwksKeySet holds the keys of a JWKS document, loaded from a file or URL and
+// refreshed after the cache TTL expires or when an unknown key ID is seen.
+type jwksKeySet struct {
+	load             func(ctx context.Context) ([]byte, error)
+	ttl              time.Duration
+	minRefreshPeriod time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]any
+	fetchedAt time.Time
+	checkedAt time.Time
+}
+
+func newFileJWKS(file string, ttl time.Duration) *jwksKeySet {
+	return newJWKS(func(context.Context) ([]byte, error) {
+		return os.ReadFile(file)
+	}, ttl)
+}
+
+func newURLJWKS(url string, ttl time.Duration, client *http.Client) *jwksKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: defaultJWKSFetchTimeout}
+	}
+	return newJWKS(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("grpc_utils: fetching JWKS from %s: %s", url, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}, ttl)
+}
+
+func newJWKS(load func(ctx context.Context) ([]byte, error), ttl time.Duration) *jwksKeySet {
+	if ttl == 0 {
+		ttl = defaultJWKSCacheTTL
+	}
+	return &jwksKeySet{
+		load:             load,
+		ttl:              ttl,
+		minRefreshPeriod: min(ttl, defaultJWKSMinRefreshPeriod),
+	}
+}
+
+// Key returns the key with the given ID, refreshing the key set if it is
+// stale or does not contain the key.
+func (s *jwksKeySet) Key(ctx context.Context, kid string) (any, error) {
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	fresh := time.Since(s.fetchedAt) < s.ttl
+	recentlyChecked := time.Since(s.checkedAt) < s.minRefreshPeriod
+	s.mu.RUnlock()
+
+	if ok && (fresh || recentlyChecked) {
+		return key, nil
+	}
+	if !ok && recentlyChecked {
+		return nil, errKeyNotFound
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		if ok {
+			// Keep serving the stale key if the JWKS source is unavailable
+			return key, nil
+		}
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errKeyNotFound
+}
+
+// lookup must be called with s.mu held.
+func (s *jwksKeySet) lookup(kid string) (any, bool) {
+	if key, ok := s.keys[kid]; ok {
+		return key, true
+	}
+	// Tokens without a key ID can only be matched against a single-key set
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (s *jwksKeySet) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another caller may have refreshed while we were waiting for the lock
+	if time.Since(s.checkedAt) < s.minRefreshPeriod {
+		return nil
+	}
+	s.checkedAt = time.Now()
+
+	data, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(ctx, data)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+// parseJWKS returns the signing keys of a JWKS document by key ID. Keys of
+// unsupported types or curves, such as Ed25519 keys published alongside RSA
+// keys, are skipped, and an error is only returned if no usable key remains.
+func parseJWKS(ctx context.Context, data []byte) (map[string]any, error) {
+	var set jsonWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("grpc_utils: parsing JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	var lastErr error
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			lastErr = fmt.Errorf("grpc_utils: parsing JWK %q: %w", jwk.Kid, err)
+			slog.DebugContext(ctx, "skipping JWK", "kid", jwk.Kid, "kty", jwk.Kty, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, errors.New("grpc_utils: JWKS has no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URLInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URLInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var (
+			curve     elliptic.Curve
+			ecdhCurve ecdh.Curve
+		)
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBase64URLInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URLInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Validate the point by parsing its uncompressed encoding
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x.Bytes()) > size || len(y.Bytes()) > size {
+			return nil, errors.New("invalid EC coordinates")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		x.FillBytes(point[1 : 1+size])
+		y.FillBytes(point[1+size:])
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+func decodeBase64URLInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
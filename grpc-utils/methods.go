@@ -0,0 +1,33 @@
+package grpc_utils
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchMethod reports whether a full gRPC method name such as
+// "/pkg.Service/Method" matches the given pattern.
+//
+// Patterns use path.Match syntax, so "/pkg.Service/*" matches every method of
+// a service and "/pkg.*/*" matches every service in a package. The pattern
+// "*" matches every method.
+func MatchMethod(pattern, fullMethod string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if !strings.ContainsAny(pattern, "*?[\\") {
+		return pattern == fullMethod
+	}
+	matched, err := path.Match(pattern, fullMethod)
+	return err == nil && matched
+}
+
+// matchAnyMethod reports whether fullMethod matches any of the patterns.
+func matchAnyMethod(patterns []string, fullMethod string) bool {
+	for _, pattern := range patterns {
+		if MatchMethod(pattern, fullMethod) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package grpc_utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	rpcTypeUnary        = "unary"
+	rpcTypeClientStream = "client_stream"
+	rpcTypeServerStream = "server_stream"
+	rpcTypeBidiStream   = "bidi_stream"
+)
+
+// MetricsConfig holds configuration for the Prometheus metrics interceptors.
+type MetricsConfig struct {
+	// Namespace is prepended to all metric names. Optional.
+	Namespace string `mapstructure:"namespace"`
+	// Buckets are the latency histogram buckets in seconds.
+	// Optional. Defaults to prometheus.DefBuckets.
+	Buckets []float64 `mapstructure:"buckets"`
+	// Registry is the registerer the metrics are registered on, typically a
+	// *prometheus.Registry. Optional. Defaults to prometheus.DefaultRegisterer.
+	Registry prometheus.Registerer `mapstructure:"-"`
+}
+
+// rpcMetrics holds the RED metrics shared by the server and client side.
+type rpcMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newRPCMetrics(cfg MetricsConfig, side string) *rpcMetrics {
+	buckets := cfg.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	if cfg.Registry != nil {
+		registerer = cfg.Registry
+	}
+
+	subsystem := "grpc_" + side
+	labels := []string{"grpc_service", "grpc_method", "grpc_type"}
+	m := &rpcMetrics{
+		requests: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Total number of RPCs completed, by status code.",
+		}, append(labels, "grpc_code"))),
+		errors: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "Total number of RPCs completed with a non-OK status code.",
+		}, append(labels, "grpc_code"))),
+		latency: registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: subsystem,
+			Name:      "handling_seconds",
+			Help:      "Latency of RPCs until completion.",
+			Buckets:   buckets,
+		}, labels)),
+		inFlight: registerCollector(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: subsystem,
+			Name:      "in_flight_requests",
+			Help:      "Number of RPCs currently in flight.",
+		}, labels)),
+	}
+	return m
+}
+
+// registerCollector registers c, reusing an identical collector that is
+// already registered so metrics can be created more than once per registry.
+func registerCollector[T prometheus.Collector](registerer prometheus.Registerer, c T) T {
+	if err := registerer.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// start records the beginning of an RPC and returns a function recording its
+// completion.
+func (m *rpcMetrics) start(fullMethod, rpcType string) func(err error) {
+	service, method := splitFullMethod(fullMethod)
+	m.inFlight.WithLabelValues(service, method, rpcType).Inc()
+	startTime := time.Now()
+
+	return func(err error) {
+		code := status.Code(err).String()
+		m.inFlight.WithLabelValues(service, method, rpcType).Dec()
+		m.latency.WithLabelValues(service, method, rpcType).Observe(time.Since(startTime).Seconds())
+		m.requests.WithLabelValues(service, method, rpcType, code).Inc()
+		if err != nil {
+			m.errors.WithLabelValues(service, method, rpcType, code).Inc()
+		}
+	}
+}
+
+// ServerMetrics records request counts, error counts, latency and in-flight
+// requests of a gRPC server.
+type ServerMetrics struct {
+	metrics *rpcMetrics
+}
+
+// NewServerMetrics creates and registers gRPC server metrics.
+//
+// Example:
+//
+//	registry := prometheus.NewRegistry()
+//	metrics := grpc_utils.NewServerMetrics(grpc_utils.MetricsConfig{
+//	    Registry: registry,
+//	})
+//	server := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor()),
+//	    grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor()),
+//	)
+func NewServerMetrics(cfg MetricsConfig) *ServerMetrics {
+	return &ServerMetrics{metrics: newRPCMetrics(cfg, "server")}
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor recording metrics for
+// unary calls.
+func (m *ServerMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		done := m.metrics.start(info.FullMethod, rpcTypeUnary)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor recording metrics for
+// streaming calls.
+func (m *ServerMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		done := m.metrics.start(info.FullMethod, streamType(info.IsClientStream, info.IsServerStream))
+		err := handler(srv, ss)
+		done(err)
+		return err
+	}
+}
+
+// ClientMetrics records request counts, error counts, latency and in-flight
+// requests of gRPC client calls.
+type ClientMetrics struct {
+	metrics *rpcMetrics
+}
+
+// NewClientMetrics creates and registers gRPC client metrics.
+//
+// Example:
+//
+//	metrics := grpc_utils.NewClientMetrics(grpc_utils.MetricsConfig{})
+//	conn, err := grpc.NewClient(target,
+//	    grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor()),
+//	    grpc.WithChainStreamInterceptor(metrics.StreamClientInterceptor()),
+//	)
+func NewClientMetrics(cfg MetricsConfig) *ClientMetrics {
+	return &ClientMetrics{metrics: newRPCMetrics(cfg, "client")}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor recording metrics for
+// outgoing unary calls.
+func (m *ClientMetrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		done := m.metrics.start(method, rpcTypeUnary)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		done(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC interceptor recording metrics for
+// outgoing streaming calls. A stream is complete once RecvMsg returns an
+// error, io.EOF included, or the response of a client streaming call, or once
+// the call context is done, so abandoned streams are recorded with the
+// context error.
+func (m *ClientMetrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		done := m.metrics.start(method, streamType(desc.ClientStreams, desc.ServerStreams))
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			done(err)
+			return nil, err
+		}
+		s := &monitoredClientStream{ClientStream: cs, done: done, serverStreams: desc.ServerStreams}
+		s.stop = context.AfterFunc(ctx, func() {
+			s.finish(status.FromContextError(ctx.Err()).Err())
+		})
+		return s, nil
+	}
+}
+
+type monitoredClientStream struct {
+	grpc.ClientStream
+	done func(err error)
+	// serverStreams is false for client streaming calls, which complete with
+	// their single response
+	serverStreams bool
+	once          sync.Once
+	// stop stops the completion on context cancellation
+	stop func() bool
+}
+
+func (s *monitoredClientStream) finish(err error) {
+	s.once.Do(func() { s.done(err) })
+}
+
+func (s *monitoredClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil && !s.serverStreams {
+		s.stop()
+		s.finish(nil)
+		return nil
+	}
+	if err != nil {
+		s.stop()
+		if err == io.EOF {
+			s.finish(nil)
+		} else {
+			s.finish(err)
+		}
+	}
+	return err
+}
+
+// MetricsHandler returns an HTTP handler serving the metrics of the registry,
+// or of the default registry if nil.
+func MetricsHandler(registry *prometheus.Registry) http.Handler {
+	if registry == nil {
+		return promhttp.Handler()
+	}
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// NewMetricsServer creates an HTTP server exposing the metrics of the registry
+// at /metrics, to be run alongside the gRPC server.
+//
+// Example:
+//
+//	metricsServer := grpc_utils.NewMetricsServer(":9090", registry)
+//	go func() {
+//	    if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+//	        slog.Error("metrics server failed", "error", err)
+//	    }
+//	}()
+func NewMetricsServer(addr string, registry *prometheus.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler(registry))
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func streamType(clientStream, serverStream bool) string {
+	switch {
+	case clientStream && serverStream:
+		return rpcTypeBidiStream
+	case clientStream:
+		return rpcTypeClientStream
+	case serverStream:
+		return rpcTypeServerStream
+	default:
+		return rpcTypeUnary
+	}
+}
+
+// splitFullMethod splits "/pkg.Service/Method" into service and method.
+func splitFullMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
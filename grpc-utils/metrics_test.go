@@ -0,0 +1,200 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := grpc_utils.NewServerMetrics(grpc_utils.MetricsConfig{Registry: registry})
+	interceptor := metrics.UnaryServerInterceptor()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.v1.Service/Get"}
+	ok := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	notFound := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	}
+
+	for range 2 {
+		_, _ = interceptor(context.Background(), nil, info, ok)
+	}
+	_, _ = interceptor(context.Background(), nil, info, notFound)
+
+	expected := `
+# HELP grpc_server_requests_total Total number of RPCs completed, by status code.
+# TYPE grpc_server_requests_total counter
+grpc_server_requests_total{grpc_code="NotFound",grpc_method="Get",grpc_service="test.v1.Service",grpc_type="unary"} 1
+grpc_server_requests_total{grpc_code="OK",grpc_method="Get",grpc_service="test.v1.Service",grpc_type="unary"} 2
+# HELP grpc_server_errors_total Total number of RPCs completed with a non-OK status code.
+# TYPE grpc_server_errors_total counter
+grpc_server_errors_total{grpc_code="NotFound",grpc_method="Get",grpc_service="test.v1.Service",grpc_type="unary"} 1
+# HELP grpc_server_in_flight_requests Number of RPCs currently in flight.
+# TYPE grpc_server_in_flight_requests gauge
+grpc_server_in_flight_requests{grpc_method="Get",grpc_service="test.v1.Service",grpc_type="unary"} 0
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"grpc_server_requests_total", "grpc_server_errors_total", "grpc_server_in_flight_requests")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(registry, "grpc_server_handling_seconds"); n != 1 {
+		t.Errorf("Expected 1 latency histogram series, got %d", n)
+	}
+
+	// Creating the metrics again on the same registry reuses the collectors
+	_ = grpc_utils.NewServerMetrics(grpc_utils.MetricsConfig{Registry: registry})
+}
+
+func TestServerMetrics_InFlight(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := grpc_utils.NewServerMetrics(grpc_utils.MetricsConfig{
+		Namespace: "myapp",
+		Registry:  registry,
+	})
+	interceptor := metrics.UnaryServerInterceptor()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.v1.Service/Slow"}
+	_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		expected := `
+# HELP myapp_grpc_server_in_flight_requests Number of RPCs currently in flight.
+# TYPE myapp_grpc_server_in_flight_requests gauge
+myapp_grpc_server_in_flight_requests{grpc_method="Slow",grpc_service="test.v1.Service",grpc_type="unary"} 1
+`
+		if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+			"myapp_grpc_server_in_flight_requests"); err != nil {
+			t.Error(err)
+		}
+		return nil, nil
+	})
+}
+
+func TestClientMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := grpc_utils.NewClientMetrics(grpc_utils.MetricsConfig{Registry: registry})
+	interceptor := metrics.UnaryClientInterceptor()
+
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "down")
+	}
+	_ = interceptor(context.Background(), "/test.v1.Service/Get", nil, nil, nil, invoker)
+
+	expected := `
+# HELP grpc_client_errors_total Total number of RPCs completed with a non-OK status code.
+# TYPE grpc_client_errors_total counter
+grpc_client_errors_total{grpc_code="Unavailable",grpc_method="Get",grpc_service="test.v1.Service",grpc_type="unary"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"grpc_client_errors_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+// recvClientStream is a client stream whose RecvMsg always succeeds.
+type recvClientStream struct {
+	grpc.ClientStream
+}
+
+func (recvClientStream) RecvMsg(any) error { return nil }
+
+func TestClientMetrics_AbandonedStream(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := grpc_utils.NewClientMetrics(grpc_utils.MetricsConfig{Registry: registry})
+	interceptor := metrics.StreamClientInterceptor()
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return recvClientStream{}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	cs, err := interceptor(ctx, desc, nil, "/test.v1.Service/Watch", streamer)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if err := cs.RecvMsg(nil); err != nil {
+		t.Fatalf("Failed to receive: %v", err)
+	}
+
+	// The stream is abandoned without draining it to io.EOF
+	cancel()
+
+	expected := `
+# HELP grpc_client_in_flight_requests Number of RPCs currently in flight.
+# TYPE grpc_client_in_flight_requests gauge
+grpc_client_in_flight_requests{grpc_method="Watch",grpc_service="test.v1.Service",grpc_type="server_stream"} 0
+# HELP grpc_client_requests_total Total number of RPCs completed, by status code.
+# TYPE grpc_client_requests_total counter
+grpc_client_requests_total{grpc_code="Canceled",grpc_method="Watch",grpc_service="test.v1.Service",grpc_type="server_stream"} 1
+`
+	waitFor(t, "abandoned stream to be recorded", func() bool {
+		return testutil.GatherAndCompare(registry, strings.NewReader(expected),
+			"grpc_client_in_flight_requests", "grpc_client_requests_total") == nil
+	})
+}
+
+func TestClientMetrics_ClientStream(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := grpc_utils.NewClientMetrics(grpc_utils.MetricsConfig{Registry: registry})
+	interceptor := metrics.StreamClientInterceptor()
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return recvClientStream{}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	desc := &grpc.StreamDesc{ClientStreams: true}
+	cs, err := interceptor(ctx, desc, nil, "/test.v1.Service/Upload", streamer)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	// CloseAndRecv receives the single response without io.EOF
+	if err := cs.RecvMsg(nil); err != nil {
+		t.Fatalf("Failed to receive: %v", err)
+	}
+	// The usual deferred cancel must not record the call again
+	cancel()
+
+	expected := `
+# HELP grpc_client_in_flight_requests Number of RPCs currently in flight.
+# TYPE grpc_client_in_flight_requests gauge
+grpc_client_in_flight_requests{grpc_method="Upload",grpc_service="test.v1.Service",grpc_type="client_stream"} 0
+# HELP grpc_client_requests_total Total number of RPCs completed, by status code.
+# TYPE grpc_client_requests_total counter
+grpc_client_requests_total{grpc_code="OK",grpc_method="Upload",grpc_service="test.v1.Service",grpc_type="client_stream"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"grpc_client_in_flight_requests", "grpc_client_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewMetricsServer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := grpc_utils.NewServerMetrics(grpc_utils.MetricsConfig{Registry: registry})
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.v1.Service/Get"}
+	_, _ = metrics.UnaryServerInterceptor()(context.Background(), nil, info,
+		func(ctx context.Context, req any) (any, error) { return nil, nil })
+
+	server := httptest.NewServer(grpc_utils.NewMetricsServer(":0", registry).Handler)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to fetch metrics: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `grpc_server_requests_total{grpc_code="OK"`) {
+		t.Errorf("Expected request counter in /metrics output, got:\n%s", body)
+	}
+}
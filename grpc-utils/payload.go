@@ -0,0 +1,263 @@
+package grpc_utils
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	defaultPayloadMaxSize = 4096
+	redactedValue         = "[REDACTED]"
+)
+
+// PayloadLogConfig holds configuration for payload logging.
+//
+// Example config:
+//
+//	grpc:
+//	  payload_log:
+//	    methods: [/orders.v1.OrderService/*]
+//	    max_size: 2048
+//	    sample_rate: 0.1
+//	    redact_fields: [password, card_number]
+type PayloadLogConfig struct {
+	// Methods lists the full method name patterns whose payloads are
+	// logged, see MatchMethod. Payloads of other methods are not logged.
+	Methods []string `mapstructure:"methods"`
+	// MaxSize truncates rendered payloads to this many bytes.
+	// Optional. Defaults to 4096.
+	MaxSize int `mapstructure:"max_size"`
+	// SampleRate is the fraction of calls logged, between 0 and 1.
+	// Optional. Defaults to 1.
+	SampleRate float64 `mapstructure:"sample_rate"`
+	// RedactFields lists proto field names redacted at any depth, including
+	// messages packed in google.protobuf.Any. Fields with the debug_redact
+	// option are always redacted, and Any values of unknown types are
+	// replaced.
+	RedactFields []string `mapstructure:"redact_fields"`
+
+	// Logger is used to log payloads. Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+}
+
+type payloadLogger struct {
+	cfg    PayloadLogConfig
+	redact map[protoreflect.Name]bool
+}
+
+func newPayloadLogger(cfg PayloadLogConfig) *payloadLogger {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultPayloadMaxSize
+	}
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = 1
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	redact := make(map[protoreflect.Name]bool, len(cfg.RedactFields))
+	for _, name := range cfg.RedactFields {
+		redact[protoreflect.Name(name)] = true
+	}
+	return &payloadLogger{cfg: cfg, redact: redact}
+}
+
+// sampled reports whether the payloads of a call to fullMethod are logged.
+func (p *payloadLogger) sampled(fullMethod string) bool {
+	if !matchAnyMethod(p.cfg.Methods, fullMethod) {
+		return false
+	}
+	return p.cfg.SampleRate >= 1 || rand.Float64() < p.cfg.SampleRate
+}
+
+func (p *payloadLogger) log(ctx context.Context, msg, fullMethod string, payload any, attrs ...slog.Attr) {
+	rendered, truncated := p.summary(payload)
+	attrs = append(attrs,
+		slog.String("method", fullMethod),
+		slog.String("payload", rendered),
+		slog.Bool("truncated", truncated),
+	)
+	p.cfg.Logger.LogAttrs(ctx, slog.LevelInfo, msg, attrs...)
+}
+
+// summary returns payload rendered and truncated to MaxSize, and whether it
+// was truncated.
+func (p *payloadLogger) summary(payload any) (string, bool) {
+	rendered := p.render(payload)
+	if len(rendered) <= p.cfg.MaxSize {
+		return rendered, false
+	}
+	// Cutting at a byte offset may split a multi-byte character.
+	return strings.ToValidUTF8(rendered[:p.cfg.MaxSize], ""), true
+}
+
+// render returns payload as JSON with sensitive fields redacted.
+func (p *payloadLogger) render(payload any) string {
+	if msg, ok := payload.(proto.Message); ok {
+		redacted := proto.Clone(msg)
+		p.redactMessage(redacted.ProtoReflect())
+		data, err := protojson.Marshal(redacted)
+		if err != nil {
+			// Rendering the message as a Go value would bypass redaction.
+			return "<unrenderable payload>"
+		}
+		return string(data)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "<unrenderable payload>"
+	}
+	return string(data)
+}
+
+func (p *payloadLogger) redactMessage(m protoreflect.Message) {
+	if m.Descriptor().FullName() == anyFullName {
+		p.redactAny(m)
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if p.isSensitive(fd) {
+			if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+				m.Set(fd, protoreflect.ValueOfString(redactedValue))
+			} else {
+				m.Clear(fd)
+			}
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				p.redactMessage(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				p.redactMessage(mv.Message())
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			p.redactMessage(v.Message())
+		}
+		return true
+	})
+}
+
+// anyFullName is the name of google.protobuf.Any, e.g. the details of a
+// status.
+var anyFullName = (&anypb.Any{}).ProtoReflect().Descriptor().FullName()
+
+// redactAny redacts the message packed in the Any m and packs it again. A
+// message of an unknown type cannot be inspected, so it is replaced with a
+// string holding redactedValue.
+func (p *payloadLogger) redactAny(m protoreflect.Message) {
+	fields := m.Descriptor().Fields()
+	typeURL, value := fields.ByName("type_url"), fields.ByName("value")
+
+	packed := &anypb.Any{
+		TypeUrl: m.Get(typeURL).String(),
+		Value:   m.Get(value).Bytes(),
+	}
+	if packed.TypeUrl == "" {
+		return
+	}
+	inner, err := packed.UnmarshalNew()
+	if err == nil {
+		p.redactMessage(inner.ProtoReflect())
+		packed.Value, err = proto.Marshal(inner)
+	}
+	if err != nil {
+		packed, _ = anypb.New(wrapperspb.String(redactedValue))
+	}
+	m.Set(typeURL, protoreflect.ValueOfString(packed.TypeUrl))
+	m.Set(value, protoreflect.ValueOfBytes(packed.Value))
+}
+
+func (p *payloadLogger) isSensitive(fd protoreflect.FieldDescriptor) bool {
+	if p.redact[fd.Name()] {
+		return true
+	}
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	return ok && opts.GetDebugRedact()
+}
+
+// Creates a gRPC interceptor that logs the request and response payloads of
+// the configured unary methods as JSON, redacting sensitive fields.
+func BuildPayloadLogInterceptor(cfg PayloadLogConfig) grpc.UnaryServerInterceptor {
+	p := newPayloadLogger(cfg)
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if !p.sampled(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		p.log(ctx, "request payload", info.FullMethod, req)
+		resp, err := handler(ctx, req)
+		if err != nil {
+			p.log(ctx, "response payload", info.FullMethod, status.Convert(err).Proto(),
+				slog.String("code", status.Code(err).String()))
+			return resp, err
+		}
+		p.log(ctx, "response payload", info.FullMethod, resp, slog.String("code", "OK"))
+		return resp, nil
+	}
+}
+
+// Creates a gRPC interceptor that logs every message received and sent on
+// the configured streaming methods as JSON, redacting sensitive fields.
+func BuildPayloadLogStreamInterceptor(cfg PayloadLogConfig) grpc.StreamServerInterceptor {
+	p := newPayloadLogger(cfg)
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if !p.sampled(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		return handler(srv, &payloadLoggingServerStream{
+			ServerStream: ss,
+			logger:       p,
+			fullMethod:   info.FullMethod,
+		})
+	}
+}
+
+type payloadLoggingServerStream struct {
+	grpc.ServerStream
+	logger     *payloadLogger
+	fullMethod string
+}
+
+func (s *payloadLoggingServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.logger.log(s.Context(), "stream message received", s.fullMethod, m)
+	}
+	return err
+}
+
+func (s *payloadLoggingServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.logger.log(s.Context(), "stream message sent", s.fullMethod, m)
+	}
+	return err
+}
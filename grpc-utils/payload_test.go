@@ -0,0 +1,223 @@
+package grpc_utils_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// loginRequestDescriptor builds
+//
+//	message Credentials {
+//	  string username = 1;
+//	  string password = 2;
+//	  string token = 3 [debug_redact = true];
+//	}
+//	message LoginRequest {
+//	  Credentials credentials = 1;
+//	  repeated Credentials fallbacks = 2;
+//	}
+func loginRequestDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("payload_test.proto"),
+		Package: proto.String("test.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Credentials"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("username"), Number: proto.Int32(1), Type: str, Label: optional, JsonName: proto.String("username")},
+					{Name: proto.String("password"), Number: proto.Int32(2), Type: str, Label: optional, JsonName: proto.String("password")},
+					{
+						Name: proto.String("token"), Number: proto.Int32(3), Type: str, Label: optional, JsonName: proto.String("token"),
+						Options: &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)},
+					},
+				},
+			},
+			{
+				Name: proto.String("LoginRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("credentials"), Number: proto.Int32(1), Type: msg, Label: optional, TypeName: proto.String(".test.v1.Credentials"), JsonName: proto.String("credentials")},
+					{Name: proto.String("fallbacks"), Number: proto.Int32(2), Type: msg, Label: repeated, TypeName: proto.String(".test.v1.Credentials"), JsonName: proto.String("fallbacks")},
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to build descriptor: %v", err)
+	}
+	return file.Messages().ByName("LoginRequest")
+}
+
+func newLoginRequest(t *testing.T) proto.Message {
+	t.Helper()
+	desc := loginRequestDescriptor(t)
+	credDesc := desc.Fields().ByName("credentials").Message()
+
+	newCredentials := func(user string) protoreflect.Message {
+		cred := dynamicpb.NewMessage(credDesc)
+		cred.Set(credDesc.Fields().ByName("username"), protoreflect.ValueOfString(user))
+		cred.Set(credDesc.Fields().ByName("password"), protoreflect.ValueOfString("hunter2"))
+		cred.Set(credDesc.Fields().ByName("token"), protoreflect.ValueOfString("secret-token"))
+		return cred
+	}
+
+	req := dynamicpb.NewMessage(desc)
+	req.Set(desc.Fields().ByName("credentials"), protoreflect.ValueOfMessage(newCredentials("alice")))
+	fallbacks := req.Mutable(desc.Fields().ByName("fallbacks")).List()
+	fallbacks.Append(protoreflect.ValueOfMessage(newCredentials("bob")))
+	return req
+}
+
+func capturePayloadLogs(t *testing.T, cfg grpc_utils.PayloadLogConfig, method string, req any, handler grpc.UnaryHandler) []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	interceptor := grpc_utils.BuildPayloadLogInterceptor(cfg)
+	_, _ = interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestPayloadLogInterceptor_Redaction(t *testing.T) {
+	req := newLoginRequest(t)
+	cfg := grpc_utils.PayloadLogConfig{
+		Methods:      []string{"/auth.v1.AuthService/*"},
+		RedactFields: []string{"password"},
+	}
+	entries := capturePayloadLogs(t, cfg, "/auth.v1.AuthService/Login", req, func(ctx context.Context, req any) (any, error) {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	})
+	if len(entries) != 2 {
+		t.Fatalf("Expected request and response entries, got %v", entries)
+	}
+
+	payload := entries[0]["payload"].(string)
+	if strings.Contains(payload, "hunter2") || strings.Contains(payload, "secret-token") {
+		t.Errorf("Expected sensitive fields to be redacted, got %s", payload)
+	}
+	if !strings.Contains(payload, "alice") || !strings.Contains(payload, "bob") {
+		t.Errorf("Expected other fields to be logged, got %s", payload)
+	}
+	if strings.Count(payload, "[REDACTED]") != 4 {
+		t.Errorf("Expected password and token redacted in all credentials, got %s", payload)
+	}
+	if entries[1]["msg"] != "response payload" || !strings.Contains(entries[1]["payload"].(string), "SERVING") {
+		t.Errorf("Expected response payload entry, got %v", entries[1])
+	}
+
+	// The original request must be left untouched.
+	cred := req.ProtoReflect().Get(req.ProtoReflect().Descriptor().Fields().ByName("credentials")).Message()
+	if got := cred.Get(cred.Descriptor().Fields().ByName("password")).String(); got != "hunter2" {
+		t.Errorf("Expected request to be unchanged, got password %q", got)
+	}
+}
+
+func TestPayloadLogInterceptor_RedactsAny(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "invalid credentials").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "password", Description: "hunter2 is too short"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("WithDetails failed: %v", err)
+	}
+	details := st.Proto()
+	details.Details = append(details.Details, &anypb.Any{
+		TypeUrl: "type.googleapis.com/unknown.v1.Secret",
+		Value:   []byte("secret-token"),
+	})
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, status.ErrorProto(details)
+	}
+
+	cfg := grpc_utils.PayloadLogConfig{Methods: []string{"*"}, RedactFields: []string{"description"}}
+	entries := capturePayloadLogs(t, cfg, "/auth.v1.AuthService/Login", &healthpb.HealthCheckRequest{}, handler)
+	if len(entries) != 2 {
+		t.Fatalf("Expected request and response entries, got %v", entries)
+	}
+	payload := entries[1]["payload"].(string)
+	if strings.Contains(payload, "hunter2") || strings.Contains(payload, "c2VjcmV0LXRva2Vu") {
+		t.Errorf("Expected sensitive details to be redacted, got %s", payload)
+	}
+	if !strings.Contains(payload, `"field":"password"`) || strings.Count(payload, "[REDACTED]") != 2 {
+		t.Errorf("Expected known details rendered and unknown details redacted, got %s", payload)
+	}
+}
+
+func TestPayloadLogInterceptor_TruncationAndMethods(t *testing.T) {
+	req := &healthpb.HealthCheckRequest{Service: strings.Repeat("x", 100)}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+
+	entries := capturePayloadLogs(t, grpc_utils.PayloadLogConfig{Methods: []string{"*"}, MaxSize: 20},
+		"/grpc.health.v1.Health/Check", req, handler)
+	if len(entries) != 2 {
+		t.Fatalf("Expected request and response entries, got %v", entries)
+	}
+	if payload := entries[0]["payload"].(string); len(payload) != 20 || entries[0]["truncated"] != true {
+		t.Errorf("Expected payload truncated to 20 bytes, got %q", payload)
+	}
+	if entries[1]["code"] != "NotFound" {
+		t.Errorf("Expected error code in response entry, got %v", entries[1])
+	}
+
+	entries = capturePayloadLogs(t, grpc_utils.PayloadLogConfig{Methods: []string{"/orders.v1.OrderService/*"}},
+		"/grpc.health.v1.Health/Check", req, handler)
+	if len(entries) != 0 {
+		t.Errorf("Expected no payload logs for unlisted methods, got %v", entries)
+	}
+}
+
+func TestPayloadLogInterceptor_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := grpc_utils.BuildPayloadLogInterceptor(grpc_utils.PayloadLogConfig{
+		Methods:    []string{"*"},
+		SampleRate: 0.2,
+		Logger:     slog.New(slog.NewTextHandler(&buf, nil)),
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	handler := func(ctx context.Context, req any) (any, error) { return &healthpb.HealthCheckResponse{}, nil }
+	for range 1000 {
+		_, _ = interceptor(context.Background(), &healthpb.HealthCheckRequest{}, info, handler)
+	}
+
+	logged := strings.Count(buf.String(), "request payload")
+	if logged < 100 || logged > 300 {
+		t.Errorf("Expected about 20%% of 1000 calls to be logged, got %d", logged)
+	}
+}
@@ -0,0 +1,370 @@
+package grpc_utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Rate limit key types select which caller attribute a limit is counted by.
+const (
+	RateLimitByCaller = "caller"
+	RateLimitByUser   = "user"
+	RateLimitByAPIKey = "api_key"
+	RateLimitByPeer   = "peer"
+	RateLimitByMethod = "method"
+)
+
+const (
+	defaultRateLimitKeyPrefix    = "ratelimit:"
+	defaultRateLimitAPIKeyHeader = "x-api-key"
+	rateLimitRetryAfterHeader    = "retry-after"
+	localRateLimitSweepInterval  = time.Minute
+	rateLimitWarnInterval        = time.Minute
+)
+
+// gcraScript implements the generic cell rate algorithm. The theoretical
+// arrival time (TAT) of the next request is stored per key, using the Redis
+// server clock so that all replicas share the same time base.
+//
+// KEYS[1]: limit key
+// ARGV[1]: emission interval in microseconds
+// ARGV[2]: burst tolerance in microseconds
+//
+// Returns {allowed, retry_after_us}.
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local emission = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+  tat = now
+end
+
+local new_tat = tat + emission
+local allow_at = new_tat - tolerance - emission
+if allow_at > now then
+  return {0, allow_at - now}
+end
+
+local ttl = math.max(1, math.ceil((new_tat - now) / 1000))
+redis.call('SET', KEYS[1], string.format('%.0f', new_tat), 'PX', ttl)
+return {1, 0}
+`)
+
+// RateLimit configures the limit applied to the methods matching a pattern.
+type RateLimit struct {
+	// Method is a full method name pattern, see MatchMethod. All methods
+	// matching it share one budget per caller, e.g. "*" limits the calls of
+	// a caller to any method.
+	Method string `mapstructure:"method"`
+	// Rate is the number of requests allowed per Period. Rates above one
+	// request per microsecond are capped to it.
+	Rate int `mapstructure:"rate"`
+	// Period is the window Rate applies to. Optional. Defaults to 1 second.
+	Period time.Duration `mapstructure:"period"`
+	// Burst is the number of requests that may be made at once.
+	// Optional. Defaults to Rate.
+	Burst int `mapstructure:"burst"`
+	// By selects the caller attribute the limit is counted by: "caller"
+	// (user, then API key, then peer IP), "user", "api_key", "peer" or
+	// "method" (shared by all callers). Optional. Defaults to "caller".
+	By string `mapstructure:"by"`
+}
+
+// RateLimitConfig holds configuration for the rate limiting interceptor.
+//
+// Example config:
+//
+//	grpc:
+//	  ratelimit:
+//	    limits:
+//	      - method: /auth.v1.AuthService/Login
+//	        rate: 5
+//	        period: 1m
+//	        by: peer
+//	      - method: "*"
+//	        rate: 100
+//	        burst: 200
+type RateLimitConfig struct {
+	// Limits are evaluated in order and the first limit matching the method
+	// applies. Methods matching no limit are not rate limited.
+	Limits []RateLimit `mapstructure:"limits"`
+	// KeyPrefix is prepended to Redis keys. Optional. Defaults to "ratelimit:".
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// APIKeyHeader is the metadata key carrying the caller API key.
+	// Optional. Defaults to "x-api-key".
+	APIKeyHeader string `mapstructure:"api_key_header"`
+	// Redis is the client used to share limits across replicas, typically
+	// created with redis_client.NewRDB. If nil, or while Redis is unavailable,
+	// limits are enforced in memory per replica.
+	Redis redis.UniversalClient `mapstructure:"-"`
+}
+
+// RateLimiter enforces per-method and per-caller rate limits.
+type RateLimiter struct {
+	cfg   RateLimitConfig
+	local *localGCRA
+	// lastWarning is the time of the last fallback warning in Unix
+	// nanoseconds, so an unavailable Redis does not flood the logs.
+	lastWarning atomic.Int64
+}
+
+// NewRateLimiter creates a new rate limiter with the provided configuration.
+//
+// Example:
+//
+//	limiter := grpc_utils.NewRateLimiter(grpc_utils.RateLimitConfig{
+//	    Limits: []grpc_utils.RateLimit{
+//	        {Method: "*", Rate: 100, Burst: 200},
+//	    },
+//	    Redis: redis_client.NewRDB(redis_client.Config{
+//	        Urls: []string{"localhost:6379"},
+//	    }),
+//	})
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultRateLimitKeyPrefix
+	}
+	if cfg.APIKeyHeader == "" {
+		cfg.APIKeyHeader = defaultRateLimitAPIKeyHeader
+	}
+	return &RateLimiter{
+		cfg:   cfg,
+		local: newLocalGCRA(),
+	}
+}
+
+// Allow reports whether a call to fullMethod may proceed and, if not, how
+// long the caller should wait before retrying.
+func (l *RateLimiter) Allow(ctx context.Context, fullMethod string) (bool, time.Duration) {
+	var limit *RateLimit
+	for i := range l.cfg.Limits {
+		if MatchMethod(l.cfg.Limits[i].Method, fullMethod) {
+			limit = &l.cfg.Limits[i]
+			break
+		}
+	}
+	if limit == nil || limit.Rate <= 0 {
+		return true, 0
+	}
+
+	period := limit.Period
+	if period <= 0 {
+		period = time.Second
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Rate
+	}
+	// Redis stores microseconds, so shorter intervals would disable the limit.
+	emission := max(period/time.Duration(limit.Rate), time.Microsecond)
+	tolerance := emission * time.Duration(burst-1)
+
+	key := l.cfg.KeyPrefix + limit.Method + ":" + l.callerKey(ctx, limit.By)
+
+	if l.cfg.Redis != nil {
+		allowed, retryAfter, err := l.allowRedis(ctx, key, emission, tolerance)
+		if err == nil {
+			return allowed, retryAfter
+		}
+		l.warnFallback(ctx, err)
+	}
+	return l.local.allow(key, emission, tolerance, time.Now())
+}
+
+// warnFallback logs a Redis failure at most once per rateLimitWarnInterval.
+func (l *RateLimiter) warnFallback(ctx context.Context, err error) {
+	now := time.Now().UnixNano()
+	last := l.lastWarning.Load()
+	if now-last < int64(rateLimitWarnInterval) || !l.lastWarning.CompareAndSwap(last, now) {
+		return
+	}
+	slog.WarnContext(ctx, "rate limiter falling back to local limits", "error", err)
+}
+
+func (l *RateLimiter) allowRedis(
+	ctx context.Context,
+	key string,
+	emission, tolerance time.Duration,
+) (bool, time.Duration, error) {
+	res, err := gcraScript.Run(
+		ctx, l.cfg.Redis, []string{key},
+		emission.Microseconds(), tolerance.Microseconds(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Microsecond, nil
+}
+
+func (l *RateLimiter) callerKey(ctx context.Context, by string) string {
+	switch by {
+	case RateLimitByMethod:
+		return "all"
+	case RateLimitByUser:
+		if user := userFromContext(ctx); user != "" {
+			return "user:" + user
+		}
+		return "user:anonymous"
+	case RateLimitByAPIKey:
+		if apiKey := l.apiKeyFromContext(ctx); apiKey != "" {
+			return "api_key:" + apiKey
+		}
+		return "api_key:none"
+	case RateLimitByPeer:
+		return "peer:" + peerIPFromContext(ctx)
+	default:
+		if user := userFromContext(ctx); user != "" {
+			return "user:" + user
+		}
+		if apiKey := l.apiKeyFromContext(ctx); apiKey != "" {
+			return "api_key:" + apiKey
+		}
+		return "peer:" + peerIPFromContext(ctx)
+	}
+}
+
+// apiKeyFromContext returns a hash of the API key so that raw keys are never
+// written to Redis.
+func (l *RateLimiter) apiKeyFromContext(ctx context.Context) string {
+	apiKey := metadataValue(ctx, l.cfg.APIKeyHeader)
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+func userFromContext(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.Subject
+	}
+	return ""
+}
+
+func peerIPFromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func (l *RateLimiter) check(ctx context.Context, fullMethod string) error {
+	allowed, retryAfter := l.Allow(ctx, fullMethod)
+	if allowed {
+		return nil
+	}
+
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if err := grpc.SetHeader(ctx, metadata.Pairs(
+		rateLimitRetryAfterHeader, strconv.FormatInt(seconds, 10),
+	)); err != nil {
+		slog.DebugContext(ctx, "failed to set retry-after header", "error", err)
+	}
+
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return st.Err()
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that rejects unary calls
+// exceeding their rate limit with codes.ResourceExhausted.
+func (l *RateLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := l.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that rejects streaming
+// calls exceeding their rate limit with codes.ResourceExhausted.
+func (l *RateLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := l.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// localGCRA is the in-memory implementation of the generic cell rate
+// algorithm used when Redis is not configured or unavailable.
+type localGCRA struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+func newLocalGCRA() *localGCRA {
+	return &localGCRA{tats: map[string]time.Time{}}
+}
+
+func (g *localGCRA) allow(
+	key string,
+	emission, tolerance time.Duration,
+	now time.Time,
+) (bool, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.lastSweep) > localRateLimitSweepInterval {
+		for k, tat := range g.tats {
+			if tat.Before(now) {
+				delete(g.tats, k)
+			}
+		}
+		g.lastSweep = now
+	}
+
+	tat, ok := g.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(emission)
+	allowAt := newTAT.Add(-tolerance - emission)
+	if allowAt.After(now) {
+		return false, allowAt.Sub(now)
+	}
+	g.tats[key] = newTAT
+	return true, 0
+}
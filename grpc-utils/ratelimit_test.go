@@ -0,0 +1,238 @@
+package grpc_utils_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	redis_client "github.com/poly-workshop/go-webmods/redis-client"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// startRedisContainer starts a Redis container for testing, skipping the test
+// if no container runtime is available.
+func startRedisContainer(t *testing.T) string {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7-alpine",
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForLog("Ready to accept connections"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to start Redis container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Errorf("Failed to terminate container: %v", err)
+		}
+	})
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get container host: %v", err)
+	}
+	port, err := container.MappedPort(ctx, "6379")
+	if err != nil {
+		t.Fatalf("Failed to get container port: %v", err)
+	}
+	return host + ":" + port.Port()
+}
+
+func peerContext(ip string) context.Context {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345}
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+}
+
+func assertAllowed(t *testing.T, limiter *grpc_utils.RateLimiter, ctx context.Context, method string, want int) {
+	t.Helper()
+	for i := range want {
+		if allowed, _ := limiter.Allow(ctx, method); !allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	allowed, retryAfter := limiter.Allow(ctx, method)
+	if allowed {
+		t.Fatalf("Expected request %d to be rejected", want+1)
+	}
+	if retryAfter <= 0 {
+		t.Errorf("Expected positive retry-after, got %v", retryAfter)
+	}
+}
+
+func TestRateLimiter_Local(t *testing.T) {
+	limiter := grpc_utils.NewRateLimiter(grpc_utils.RateLimitConfig{
+		Limits: []grpc_utils.RateLimit{
+			{Method: "/test.Service/Login", Rate: 2, Period: time.Minute, By: grpc_utils.RateLimitByPeer},
+			{Method: "/test.Service/*", Rate: 10, Period: time.Minute, Burst: 3},
+		},
+	})
+
+	t.Run("PerPeer", func(t *testing.T) {
+		assertAllowed(t, limiter, peerContext("10.0.0.1"), "/test.Service/Login", 2)
+		// Another peer has its own budget
+		assertAllowed(t, limiter, peerContext("10.0.0.2"), "/test.Service/Login", 2)
+	})
+
+	t.Run("Burst", func(t *testing.T) {
+		assertAllowed(t, limiter, peerContext("10.0.0.3"), "/test.Service/List", 3)
+	})
+
+	t.Run("PerUser", func(t *testing.T) {
+		claims := &grpc_utils.Claims{}
+		claims.Subject = "user-1"
+		ctx := grpc_utils.ContextWithClaims(peerContext("10.0.0.4"), claims)
+		assertAllowed(t, limiter, ctx, "/test.Service/Get", 3)
+
+		// Same peer but a different user is counted separately
+		claims2 := &grpc_utils.Claims{}
+		claims2.Subject = "user-2"
+		ctx2 := grpc_utils.ContextWithClaims(peerContext("10.0.0.4"), claims2)
+		if allowed, _ := limiter.Allow(ctx2, "/test.Service/Get"); !allowed {
+			t.Error("Expected request from another user to be allowed")
+		}
+	})
+
+	t.Run("SharedByPattern", func(t *testing.T) {
+		// All methods matching a limit share its budget
+		ctx := peerContext("10.0.0.6")
+		for _, method := range []string{"/test.Service/A", "/test.Service/B", "/test.Service/C"} {
+			if allowed, _ := limiter.Allow(ctx, method); !allowed {
+				t.Fatalf("Expected call to %s to be allowed", method)
+			}
+		}
+		if allowed, _ := limiter.Allow(ctx, "/test.Service/D"); allowed {
+			t.Error("Expected call to another matching method to be rejected")
+		}
+	})
+
+	t.Run("PerAPIKey", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(peerContext("10.0.0.5"), metadata.Pairs("x-api-key", "key-1"))
+		assertAllowed(t, limiter, ctx, "/test.Service/Update", 3)
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		for range 100 {
+			if allowed, _ := limiter.Allow(context.Background(), "/other.Service/Method"); !allowed {
+				t.Fatal("Expected method without limit to be allowed")
+			}
+		}
+	})
+}
+
+func TestRateLimiter_Interceptor(t *testing.T) {
+	limiter := grpc_utils.NewRateLimiter(grpc_utils.RateLimitConfig{
+		Limits: []grpc_utils.RateLimit{
+			{Method: "*", Rate: 1, Period: time.Minute, By: grpc_utils.RateLimitByMethod},
+		},
+	})
+	interceptor := limiter.UnaryServerInterceptor()
+
+	call := func() (*fakeServerStream, error) {
+		stream := &fakeServerStream{method: "/test.Service/Method"}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		info := &grpc.UnaryServerInfo{FullMethod: stream.method}
+		_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			return "ok", nil
+		})
+		return stream, err
+	}
+
+	if _, err := call(); err != nil {
+		t.Fatalf("Expected first call to succeed, got %v", err)
+	}
+
+	stream, err := call()
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %v", err)
+	}
+	if got := stream.header.Get("retry-after"); len(got) != 1 || got[0] != "60" {
+		t.Errorf("Expected retry-after header 60, got %v", got)
+	}
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if ri, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = ri
+		}
+	}
+	if retryInfo == nil || retryInfo.RetryDelay.AsDuration() <= 0 {
+		t.Errorf("Expected RetryInfo detail with positive delay, got %v", st.Details())
+	}
+}
+
+func TestRateLimiter_RedisUnavailableFallsBack(t *testing.T) {
+	limiter := grpc_utils.NewRateLimiter(grpc_utils.RateLimitConfig{
+		Limits: []grpc_utils.RateLimit{
+			{Method: "*", Rate: 2, Period: time.Minute},
+		},
+		// Nothing listens on this port, so every Redis call fails
+		Redis: redis_client.NewRDB(redis_client.Config{Urls: []string{"127.0.0.1:1"}}),
+	})
+
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+
+	assertAllowed(t, limiter, peerContext("10.0.0.1"), "/test.Service/Method", 2)
+	if got := strings.Count(buf.String(), "falling back"); got != 1 {
+		t.Errorf("Expected one fallback warning, got %d", got)
+	}
+}
+
+func TestRateLimiter_Redis(t *testing.T) {
+	addr := startRedisContainer(t)
+	rdb := redis_client.NewRDB(redis_client.Config{Urls: []string{addr}})
+
+	cfg := grpc_utils.RateLimitConfig{
+		Limits: []grpc_utils.RateLimit{
+			{Method: "/test.Service/Fast", Rate: 10_000_000, Period: time.Second},
+			{Method: "*", Rate: 3, Period: time.Minute},
+		},
+		Redis: rdb,
+	}
+	// Two limiters emulate two replicas sharing the same limits
+	replica1 := grpc_utils.NewRateLimiter(cfg)
+	replica2 := grpc_utils.NewRateLimiter(cfg)
+
+	ctx := peerContext("10.0.0.1")
+	for i, limiter := range []*grpc_utils.RateLimiter{replica1, replica2, replica1} {
+		if allowed, _ := limiter.Allow(ctx, "/test.Service/Method"); !allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	if allowed, retryAfter := replica2.Allow(ctx, "/test.Service/Method"); allowed || retryAfter <= 0 {
+		t.Errorf("Expected limit to be shared across replicas, got allowed=%v retryAfter=%v", allowed, retryAfter)
+	}
+
+	// Sub-microsecond emission intervals are capped instead of failing the
+	// script and falling back to local limits.
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+	if allowed, _ := replica1.Allow(ctx, "/test.Service/Fast"); !allowed {
+		t.Fatal("Expected fast call to be allowed")
+	}
+	if strings.Contains(buf.String(), "falling back") {
+		t.Errorf("Expected fast limit to be enforced in Redis, got %s", buf.String())
+	}
+}
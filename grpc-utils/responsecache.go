@@ -0,0 +1,258 @@
+package grpc_utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+	"github.com/poly-workshop/go-webmods/app"
+	redis_client "github.com/poly-workshop/go-webmods/redis-client"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	defaultResponseCacheKeyPrefix = "grpc_cache:"
+	defaultResponseCacheTTL       = time.Minute
+)
+
+// CachedMethod sets the TTL of cached responses for methods matching a
+// pattern.
+type CachedMethod struct {
+	// Method is a full method name pattern, see MatchMethod.
+	Method string `mapstructure:"method"`
+	// TTL is how long responses are cached. Optional. Defaults to 1 minute.
+	// Responses with a TTL below the LocalCacheTTL of the cache are only
+	// stored in Redis, so every replica sees them expire on time.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// ResponseCacheConfig holds configuration for the response cache interceptor.
+//
+// Example config:
+//
+//	grpc:
+//	  response_cache:
+//	    metadata_keys: [accept-language]
+//	    methods:
+//	      - method: /catalog.v1.CatalogService/ListProducts
+//	        ttl: 5m
+//	      - method: /catalog.v1.CatalogService/Get*
+//	        ttl: 1m
+type ResponseCacheConfig struct {
+	// Methods lists the cached methods. The first match applies, and other
+	// methods are not cached. Only cache methods whose response depends
+	// solely on the request, the tenant and MetadataKeys.
+	Methods []CachedMethod `mapstructure:"methods"`
+	// MetadataKeys lists the metadata keys whose values are part of the
+	// cache key. The tenant stored with app.WithTenant is always included.
+	MetadataKeys []string `mapstructure:"metadata_keys"`
+	// PerCaller caches responses separately for each authenticated subject.
+	PerCaller bool `mapstructure:"per_caller"`
+	// KeyPrefix is prepended to cache keys. Optional. Defaults to
+	// "grpc_cache:".
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// Metrics configures the cache hit and miss metrics.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	// Cache stores the responses, typically created with
+	// redis_client.NewCache. Required.
+	Cache *redis_client.Cache `mapstructure:"-"`
+	// Logger logs cache failures. Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+}
+
+// ResponseCache serves repeated unary calls of read-only methods from a
+// redis_client.Cache.
+type ResponseCache struct {
+	cfg      ResponseCacheConfig
+	requests *prometheus.CounterVec
+}
+
+// NewResponseCache creates a response cache interceptor provider.
+//
+// Example:
+//
+//	responseCache, err := grpc_utils.NewResponseCache(grpc_utils.ResponseCacheConfig{
+//	    Methods: []grpc_utils.CachedMethod{
+//	        {Method: "/catalog.v1.CatalogService/ListProducts", TTL: 5 * time.Minute},
+//	    },
+//	    Cache: redis_client.NewCache(redis_client.CacheConfig{Redis: rdb}),
+//	})
+func NewResponseCache(cfg ResponseCacheConfig) (*ResponseCache, error) {
+	if cfg.Cache == nil {
+		return nil, errors.New("grpc_utils: response cache requires a cache")
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultResponseCacheKeyPrefix
+	}
+	for i, key := range cfg.MetadataKeys {
+		cfg.MetadataKeys[i] = strings.ToLower(key)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	if cfg.Metrics.Registry != nil {
+		registerer = cfg.Metrics.Registry
+	}
+	return &ResponseCache{
+		cfg: cfg,
+		requests: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Metrics.Namespace,
+			Subsystem: "grpc_server",
+			Name:      "cache_requests_total",
+			Help:      "Total number of cacheable RPCs by cache result.",
+		}, []string{"grpc_service", "grpc_method", "result"})),
+	}, nil
+}
+
+func (c *ResponseCache) ttl(fullMethod string) (time.Duration, bool) {
+	for _, m := range c.cfg.Methods {
+		if MatchMethod(m.Method, fullMethod) {
+			if m.TTL <= 0 {
+				return defaultResponseCacheTTL, true
+			}
+			return m.TTL, true
+		}
+	}
+	return 0, false
+}
+
+// key returns the cache key of a call to fullMethod with req in ctx.
+func (c *ResponseCache) key(ctx context.Context, fullMethod string, req any) (string, error) {
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	tenant, _ := app.TenantFromContext(ctx)
+	h.Write([]byte(tenant + "\x00"))
+	if c.cfg.PerCaller {
+		h.Write([]byte(userFromContext(ctx) + "\x00"))
+	}
+	for _, key := range c.cfg.MetadataKeys {
+		h.Write([]byte(metadataValue(ctx, key) + "\x00"))
+	}
+	h.Write([]byte(fingerprint))
+	return c.cfg.KeyPrefix + fullMethod + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Invalidate removes the cached response of fullMethod for req, keyed with
+// the tenant and metadata of ctx. Handlers of mutating methods call it with
+// their own context to drop the entries they made stale. The removal is
+// broadcast on the refresh event channel of the cache, so other instances
+// drop their local copies too.
+//
+// Example:
+//
+//	err := responseCache.Invalidate(ctx, "/catalog.v1.CatalogService/GetProduct",
+//	    &catalogv1.GetProductRequest{Id: req.GetId()})
+func (c *ResponseCache) Invalidate(ctx context.Context, fullMethod string, req proto.Message) error {
+	key, err := c.key(ctx, fullMethod, req)
+	if err != nil {
+		return err
+	}
+	return c.cfg.Cache.Delete(ctx, key)
+}
+
+// UnaryServerInterceptor returns an interceptor that serves calls of the
+// configured methods from the cache, and caches successful responses.
+//
+// Calls whose request or response is not a protobuf message are not cached,
+// and cache failures fall back to calling the handler.
+func (c *ResponseCache) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ttl, ok := c.ttl(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		key, err := c.key(ctx, info.FullMethod, req)
+		if err != nil {
+			c.cfg.Logger.WarnContext(ctx, "response cache disabled for request", "error", err)
+			return handler(ctx, req)
+		}
+
+		service, method := splitFullMethod(info.FullMethod)
+		// The local cache keeps entries for its own TTL, which would outlive
+		// shorter method TTLs.
+		skipLocal := ttl < c.cfg.Cache.LocalCacheTTL()
+		if resp, ok := c.load(ctx, key, skipLocal); ok {
+			c.requests.WithLabelValues(service, method, "hit").Inc()
+			return resp, nil
+		}
+		c.requests.WithLabelValues(service, method, "miss").Inc()
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		c.store(ctx, key, resp, ttl, skipLocal)
+		return resp, nil
+	}
+}
+
+func (c *ResponseCache) load(ctx context.Context, key string, skipLocal bool) (any, bool) {
+	var data []byte
+	get := c.cfg.Cache.Get
+	if skipLocal {
+		get = c.cfg.Cache.GetSkippingLocalCache
+	}
+	if err := get(ctx, key, &data); err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			c.cfg.Logger.WarnContext(ctx, "failed to load cached response", "error", err)
+		}
+		return nil, false
+	}
+	var wrapped anypb.Any
+	if err := proto.Unmarshal(data, &wrapped); err != nil {
+		c.cfg.Logger.WarnContext(ctx, "failed to decode cached response", "error", err)
+		return nil, false
+	}
+	resp, err := wrapped.UnmarshalNew()
+	if err != nil {
+		c.cfg.Logger.WarnContext(ctx, "failed to decode cached response", "error", err)
+		return nil, false
+	}
+	return resp, true
+}
+
+func (c *ResponseCache) store(ctx context.Context, key string, resp any, ttl time.Duration, skipLocal bool) {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		c.cfg.Logger.WarnContext(ctx, "response cache disabled for response",
+			"error", "response is not a protobuf message")
+		return
+	}
+	wrapped, err := anypb.New(msg)
+	if err != nil {
+		c.cfg.Logger.WarnContext(ctx, "failed to encode response for cache", "error", err)
+		return
+	}
+	data, err := proto.Marshal(wrapped)
+	if err != nil {
+		c.cfg.Logger.WarnContext(ctx, "failed to encode response for cache", "error", err)
+		return
+	}
+	set := c.cfg.Cache.Set
+	if skipLocal {
+		set = c.cfg.Cache.SetSkippingLocalCache
+	}
+	// The response is cached even if the client went away.
+	if err := set(context.WithoutCancel(ctx), key, data, ttl); err != nil {
+		c.cfg.Logger.WarnContext(ctx, "failed to store cached response", "error", err)
+	}
+}
@@ -0,0 +1,121 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/poly-workshop/go-webmods/app"
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	redis_client "github.com/poly-workshop/go-webmods/redis-client"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewResponseCache_RequiresCache(t *testing.T) {
+	if _, err := grpc_utils.NewResponseCache(grpc_utils.ResponseCacheConfig{}); err == nil {
+		t.Error("Expected error without cache")
+	}
+}
+
+func TestResponseCache_Redis(t *testing.T) {
+	addr := startRedisContainer(t)
+	rdb := redis_client.NewRDB(redis_client.Config{Urls: []string{addr}})
+	responseCache, err := grpc_utils.NewResponseCache(grpc_utils.ResponseCacheConfig{
+		Methods: []grpc_utils.CachedMethod{
+			{Method: "/grpc.health.v1.Health/Check", TTL: time.Minute},
+			{Method: "/grpc.health.v1.Health/Short", TTL: 100 * time.Millisecond},
+		},
+		MetadataKeys: []string{"Accept-Language"},
+		Metrics:      grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()},
+		Cache:        redis_client.NewCache(redis_client.CacheConfig{Redis: rdb}),
+		Logger:       discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewResponseCache failed: %v", err)
+	}
+	interceptor := responseCache.UnaryServerInterceptor()
+
+	var calls atomic.Int32
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls.Add(1)
+		if req.(*healthpb.HealthCheckRequest).GetService() == "broken" {
+			return nil, status.Error(codes.NotFound, "unknown service")
+		}
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}
+	call := func(ctx context.Context, method, service string) (*healthpb.HealthCheckResponse, error) {
+		resp, err := interceptor(ctx, &healthpb.HealthCheckRequest{Service: service},
+			&grpc.UnaryServerInfo{FullMethod: method}, handler)
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*healthpb.HealthCheckResponse), nil
+	}
+	expectCalls := func(expected int32) {
+		t.Helper()
+		if got := calls.Swap(0); got != expected {
+			t.Errorf("Expected %d handler calls, got %d", expected, got)
+		}
+	}
+
+	ctx := context.Background()
+	for range 3 {
+		resp, err := call(ctx, "/grpc.health.v1.Health/Check", "orders")
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Expected cached status SERVING, got %v", resp.GetStatus())
+		}
+	}
+	expectCalls(1)
+
+	// The request, tenant and selected metadata are part of the key
+	_, _ = call(ctx, "/grpc.health.v1.Health/Check", "payments")
+	_, _ = call(app.WithTenant(ctx, "acme"), "/grpc.health.v1.Health/Check", "orders")
+	_, _ = call(metadata.NewIncomingContext(ctx, metadata.Pairs("accept-language", "de")),
+		"/grpc.health.v1.Health/Check", "orders")
+	expectCalls(3)
+
+	// Other metadata is not
+	_, _ = call(metadata.NewIncomingContext(ctx, metadata.Pairs("user-agent", "test")),
+		"/grpc.health.v1.Health/Check", "orders")
+	expectCalls(0)
+
+	// Methods not configured are never cached
+	for range 2 {
+		_, _ = call(ctx, "/grpc.health.v1.Health/List", "orders")
+	}
+	expectCalls(2)
+
+	// Errors are not cached
+	for range 2 {
+		if _, err := call(ctx, "/grpc.health.v1.Health/Check", "broken"); status.Code(err) != codes.NotFound {
+			t.Errorf("Expected NotFound, got %v", err)
+		}
+	}
+	expectCalls(2)
+
+	err = responseCache.Invalidate(ctx, "/grpc.health.v1.Health/Check",
+		&healthpb.HealthCheckRequest{Service: "orders"})
+	if err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	_, _ = call(ctx, "/grpc.health.v1.Health/Check", "orders")
+	expectCalls(1)
+
+	// TTLs below the local cache TTL are not outlived by the local cache
+	for range 2 {
+		_, _ = call(ctx, "/grpc.health.v1.Health/Short", "orders")
+	}
+	expectCalls(1)
+	time.Sleep(200 * time.Millisecond)
+	_, _ = call(ctx, "/grpc.health.v1.Health/Short", "orders")
+	expectCalls(1)
+}
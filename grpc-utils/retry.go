@@ -0,0 +1,191 @@
+package grpc_utils
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+	defaultRetryMultiplier     = 2
+)
+
+// RetryConfig holds configuration for the retry interceptor.
+//
+// Example config:
+//
+//	grpc:
+//	  clients:
+//	    orders:
+//	      interceptors: [request_id, metrics, logging, retry]
+//	      retries:
+//	        max_attempts: 3
+//	        initial_backoff: 100ms
+//	        max_backoff: 2s
+//	        methods:
+//	          - /orders.v1.OrderService/Get*
+//	          - /orders.v1.OrderService/List*
+type RetryConfig struct {
+	// Methods lists the full method name patterns of idempotent methods that
+	// are retried, see MatchMethod. Calls carrying an IdempotencyHeader are
+	// retried regardless.
+	Methods []string `mapstructure:"methods"`
+	// IdempotencyHeader is the outgoing metadata key carrying the idempotency
+	// key, matching IdempotencyConfig.Header of the server.
+	// Optional. Defaults to "idempotency-key".
+	IdempotencyHeader string `mapstructure:"idempotency_header"`
+	// MaxAttempts is the total number of attempts including the first one.
+	// Optional. Defaults to 3.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// InitialBackoff is the upper bound of the delay before the first retry.
+	// Optional. Defaults to 100ms.
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// MaxBackoff caps the delay between retries. Optional. Defaults to 2s.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// Multiplier grows the backoff after each retry. Optional. Defaults to 2.
+	Multiplier float64 `mapstructure:"multiplier"`
+	// RetryableCodes lists the status codes that are retried, e.g.
+	// "UNAVAILABLE". Optional. Defaults to UNAVAILABLE.
+	RetryableCodes []string `mapstructure:"retryable_codes"`
+
+	// Logger logs retried calls. Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+}
+
+// Retrier retries failed unary calls to idempotent methods with jittered
+// exponential backoff.
+type Retrier struct {
+	cfg   RetryConfig
+	codes map[codes.Code]bool
+}
+
+// NewRetrier creates a retry interceptor provider.
+//
+// Example:
+//
+//	retrier, err := grpc_utils.NewRetrier(grpc_utils.RetryConfig{
+//	    Methods: []string{"/orders.v1.OrderService/Get*"},
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	conn, err := grpc_utils.NewClientConn(grpc_utils.ClientConfig{
+//	    Target: "dns:///orders:50051",
+//	    UnaryInterceptors: []grpc.UnaryClientInterceptor{
+//	        retrier.UnaryClientInterceptor(),
+//	        breaker.UnaryClientInterceptor(),
+//	    },
+//	})
+func NewRetrier(cfg RetryConfig) (*Retrier, error) {
+	if cfg.IdempotencyHeader == "" {
+		cfg.IdempotencyHeader = DefaultIdempotencyHeader
+	}
+	cfg.IdempotencyHeader = strings.ToLower(cfg.IdempotencyHeader)
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if cfg.Multiplier < 1 {
+		cfg.Multiplier = defaultRetryMultiplier
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	retryable, err := parseCodes(cfg.RetryableCodes, []codes.Code{codes.Unavailable})
+	if err != nil {
+		return nil, err
+	}
+	return &Retrier{cfg: cfg, codes: retryable}, nil
+}
+
+// UnaryClientInterceptor returns an interceptor that retries failed calls to
+// idempotent methods. The delay before each retry is drawn uniformly up to the
+// current backoff, and is at least the delay requested by a RetryInfo detail.
+// Calls are not retried past the context deadline or when the circuit
+// breaker is open.
+func (r *Retrier) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if !r.idempotent(ctx, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		backoff := r.cfg.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || attempt >= r.cfg.MaxAttempts || !r.retryable(err) {
+				return err
+			}
+
+			delay := rand.N(backoff + 1)
+			if requested := retryDelay(err); requested > delay {
+				delay = requested
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+				return err
+			}
+			r.cfg.Logger.DebugContext(ctx, "retrying grpc call",
+				"method", method,
+				"attempt", attempt,
+				"delay", delay,
+				"error", err,
+			)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			backoff = min(time.Duration(float64(backoff)*r.cfg.Multiplier), r.cfg.MaxBackoff)
+		}
+	}
+}
+
+func (r *Retrier) idempotent(ctx context.Context, method string) bool {
+	if matchAnyMethod(r.cfg.Methods, method) {
+		return true
+	}
+	md, ok := metadata.FromOutgoingContext(ctx)
+	return ok && len(md.Get(r.cfg.IdempotencyHeader)) > 0
+}
+
+func (r *Retrier) retryable(err error) bool {
+	if IsCircuitOpen(err) {
+		return false
+	}
+	return r.codes[status.Code(err)]
+}
+
+// retryDelay returns the delay requested by a RetryInfo detail of err.
+func retryDelay(err error) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.RetryDelay != nil {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	return 0
+}
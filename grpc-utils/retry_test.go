@@ -0,0 +1,160 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// countingInvoker fails the first failures calls with err.
+func countingInvoker(calls *int, failures int, err error) grpc.UnaryInvoker {
+	return func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		*calls++
+		if *calls <= failures {
+			return err
+		}
+		return nil
+	}
+}
+
+// openCircuitError returns the error of a call rejected by an open breaker.
+func openCircuitError(t *testing.T) error {
+	t.Helper()
+	breaker, err := grpc_utils.NewCircuitBreaker(grpc_utils.CircuitBreakerConfig{
+		MinRequests: 1,
+		Metrics:     grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()},
+		Logger:      discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewCircuitBreaker failed: %v", err)
+	}
+	interceptor := breaker.UnaryClientInterceptor()
+	cc := idleClientConn(t, "passthrough:///orders")
+	_ = interceptor(context.Background(), "/m", nil, nil, cc, invokerReturning(status.Error(codes.Unavailable, "down")))
+	err = interceptor(context.Background(), "/m", nil, nil, cc, invokerReturning(nil))
+	if !grpc_utils.IsCircuitOpen(err) {
+		t.Fatalf("Expected breaker to be open, got %v", err)
+	}
+	return err
+}
+
+func newTestRetrier(t *testing.T, cfg grpc_utils.RetryConfig) grpc.UnaryClientInterceptor {
+	t.Helper()
+	cfg.InitialBackoff = time.Millisecond
+	cfg.Logger = discardLogger
+	retrier, err := grpc_utils.NewRetrier(cfg)
+	if err != nil {
+		t.Fatalf("NewRetrier failed: %v", err)
+	}
+	return retrier.UnaryClientInterceptor()
+}
+
+func TestRetrier(t *testing.T) {
+	interceptor := newTestRetrier(t, grpc_utils.RetryConfig{
+		Methods:     []string{"/orders.v1.OrderService/Get*"},
+		MaxAttempts: 3,
+	})
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	var calls int
+	err := interceptor(context.Background(), "/orders.v1.OrderService/GetOrder", nil, nil, nil,
+		countingInvoker(&calls, 2, unavailable))
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third attempt, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = interceptor(context.Background(), "/orders.v1.OrderService/GetOrder", nil, nil, nil,
+		countingInvoker(&calls, 5, unavailable))
+	if status.Code(err) != codes.Unavailable || calls != 3 {
+		t.Errorf("Expected attempts to be bounded, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	_ = interceptor(context.Background(), "/orders.v1.OrderService/CreateOrder", nil, nil, nil,
+		countingInvoker(&calls, 5, unavailable))
+	if calls != 1 {
+		t.Errorf("Expected non-idempotent method not to be retried, got %d calls", calls)
+	}
+
+	calls = 0
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpc_utils.DefaultIdempotencyHeader, "create-1")
+	_ = interceptor(ctx, "/orders.v1.OrderService/CreateOrder", nil, nil, nil,
+		countingInvoker(&calls, 1, unavailable))
+	if calls != 2 {
+		t.Errorf("Expected call with idempotency key to be retried, got %d calls", calls)
+	}
+
+	calls = 0
+	_ = interceptor(context.Background(), "/orders.v1.OrderService/GetOrder", nil, nil, nil,
+		countingInvoker(&calls, 5, status.Error(codes.NotFound, "no such order")))
+	if calls != 1 {
+		t.Errorf("Expected non-retryable code not to be retried, got %d calls", calls)
+	}
+
+	calls = 0
+	_ = interceptor(context.Background(), "/orders.v1.OrderService/GetOrder", nil, nil, nil,
+		countingInvoker(&calls, 5, openCircuitError(t)))
+	if calls != 1 {
+		t.Errorf("Expected open circuit not to be retried, got %d calls", calls)
+	}
+}
+
+func TestRetrier_IdempotencyHeader(t *testing.T) {
+	interceptor := newTestRetrier(t, grpc_utils.RetryConfig{IdempotencyHeader: "X-Request-Id"})
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	var calls int
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "create-1")
+	_ = interceptor(ctx, "/orders.v1.OrderService/CreateOrder", nil, nil, nil,
+		countingInvoker(&calls, 1, unavailable))
+	if calls != 2 {
+		t.Errorf("Expected call with the configured header to be retried, got %d calls", calls)
+	}
+
+	calls = 0
+	ctx = metadata.AppendToOutgoingContext(context.Background(), grpc_utils.DefaultIdempotencyHeader, "create-1")
+	_ = interceptor(ctx, "/orders.v1.OrderService/CreateOrder", nil, nil, nil,
+		countingInvoker(&calls, 1, unavailable))
+	if calls != 1 {
+		t.Errorf("Expected default header to be ignored, got %d calls", calls)
+	}
+}
+
+func TestRetrier_RetryInfoAndDeadline(t *testing.T) {
+	interceptor := newTestRetrier(t, grpc_utils.RetryConfig{
+		Methods:        []string{"*"},
+		RetryableCodes: []string{"RESOURCE_EXHAUSTED"},
+	})
+	st, _ := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(50 * time.Millisecond)})
+
+	var calls int
+	start := time.Now()
+	err := interceptor(context.Background(), "/orders.v1.OrderService/GetOrder", nil, nil, nil,
+		countingInvoker(&calls, 1, st.Err()))
+	if err != nil || calls != 2 {
+		t.Fatalf("Expected retry to succeed, got %v after %d calls", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected retry to wait for the requested delay, waited %v", elapsed)
+	}
+
+	// Retries that cannot finish before the deadline are not attempted.
+	calls = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = interceptor(ctx, "/orders.v1.OrderService/GetOrder", nil, nil, nil, countingInvoker(&calls, 5, st.Err()))
+	if status.Code(err) != codes.ResourceExhausted || calls != 1 {
+		t.Errorf("Expected no retry past the deadline, got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,384 @@
+package grpc_utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// InterceptorRequestID selects the request ID interceptor.
+	InterceptorRequestID = "request_id"
+	// InterceptorMetrics selects the Prometheus metrics interceptor.
+	InterceptorMetrics = "metrics"
+	// InterceptorLogging selects the logging interceptor.
+	InterceptorLogging = "logging"
+	// InterceptorRecovery selects the panic recovery interceptor.
+	InterceptorRecovery = "recovery"
+
+	defaultServerAddress   = ":50051"
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+// DefaultInterceptors lists the interceptors enabled when ServerConfig does
+// not select any.
+var DefaultInterceptors = []string{
+	InterceptorRequestID,
+	InterceptorMetrics,
+	InterceptorLogging,
+	InterceptorRecovery,
+}
+
+// TLSConfig holds the server certificates. Setting ClientCAFile enables
+// mutual TLS.
+type TLSConfig struct {
+	// CertFile is the PEM encoded server certificate.
+	CertFile string `mapstructure:"cert_file"`
+	// KeyFile is the PEM encoded server private key.
+	KeyFile string `mapstructure:"key_file"`
+	// ClientCAFile is the PEM encoded CA bundle used to verify client
+	// certificates. Optional. Clients must present a certificate when set.
+	ClientCAFile string `mapstructure:"client_ca_file"`
+}
+
+// KeepaliveConfig holds the server keepalive parameters and enforcement
+// policy. Zero values keep the gRPC defaults.
+type KeepaliveConfig struct {
+	// Time after which the server pings an idle connection.
+	Time time.Duration `mapstructure:"time"`
+	// Timeout waiting for a ping ack before closing the connection.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxConnectionIdle closes connections idle for longer.
+	MaxConnectionIdle time.Duration `mapstructure:"max_connection_idle"`
+	// MaxConnectionAge closes connections older than this, which spreads
+	// clients across instances behind a load balancer.
+	MaxConnectionAge time.Duration `mapstructure:"max_connection_age"`
+	// MaxConnectionAgeGrace is the time given to pending RPCs after
+	// MaxConnectionAge.
+	MaxConnectionAgeGrace time.Duration `mapstructure:"max_connection_age_grace"`
+	// MinTime is the minimum interval clients may send pings at.
+	MinTime time.Duration `mapstructure:"min_time"`
+	// PermitWithoutStream allows client pings without active streams.
+	PermitWithoutStream bool `mapstructure:"permit_without_stream"`
+}
+
+// ServerConfig holds configuration for NewServer.
+//
+// Example config:
+//
+//	grpc:
+//	  server:
+//	    address: :50051
+//	    reflection: true
+//	    max_recv_msg_size: 8388608
+//	    shutdown_timeout: 15s
+//	    interceptors: [request_id, metrics, logging, recovery]
+//	    tls:
+//	      cert_file: /etc/tls/tls.crt
+//	      key_file: /etc/tls/tls.key
+//	      client_ca_file: /etc/tls/ca.crt
+//	    keepalive:
+//	      time: 2m
+//	      max_connection_age: 30m
+type ServerConfig struct {
+	// Address is the listen address. Optional. Defaults to ":50051".
+	Address string `mapstructure:"address"`
+	// TLS enables TLS when CertFile and KeyFile are set.
+	TLS TLSConfig `mapstructure:"tls"`
+	// Keepalive configures connection keepalive.
+	Keepalive KeepaliveConfig `mapstructure:"keepalive"`
+	// MaxRecvMsgSize is the maximum message size in bytes the server can
+	// receive. Optional. Defaults to the gRPC default of 4 MiB.
+	MaxRecvMsgSize int `mapstructure:"max_recv_msg_size"`
+	// MaxSendMsgSize is the maximum message size in bytes the server can
+	// send. Optional.
+	MaxSendMsgSize int `mapstructure:"max_send_msg_size"`
+	// Reflection registers the server reflection service for grpcurl.
+	Reflection bool `mapstructure:"reflection"`
+	// Interceptors selects the built-in interceptors. They are always
+	// chained in the order request ID, metrics, logging, recovery, no matter
+	// the order listed. Optional. Defaults to DefaultInterceptors.
+	Interceptors []string `mapstructure:"interceptors"`
+	// ShutdownTimeout bounds the graceful stop in Shutdown before pending
+	// RPCs are canceled. Optional. Defaults to 10s.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// RequestID configures the request ID interceptor.
+	RequestID RequestIDConfig `mapstructure:"request_id"`
+	// Metrics configures the metrics interceptor.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+	// Deadlines configures default and maximum call deadlines. The deadline
+	// interceptor runs after recovery when any deadline is set.
+	Deadlines DeadlineConfig `mapstructure:"deadlines"`
+	// ConcurrencyLimit sheds calls above the in-flight limit. The limiter
+	// runs after logging when a limit is set, so shed calls are logged and
+	// measured. Its metrics default to the Metrics registry.
+	ConcurrencyLimit ConcurrencyLimitConfig `mapstructure:"concurrency_limit"`
+	// Drain configures how Shutdown drains calls in flight. Its timeout
+	// defaults to ShutdownTimeout, and its health checker to HealthChecker.
+	Drain DrainConfig `mapstructure:"drain"`
+
+	// Logger is used by the logging and recovery interceptors.
+	// Optional. Defaults to slog.Default().
+	Logger *slog.Logger `mapstructure:"-"`
+	// UnaryInterceptors are chained after the built-in interceptors, e.g.
+	// authentication and validation.
+	UnaryInterceptors []grpc.UnaryServerInterceptor `mapstructure:"-"`
+	// StreamInterceptors are chained after the built-in interceptors.
+	StreamInterceptors []grpc.StreamServerInterceptor `mapstructure:"-"`
+	// Options are additional server options.
+	Options []grpc.ServerOption `mapstructure:"-"`
+	// HealthChecker is registered as the grpc.health.v1 service and set to
+	// NOT_SERVING when Shutdown starts. Optional.
+	HealthChecker *HealthChecker `mapstructure:"-"`
+}
+
+// Server is a grpc.Server built from a ServerConfig.
+type Server struct {
+	*grpc.Server
+	// Metrics holds the server metrics, or nil if the metrics interceptor
+	// is disabled.
+	Metrics *ServerMetrics
+	// ConcurrencyLimiter holds the concurrency limiter, or nil if no limit
+	// is configured.
+	ConcurrencyLimiter *ConcurrencyLimiter
+	// DrainController tracks the calls in flight and drains them on
+	// Shutdown.
+	DrainController *DrainController
+
+	cfg ServerConfig
+}
+
+// NewServer creates a gRPC server with the built-in interceptor chain, TLS,
+// keepalive and message size limits taken from cfg.
+//
+// Example:
+//
+//	var cfg grpc_utils.ServerConfig
+//	if err := app.Config().UnmarshalKey("grpc.server", &cfg); err != nil {
+//	    panic(err)
+//	}
+//	cfg.UnaryInterceptors = []grpc.UnaryServerInterceptor{authenticator.UnaryServerInterceptor()}
+//	server, err := grpc_utils.NewServer(cfg)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	pb.RegisterYourServiceServer(server, &yourService{})
+//	go func() { _ = server.ListenAndServe() }()
+//	defer server.Shutdown(context.Background())
+func NewServer(cfg ServerConfig) (*Server, error) {
+	if cfg.Address == "" {
+		cfg.Address = defaultServerAddress
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if len(cfg.Interceptors) == 0 {
+		cfg.Interceptors = DefaultInterceptors
+	}
+
+	enabled := make(map[string]bool, len(cfg.Interceptors))
+	for _, name := range cfg.Interceptors {
+		switch name {
+		case InterceptorRequestID, InterceptorMetrics, InterceptorLogging, InterceptorRecovery:
+			enabled[name] = true
+		default:
+			return nil, fmt.Errorf("grpc_utils: unknown interceptor %q", name)
+		}
+	}
+
+	s := &Server{cfg: cfg}
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+
+	// The request ID comes first so every later log line carries it, and
+	// recovery comes last so panics surface as errors to logging and metrics.
+	if enabled[InterceptorRequestID] {
+		unary = append(unary, BuildRequestIDInterceptorWithConfig(cfg.RequestID))
+		stream = append(stream, BuildRequestIDStreamInterceptor(cfg.RequestID))
+	}
+	if enabled[InterceptorMetrics] {
+		s.Metrics = NewServerMetrics(cfg.Metrics)
+		unary = append(unary, s.Metrics.UnaryServerInterceptor())
+		stream = append(stream, s.Metrics.StreamServerInterceptor())
+	}
+	if enabled[InterceptorLogging] {
+		unary = append(unary, BuildLogInterceptor(cfg.Logger))
+		stream = append(stream, BuildLogStreamInterceptor(cfg.Logger))
+	}
+	// Draining rejects calls after logging so they are logged and measured,
+	// and before the limiter so rejected calls do not count against it.
+	drainCfg := cfg.Drain
+	if drainCfg.Timeout <= 0 {
+		drainCfg.Timeout = cfg.ShutdownTimeout
+	}
+	if drainCfg.HealthChecker == nil {
+		drainCfg.HealthChecker = cfg.HealthChecker
+	}
+	if drainCfg.Logger == nil {
+		drainCfg.Logger = cfg.Logger
+	}
+	s.DrainController = NewDrainController(drainCfg)
+	unary = append(unary, s.DrainController.UnaryServerInterceptor())
+	stream = append(stream, s.DrainController.StreamServerInterceptor())
+	if cfg.ConcurrencyLimit.enabled() {
+		limitCfg := cfg.ConcurrencyLimit
+		if limitCfg.Metrics.Registry == nil {
+			limitCfg.Metrics.Registry = cfg.Metrics.Registry
+		}
+		if limitCfg.Logger == nil {
+			limitCfg.Logger = cfg.Logger
+		}
+		limiter, err := NewConcurrencyLimiter(limitCfg)
+		if err != nil {
+			return nil, err
+		}
+		s.ConcurrencyLimiter = limiter
+		unary = append(unary, limiter.UnaryServerInterceptor())
+		stream = append(stream, limiter.StreamServerInterceptor())
+	}
+	if enabled[InterceptorRecovery] {
+		unary = append(unary, BuildRecoveryInterceptor(cfg.Logger))
+		stream = append(stream, BuildRecoveryStreamInterceptor(cfg.Logger))
+	}
+	if cfg.Deadlines.enabled() {
+		unary = append(unary, BuildDeadlineInterceptor(cfg.Deadlines))
+		stream = append(stream, BuildDeadlineStreamInterceptor(cfg.Deadlines))
+	}
+	unary = append(unary, cfg.UnaryInterceptors...)
+	stream = append(stream, cfg.StreamInterceptors...)
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:                  cfg.Keepalive.Time,
+			Timeout:               cfg.Keepalive.Timeout,
+			MaxConnectionIdle:     cfg.Keepalive.MaxConnectionIdle,
+			MaxConnectionAge:      cfg.Keepalive.MaxConnectionAge,
+			MaxConnectionAgeGrace: cfg.Keepalive.MaxConnectionAgeGrace,
+		}),
+	}
+	if cfg.Keepalive.MinTime > 0 || cfg.Keepalive.PermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             cfg.Keepalive.MinTime,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		}))
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		tlsConfig, err := serverTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	opts = append(opts, cfg.Options...)
+
+	s.Server = grpc.NewServer(opts...)
+	if cfg.Reflection {
+		reflection.Register(s.Server)
+	}
+	if cfg.HealthChecker != nil {
+		cfg.HealthChecker.Register(s.Server)
+	}
+	return s, nil
+}
+
+func serverTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("grpc_utils: failed to load server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("grpc_utils: failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("grpc_utils: no certificates found in client CA file %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// Address returns the configured listen address.
+func (s *Server) Address() string {
+	return s.cfg.Address
+}
+
+// ListenAndServe listens on the configured address and serves RPCs until the
+// server is stopped.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("grpc_utils: failed to listen on %s: %w", s.cfg.Address, err)
+	}
+	slog.Info("gRPC server starting", "address", lis.Addr().String())
+	return s.Serve(lis)
+}
+
+// Shutdown gracefully stops the server, see Drain. Calls still pending once
+// ctx is done or the drain timeout elapses are canceled and the context
+// error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	_, err := s.Drain(ctx)
+	return err
+}
+
+// Drain reports NOT_SERVING on the health checker, if configured, waits for
+// the drain grace period, rejects new calls and waits for the calls in
+// flight. It returns the report of the drain, see DrainController.Drain.
+func (s *Server) Drain(ctx context.Context) (DrainReport, error) {
+	return s.DrainController.Drain(ctx, s.Server)
+}
+
+// Creates a gRPC interceptor that recovers from panics in unary handlers,
+// logging the panic with its stack trace and returning codes.Internal.
+func BuildRecoveryInterceptor(l *slog.Logger) grpc.UnaryServerInterceptor {
+	return recovery.UnaryServerInterceptor(recovery.WithRecoveryHandlerContext(recoveryHandler(l)))
+}
+
+// Creates a gRPC interceptor that recovers from panics in streaming handlers,
+// logging the panic with its stack trace and returning codes.Internal.
+func BuildRecoveryStreamInterceptor(l *slog.Logger) grpc.StreamServerInterceptor {
+	return recovery.StreamServerInterceptor(recovery.WithRecoveryHandlerContext(recoveryHandler(l)))
+}
+
+func recoveryHandler(l *slog.Logger) recovery.RecoveryHandlerFuncContext {
+	return func(ctx context.Context, p any) error {
+		l.ErrorContext(ctx, "recovered from panic",
+			slog.Any("panic", p),
+			slog.String("stack", string(debug.Stack())),
+		)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
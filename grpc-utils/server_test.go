@@ -0,0 +1,254 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// panickingHealthServer panics in Check and blocks in Watch until the call
+// is canceled.
+type panickingHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (panickingHealthServer) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	panic("boom")
+}
+
+func (panickingHealthServer) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func startServer(t *testing.T, server *grpc_utils.Server, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	}, opts...)
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestNewServer(t *testing.T) {
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Metrics: grpc_utils.MetricsConfig{Registry: prometheus.NewRegistry()},
+		Logger:  discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if server.Address() != ":50051" {
+		t.Errorf("Expected default address, got %s", server.Address())
+	}
+	if server.Metrics == nil {
+		t.Error("Expected metrics to be enabled by default")
+	}
+	healthpb.RegisterHealthServer(server, panickingHealthServer{})
+	client := healthpb.NewHealthClient(startServer(t, server, grpc.WithTransportCredentials(insecure.NewCredentials())))
+
+	var header metadata.MD
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected panic to be recovered as Internal, got %v", err)
+	}
+	if len(header.Get(grpc_utils.DefaultRequestIDHeader)) == 0 {
+		t.Error("Expected request ID header on unary call")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, grpc_utils.DefaultRequestIDHeader, "stream-id")
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	header, err = stream.Header()
+	if err != nil {
+		t.Fatalf("Failed to read stream header: %v", err)
+	}
+	if ids := header.Get(grpc_utils.DefaultRequestIDHeader); len(ids) != 1 || ids[0] != "stream-id" {
+		t.Errorf("Expected stream request ID to be echoed, got %v", ids)
+	}
+}
+
+func TestNewServer_UnknownInterceptor(t *testing.T) {
+	_, err := grpc_utils.NewServer(grpc_utils.ServerConfig{Interceptors: []string{"tracing"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "grpc_utils: ") {
+		t.Errorf("Expected package error for unknown interceptor, got %v", err)
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Interceptors:    []string{grpc_utils.InterceptorRecovery},
+		ShutdownTimeout: 100 * time.Millisecond,
+		Logger:          discardLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	healthpb.RegisterHealthServer(server, panickingHealthServer{})
+	client := healthpb.NewHealthClient(startServer(t, server, grpc.WithTransportCredentials(insecure.NewCredentials())))
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := server.Shutdown(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected shutdown to hit the deadline with a pending stream, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Shutdown took %v, expected it to respect the timeout", elapsed)
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Error("Expected pending stream to be canceled")
+	}
+}
+
+// writeCertificate writes a self-signed certificate usable as server
+// certificate, client certificate and CA.
+func writeCertificate(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeCertificate(t, dir, "server")
+	clientCert, clientKey := writeCertificate(t, dir, "client")
+
+	server, err := grpc_utils.NewServer(grpc_utils.ServerConfig{
+		Interceptors: []string{grpc_utils.InterceptorRecovery},
+		Logger:       discardLogger,
+		TLS: grpc_utils.TLSConfig{
+			CertFile:     serverCert,
+			KeyFile:      serverKey,
+			ClientCAFile: clientCert,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	healthpb.RegisterHealthServer(server, panickingHealthServer{})
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	serverPEM, err := os.ReadFile(serverCert)
+	if err != nil {
+		t.Fatalf("Failed to read server certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(serverPEM)
+
+	dial := func(certs []tls.Certificate) error {
+		conn, err := grpc.NewClient("passthrough:///localhost",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				RootCAs:      roots,
+				Certificates: certs,
+				ServerName:   "localhost",
+			})),
+		)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = conn.Close() }()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatalf("Failed to load client certificate: %v", err)
+	}
+	// The handler panics, so a successful handshake yields Internal.
+	if err := dial([]tls.Certificate{cert}); status.Code(err) != codes.Internal {
+		t.Errorf("Expected call with client certificate to reach the handler, got %v", err)
+	}
+	if err := dial(nil); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected call without client certificate to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package grpc_utils
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/poly-workshop/go-webmods/app"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// TenantSourceClaims reads the tenant from a JWT claim.
+	TenantSourceClaims = "claims"
+	// TenantSourceMetadata reads the tenant from a metadata header.
+	TenantSourceMetadata = "metadata"
+	// TenantSourceHost reads the tenant from the subdomain of the host.
+	TenantSourceHost = "host"
+
+	// DefaultTenantHeader is the metadata key carrying the tenant when no
+	// other header is configured.
+	DefaultTenantHeader = "x-tenant-id"
+	// DefaultTenantClaim is the JWT claim carrying the tenant when no other
+	// claim is configured.
+	DefaultTenantClaim = "tenant_id"
+
+	forwardedHostHeader = "x-forwarded-host"
+	authorityHeader     = ":authority"
+)
+
+// DefaultTenantSources lists the tenant sources tried when TenantConfig does
+// not select any.
+var DefaultTenantSources = []string{
+	TenantSourceClaims,
+	TenantSourceMetadata,
+	TenantSourceHost,
+}
+
+// TenantConfig holds configuration for the tenant interceptor.
+//
+// Example config:
+//
+//	grpc:
+//	  tenant:
+//	    sources: [claims, metadata, host]
+//	    host_suffix: .api.example.com
+//	    required: true
+//	    exempt_methods: [/grpc.health.v1.Health/*]
+type TenantConfig struct {
+	// Sources lists where the tenant is read from, in order of preference.
+	// Optional. Defaults to DefaultTenantSources.
+	Sources []string `mapstructure:"sources"`
+	// Header is the metadata key carrying the tenant.
+	// Optional. Defaults to "x-tenant-id".
+	Header string `mapstructure:"header"`
+	// Claim is the JWT claim carrying the tenant. The authentication
+	// interceptor must run first. Optional. Defaults to "tenant_id".
+	Claim string `mapstructure:"claim"`
+	// AllowTokensWithoutTenant lets callers whose token has no Claim pick
+	// the tenant from the other sources. By default such calls are
+	// rejected with codes.PermissionDenied when Sources include claims, so
+	// a client cannot choose a tenant its token was not issued for. Set it
+	// only if tokens without tenant, e.g. of internal services, may act for
+	// any tenant.
+	AllowTokensWithoutTenant bool `mapstructure:"allow_tokens_without_tenant"`
+	// HostSuffix is stripped from the host to get the tenant, e.g.
+	// ".api.example.com" maps acme.api.example.com to "acme". The host
+	// source is skipped when it is unset.
+	HostSuffix string `mapstructure:"host_suffix"`
+	// Required rejects calls without tenant with codes.InvalidArgument.
+	Required bool `mapstructure:"required"`
+	// ExemptMethods lists the full method name patterns that do not require
+	// a tenant, see MatchMethod.
+	ExemptMethods []string `mapstructure:"exempt_methods"`
+	// Tenants restricts the accepted tenants. Optional. All valid tenant IDs
+	// are accepted when unset.
+	Tenants []string `mapstructure:"tenants"`
+}
+
+type tenantResolver struct {
+	cfg     TenantConfig
+	allowed map[string]bool
+	// requireClaim rejects tenants from other sources for authenticated
+	// callers whose token has no tenant claim.
+	requireClaim bool
+}
+
+func newTenantResolver(cfg TenantConfig) *tenantResolver {
+	if len(cfg.Sources) == 0 {
+		cfg.Sources = DefaultTenantSources
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultTenantHeader
+	}
+	cfg.Header = strings.ToLower(cfg.Header)
+	if cfg.Claim == "" {
+		cfg.Claim = DefaultTenantClaim
+	}
+	var allowed map[string]bool
+	if len(cfg.Tenants) > 0 {
+		allowed = make(map[string]bool, len(cfg.Tenants))
+		for _, tenant := range cfg.Tenants {
+			allowed[tenant] = true
+		}
+	}
+	return &tenantResolver{
+		cfg:          cfg,
+		allowed:      allowed,
+		requireClaim: !cfg.AllowTokensWithoutTenant && slices.Contains(cfg.Sources, TenantSourceClaims),
+	}
+}
+
+// resolve returns ctx with the tenant of the call stored via app.WithTenant.
+func (r *tenantResolver) resolve(ctx context.Context, fullMethod string) (context.Context, error) {
+	claims, authenticated := ClaimsFromContext(ctx)
+	claimTenant := r.fromClaims(claims)
+
+	var tenant string
+	for _, source := range r.cfg.Sources {
+		switch source {
+		case TenantSourceClaims:
+			tenant = claimTenant
+		case TenantSourceMetadata:
+			tenant = metadataValue(ctx, r.cfg.Header)
+		case TenantSourceHost:
+			tenant = r.fromHost(ctx)
+		}
+		if tenant != "" {
+			break
+		}
+	}
+
+	if tenant == "" {
+		if r.cfg.Required && !matchAnyMethod(r.cfg.ExemptMethods, fullMethod) {
+			return nil, status.Error(codes.InvalidArgument, "missing tenant")
+		}
+		return ctx, nil
+	}
+	if !app.ValidTenant(tenant) {
+		return nil, status.Error(codes.InvalidArgument, "invalid tenant")
+	}
+	// A token issued for one tenant must not be used to access another.
+	if claimTenant != "" && claimTenant != tenant {
+		return nil, status.Error(codes.PermissionDenied, "tenant does not match token")
+	}
+	if authenticated && claimTenant == "" && r.requireClaim {
+		return nil, status.Error(codes.PermissionDenied, "token is not issued for a tenant")
+	}
+	if r.allowed != nil && !r.allowed[tenant] {
+		return nil, status.Error(codes.PermissionDenied, "unknown tenant")
+	}
+	return app.WithTenant(ctx, tenant), nil
+}
+
+func (r *tenantResolver) fromClaims(claims *Claims) string {
+	if claims == nil {
+		return ""
+	}
+	switch v := claims.Raw[r.cfg.Claim].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func (r *tenantResolver) fromHost(ctx context.Context) string {
+	if r.cfg.HostSuffix == "" {
+		return ""
+	}
+	// Calls proxied by the gateway carry the original host in
+	// x-forwarded-host.
+	host := metadataValue(ctx, forwardedHostHeader)
+	if host == "" {
+		host = metadataValue(ctx, authorityHeader)
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	suffix := strings.ToLower(r.cfg.HostSuffix)
+	if !strings.HasPrefix(suffix, ".") {
+		suffix = "." + suffix
+	}
+	tenant, ok := strings.CutSuffix(host, suffix)
+	if !ok || strings.Contains(tenant, ".") {
+		return ""
+	}
+	return tenant
+}
+
+// Creates a gRPC interceptor that resolves the tenant of unary calls from JWT
+// claims, metadata or the host and stores it in the context with
+// app.WithTenant, which also adds it to the log attributes.
+func BuildTenantInterceptor(cfg TenantConfig) grpc.UnaryServerInterceptor {
+	r := newTenantResolver(cfg)
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, err := r.resolve(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Creates a gRPC interceptor that resolves the tenant of streaming calls and
+// stores it in the stream context.
+func BuildTenantStreamInterceptor(cfg TenantConfig) grpc.StreamServerInterceptor {
+	r := newTenantResolver(cfg)
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := r.resolve(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
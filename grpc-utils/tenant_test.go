@@ -0,0 +1,151 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/poly-workshop/go-webmods/app"
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ctxServerStream is a server stream with a fixed context.
+type ctxServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *ctxServerStream) Context() context.Context { return s.ctx }
+
+func TestTenantInterceptor(t *testing.T) {
+	interceptor := grpc_utils.BuildTenantInterceptor(grpc_utils.TenantConfig{
+		HostSuffix:    ".api.example.com",
+		Required:      true,
+		ExemptMethods: []string{"/grpc.health.v1.Health/*"},
+		Tenants:       []string{"acme", "globex", "42"},
+	})
+
+	acme := &grpc_utils.Claims{Raw: map[string]any{"tenant_id": "acme"}}
+	numeric := &grpc_utils.Claims{Raw: map[string]any{"tenant_id": float64(42)}}
+
+	tests := []struct {
+		name     string
+		method   string
+		claims   *grpc_utils.Claims
+		md       metadata.MD
+		expected string
+		code     codes.Code
+	}{
+		{"metadata", "", nil, metadata.Pairs("x-tenant-id", "globex"), "globex", codes.OK},
+		{"claims", "", acme, nil, "acme", codes.OK},
+		{"numeric_claim", "", numeric, nil, "42", codes.OK},
+		{"claims_before_host", "", acme, metadata.Pairs(":authority", "acme.api.example.com"), "acme", codes.OK},
+		{"authority", "", nil, metadata.Pairs(":authority", "acme.api.example.com:443"), "acme", codes.OK},
+		{"forwarded_host", "", nil, metadata.Pairs(
+			"x-forwarded-host", "Globex.API.example.com",
+			":authority", "gateway.internal",
+		), "globex", codes.OK},
+		{"nested_subdomain", "", nil, metadata.Pairs(":authority", "a.acme.api.example.com"), "", codes.InvalidArgument},
+		{"claims_before_metadata", "", acme, metadata.Pairs("x-tenant-id", "globex"), "acme", codes.OK},
+		{"missing", "", nil, nil, "", codes.InvalidArgument},
+		{"exempt", "/grpc.health.v1.Health/Check", nil, nil, "", codes.OK},
+		{"invalid", "", nil, metadata.Pairs("x-tenant-id", "../globex"), "", codes.InvalidArgument},
+		{"unknown", "", nil, metadata.Pairs("x-tenant-id", "initech"), "", codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = grpc_utils.ContextWithClaims(ctx, tt.claims)
+			}
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			method := tt.method
+			if method == "" {
+				method = "/orders.v1.OrderService/GetOrder"
+			}
+			var tenant string
+			handler := func(ctx context.Context, req any) (any, error) {
+				tenant, _ = app.TenantFromContext(ctx)
+				return nil, nil
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("Expected code %v, got %v (%v)", tt.code, got, err)
+			}
+			if tenant != tt.expected {
+				t.Errorf("Expected tenant %q, got %q", tt.expected, tenant)
+			}
+		})
+	}
+}
+
+func TestTenantInterceptor_ClaimMismatch(t *testing.T) {
+	interceptor := grpc_utils.BuildTenantInterceptor(grpc_utils.TenantConfig{
+		Sources: []string{grpc_utils.TenantSourceMetadata, grpc_utils.TenantSourceClaims},
+	})
+	ctx := grpc_utils.ContextWithClaims(context.Background(),
+		&grpc_utils.Claims{Raw: map[string]any{"tenant_id": "acme"}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-tenant-id", "globex"))
+
+	handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/GetOrder"}
+	if _, err := interceptor(ctx, nil, info, handler); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for tenant not matching token, got %v", err)
+	}
+}
+
+func TestTenantInterceptor_TokenWithoutTenant(t *testing.T) {
+	ctx := grpc_utils.ContextWithClaims(context.Background(),
+		&grpc_utils.Claims{Raw: map[string]any{"sub": "user-1"}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-tenant-id", "globex"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/GetOrder"}
+
+	var tenant string
+	handler := func(ctx context.Context, req any) (any, error) {
+		tenant, _ = app.TenantFromContext(ctx)
+		return nil, nil
+	}
+	interceptor := grpc_utils.BuildTenantInterceptor(grpc_utils.TenantConfig{})
+	if _, err := interceptor(ctx, nil, info, handler); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for a token without tenant, got %v", err)
+	}
+
+	interceptor = grpc_utils.BuildTenantInterceptor(grpc_utils.TenantConfig{AllowTokensWithoutTenant: true})
+	if _, err := interceptor(ctx, nil, info, handler); err != nil {
+		t.Fatalf("Expected token without tenant to be allowed, got %v", err)
+	}
+	if tenant != "globex" {
+		t.Errorf("Expected tenant globex from metadata, got %q", tenant)
+	}
+}
+
+func TestTenantStreamInterceptor(t *testing.T) {
+	interceptor := grpc_utils.BuildTenantStreamInterceptor(grpc_utils.TenantConfig{
+		Header:   "X-Org",
+		Required: true,
+	})
+	info := &grpc.StreamServerInfo{FullMethod: "/orders.v1.OrderService/Watch", IsServerStream: true}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-org", "acme"))
+	err := interceptor(nil, &ctxServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+		if tenant, _ := app.TenantFromContext(ss.Context()); tenant != "acme" {
+			t.Errorf("Expected tenant acme in stream context, got %q", tenant)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor returned error: %v", err)
+	}
+
+	err = interceptor(nil, &ctxServerStream{ctx: context.Background()}, info,
+		func(srv any, ss grpc.ServerStream) error { return nil })
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without tenant, got %v", err)
+	}
+}
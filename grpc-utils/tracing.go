@@ -0,0 +1,41 @@
+package grpc_utils
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// TracingConfig holds configuration for gRPC tracing.
+type TracingConfig struct {
+	// IgnoredMethods lists full method name patterns that are not traced,
+	// e.g. "/grpc.health.v1.Health/*". See MatchMethod.
+	IgnoredMethods []string `mapstructure:"ignored_methods"`
+}
+
+func (cfg TracingConfig) handlerOptions() []otelgrpc.Option {
+	if len(cfg.IgnoredMethods) == 0 {
+		return nil
+	}
+	return []otelgrpc.Option{
+		otelgrpc.WithFilter(func(info *stats.RPCTagInfo) bool {
+			return !matchAnyMethod(cfg.IgnoredMethods, info.FullMethodName)
+		}),
+	}
+}
+
+// TracingServerOption returns a server option that creates a span for every
+// incoming RPC, continuing the W3C trace context sent in the call metadata and
+// recording the status code.
+//
+// Spans are exported by the global tracer provider, which app.Init sets up
+// from the otel config section.
+func TracingServerOption(cfg TracingConfig) grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(cfg.handlerOptions()...))
+}
+
+// TracingDialOption returns a dial option that creates a span for every
+// outgoing RPC and propagates the W3C trace context in the call metadata.
+func TracingDialOption(cfg TracingConfig) grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler(cfg.handlerOptions()...))
+}
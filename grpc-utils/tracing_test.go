@@ -0,0 +1,111 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	originalProvider := otel.GetTracerProvider()
+	originalPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(originalProvider)
+		otel.SetTextMapPropagator(originalPropagator)
+	}()
+
+	cfg := grpc_utils.TracingConfig{IgnoredMethods: []string{"/grpc.health.v1.Health/List"}}
+
+	var handlerSpan trace.SpanContext
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc_utils.TracingServerOption(cfg),
+		grpc.ChainUnaryInterceptor(func(
+			ctx context.Context,
+			req any,
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (any, error) {
+			if info.FullMethod == "/grpc.health.v1.Health/Check" {
+				handlerSpan = trace.SpanContextFromContext(ctx)
+			}
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc_utils.TracingDialOption(cfg),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := healthpb.NewHealthClient(conn)
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if _, err := client.List(context.Background(), &healthpb.HealthListRequest{}); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	var clientSpan, serverSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() != "grpc.health.v1.Health/Check" {
+			t.Errorf("Unexpected span %q, ignored methods must not be traced", span.Name())
+			continue
+		}
+		switch span.SpanKind() {
+		case trace.SpanKindClient:
+			clientSpan = span
+		case trace.SpanKindServer:
+			serverSpan = span
+		}
+	}
+	if clientSpan == nil || serverSpan == nil {
+		t.Fatalf("Expected client and server spans, got %d spans", len(recorder.Ended()))
+	}
+
+	if serverSpan.SpanContext().TraceID() != clientSpan.SpanContext().TraceID() {
+		t.Error("Expected server span to continue the client trace")
+	}
+	if serverSpan.Parent().SpanID() != clientSpan.SpanContext().SpanID() {
+		t.Error("Expected server span to be a child of the client span")
+	}
+	if handlerSpan.SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Error("Expected server span to be active in the handler context")
+	}
+
+	found := false
+	for _, attr := range serverSpan.Attributes() {
+		if attr.Key == "rpc.grpc.status_code" && attr.Value.AsInt64() == 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected rpc.grpc.status_code attribute, got %v", serverSpan.Attributes())
+	}
+}
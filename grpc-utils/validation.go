@@ -0,0 +1,266 @@
+package grpc_utils
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// validatorAll is implemented by messages generated by protoc-gen-validate,
+// collecting all violations instead of stopping at the first one.
+type validatorAll interface {
+	ValidateAll() error
+}
+
+// validator is implemented by messages generated by protoc-gen-validate and
+// by hand written request types.
+type validator interface {
+	Validate() error
+}
+
+// fieldError matches the per-field errors generated by protoc-gen-validate.
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// multiError matches the aggregated errors returned by ValidateAll.
+type multiError interface {
+	AllErrors() []error
+}
+
+// ValidationConfig holds configuration for request validation.
+type ValidationConfig struct {
+	// FailFast calls Validate instead of ValidateAll, reporting only the
+	// first violation.
+	FailFast bool `mapstructure:"fail_fast"`
+	// Validator validates messages that have no Validate method, e.g. a
+	// protovalidate validator:
+	//
+	//	v, _ := protovalidate.New()
+	//	cfg.Validator = func(msg any) error { return v.Validate(msg.(proto.Message)) }
+	//
+	// Optional.
+	Validator func(msg any) error `mapstructure:"-"`
+}
+
+// Creates a gRPC interceptor that validates unary requests before calling the
+// handler. Invalid requests are rejected with codes.InvalidArgument and an
+// errdetails.BadRequest listing the field violations.
+func BuildValidationInterceptor(cfg ValidationConfig) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := validateMessage(ctx, cfg, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Creates a gRPC interceptor that validates every message received on a
+// stream. RecvMsg returns the codes.InvalidArgument status for invalid
+// messages.
+func BuildValidationStreamInterceptor(cfg ValidationConfig) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &validatedServerStream{
+			ServerStream: ss,
+			cfg:          cfg,
+			fullMethod:   info.FullMethod,
+		})
+	}
+}
+
+type validatedServerStream struct {
+	grpc.ServerStream
+	cfg        ValidationConfig
+	fullMethod string
+}
+
+func (s *validatedServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validateMessage(s.Context(), s.cfg, s.fullMethod, m)
+}
+
+// validateMessage runs the validation method of msg and converts a failure
+// into an InvalidArgument status.
+func validateMessage(ctx context.Context, cfg ValidationConfig, fullMethod string, msg any) error {
+	var err error
+	if v, ok := msg.(validatorAll); ok && !cfg.FailFast {
+		err = v.ValidateAll()
+	} else if v, ok := msg.(validator); ok {
+		err = v.Validate()
+	} else if cfg.Validator != nil {
+		err = cfg.Validator(msg)
+	}
+	if err == nil {
+		return nil
+	}
+
+	// Validation methods may already return a status, e.g. a domain Error.
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	slog.DebugContext(ctx, "request validation failed",
+		slog.String("method", fullMethod),
+		slog.String("error", err.Error()),
+	)
+
+	badRequest := &errdetails.BadRequest{FieldViolations: fieldViolations("", err)}
+	st, detailErr := status.New(codes.InvalidArgument, "invalid request").WithDetails(badRequest)
+	if detailErr != nil {
+		return status.Error(codes.InvalidArgument, "invalid request")
+	}
+	return st.Err()
+}
+
+// fieldViolations flattens a validation error into field violations. Nested
+// message errors are reported with dotted field paths, e.g. "address.city".
+func fieldViolations(prefix string, err error) []*errdetails.BadRequest_FieldViolation {
+	if violations, ok := protoViolations(prefix, err); ok {
+		return violations
+	}
+	if multi, ok := err.(multiError); ok {
+		return joinedViolations(prefix, multi.AllErrors())
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joinedViolations(prefix, joined.Unwrap())
+	}
+
+	var fe fieldError
+	if errors.As(err, &fe) {
+		field := fe.Field()
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		// protoc-gen-validate reports embedded message failures with the
+		// nested validation error as cause.
+		if causer, ok := fe.(interface{ Cause() error }); ok && isValidationError(causer.Cause()) {
+			return fieldViolations(field, causer.Cause())
+		}
+		return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: fe.Reason()}}
+	}
+
+	return []*errdetails.BadRequest_FieldViolation{{Field: prefix, Description: err.Error()}}
+}
+
+func isValidationError(err error) bool {
+	if _, ok := err.(multiError); ok {
+		return true
+	}
+	var fe fieldError
+	return errors.As(err, &fe)
+}
+
+func joinedViolations(prefix string, errs []error) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, err := range errs {
+		if err != nil {
+			violations = append(violations, fieldViolations(prefix, err)...)
+		}
+	}
+	return violations
+}
+
+// violationsMessage is the full name of the message protovalidate reports
+// violations with.
+const violationsMessage protoreflect.FullName = "buf.validate.Violations"
+
+// protoViolations returns the field violations of errors exposing a
+// buf.validate.Violations message through a ToProto method, such as
+// *protovalidate.ValidationError, without depending on protovalidate.
+func protoViolations(prefix string, err error) ([]*errdetails.BadRequest_FieldViolation, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		method := reflect.ValueOf(err).MethodByName("ToProto")
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+			continue
+		}
+		msg, ok := method.Call(nil)[0].Interface().(proto.Message)
+		if !ok || msg == nil || msg.ProtoReflect().Descriptor().FullName() != violationsMessage {
+			continue
+		}
+
+		m := msg.ProtoReflect()
+		list := m.Get(m.Descriptor().Fields().ByName("violations")).List()
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, list.Len())
+		for i := range list.Len() {
+			v := list.Get(i).Message()
+			field := violationFieldPath(v)
+			if prefix != "" {
+				field = strings.TrimSuffix(prefix+"."+field, ".")
+			}
+			description := stringField(v, "message")
+			if description == "" {
+				description = stringField(v, "rule_id")
+			}
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: description,
+			})
+		}
+		return violations, true
+	}
+	return nil, false
+}
+
+// violationFieldPath renders the field path of a buf.validate.Violation the
+// way protovalidate does, e.g. "items[0].name" or "labels[\"env\"]".
+func violationFieldPath(v protoreflect.Message) string {
+	fd := v.Descriptor().Fields().ByName("field")
+	if fd == nil || !v.Has(fd) {
+		// Violations of older protovalidate versions carry a rendered path
+		return stringField(v, "field_path")
+	}
+	path := v.Get(fd).Message()
+	list := path.Get(path.Descriptor().Fields().ByName("elements")).List()
+
+	var sb strings.Builder
+	for i := range list.Len() {
+		element := list.Get(i).Message()
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(stringField(element, "field_name"))
+		if oneof := element.Descriptor().Oneofs().ByName("subscript"); oneof != nil {
+			if sub := element.WhichOneof(oneof); sub != nil {
+				value := element.Get(sub)
+				if sub.Kind() == protoreflect.StringKind {
+					sb.WriteString("[" + strconv.Quote(value.String()) + "]")
+				} else {
+					sb.WriteString("[" + value.String() + "]")
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// stringField returns the string field name of m, or "" if m has none.
+func stringField(m protoreflect.Message, name protoreflect.Name) string {
+	fd := m.Descriptor().Fields().ByName(name)
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		return ""
+	}
+	return m.Get(fd).String()
+}
@@ -0,0 +1,326 @@
+package grpc_utils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	grpc_utils "github.com/poly-workshop/go-webmods/grpc-utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// validationError mimics the field errors generated by protoc-gen-validate.
+type validationError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e validationError) Field() string  { return e.field }
+func (e validationError) Reason() string { return e.reason }
+func (e validationError) Cause() error   { return e.cause }
+func (e validationError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+
+// validationMultiError mimics the aggregated errors returned by ValidateAll.
+type validationMultiError []error
+
+func (m validationMultiError) Error() string      { return errors.Join(m...).Error() }
+func (m validationMultiError) AllErrors() []error { return m }
+
+type createOrderRequest struct {
+	quantity int
+	city     string
+}
+
+func (r *createOrderRequest) violations() []error {
+	var errs []error
+	if r.quantity <= 0 {
+		errs = append(errs, validationError{field: "Quantity", reason: "value must be greater than 0"})
+	}
+	if r.city == "" {
+		errs = append(errs, validationError{
+			field:  "Address",
+			reason: "embedded message failed validation",
+			cause:  validationError{field: "City", reason: "value length must be at least 1 runes"},
+		})
+	}
+	return errs
+}
+
+func (r *createOrderRequest) Validate() error {
+	if errs := r.violations(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (r *createOrderRequest) ValidateAll() error {
+	if errs := r.violations(); len(errs) > 0 {
+		return validationMultiError(errs)
+	}
+	return nil
+}
+
+func callValidation(t *testing.T, cfg grpc_utils.ValidationConfig, req any) (bool, error) {
+	t.Helper()
+	interceptor := grpc_utils.BuildValidationInterceptor(cfg)
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/CreateOrder"}
+	called := false
+	_, err := interceptor(context.Background(), req, info, func(ctx context.Context, req any) (any, error) {
+		called = true
+		return "ok", nil
+	})
+	return called, err
+}
+
+func badRequestFields(t *testing.T, err error) map[string]string {
+	t.Helper()
+	st, _ := status.FromError(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument, got %v", err)
+	}
+	fields := map[string]string{}
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields[v.Field] = v.Description
+			}
+		}
+	}
+	return fields
+}
+
+func TestValidationInterceptor(t *testing.T) {
+	called, err := callValidation(t, grpc_utils.ValidationConfig{}, &createOrderRequest{quantity: 1, city: "Berlin"})
+	if err != nil || !called {
+		t.Fatalf("Expected valid request to reach the handler, got %v", err)
+	}
+
+	called, err = callValidation(t, grpc_utils.ValidationConfig{}, &createOrderRequest{})
+	if called {
+		t.Error("Expected invalid request not to reach the handler")
+	}
+	fields := badRequestFields(t, err)
+	if len(fields) != 2 {
+		t.Fatalf("Expected 2 field violations, got %v", fields)
+	}
+	if fields["Quantity"] != "value must be greater than 0" {
+		t.Errorf("Unexpected Quantity violation: %v", fields)
+	}
+	if _, ok := fields["Address.City"]; !ok {
+		t.Errorf("Expected nested Address.City violation, got %v", fields)
+	}
+}
+
+func TestValidationInterceptor_FailFast(t *testing.T) {
+	_, err := callValidation(t, grpc_utils.ValidationConfig{FailFast: true}, &createOrderRequest{})
+	fields := badRequestFields(t, err)
+	if len(fields) != 1 || fields["Quantity"] == "" {
+		t.Errorf("Expected only the first violation, got %v", fields)
+	}
+}
+
+func TestValidationInterceptor_CustomValidator(t *testing.T) {
+	cfg := grpc_utils.ValidationConfig{
+		Validator: func(msg any) error {
+			if msg == "" {
+				return errors.New("request must not be empty")
+			}
+			return nil
+		},
+	}
+
+	if called, err := callValidation(t, cfg, "payload"); err != nil || !called {
+		t.Fatalf("Expected valid request to reach the handler, got %v", err)
+	}
+	_, err := callValidation(t, cfg, "")
+	if fields := badRequestFields(t, err); fields[""] != "request must not be empty" {
+		t.Errorf("Expected message level violation, got %v", fields)
+	}
+
+	// Messages without a validation method pass through without a validator.
+	if called, err := callValidation(t, grpc_utils.ValidationConfig{}, ""); err != nil || !called {
+		t.Errorf("Expected request without Validate to pass, got %v", err)
+	}
+}
+
+// violationsFile describes the subset of buf/validate/validate.proto used to
+// report protovalidate violations.
+var violationsFile = func() protoreflect.FileDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	repeated := func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return f
+	}
+	inSubscript := func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		f.OneofIndex = proto.Int32(0)
+		return f
+	}
+	const (
+		typeString  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		typeUint64  = descriptorpb.FieldDescriptorProto_TYPE_UINT64
+		typeMessage = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("buf/validate/validate.proto"),
+		Package: proto.String("buf.validate"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name:  proto.String("Violations"),
+				Field: []*descriptorpb.FieldDescriptorProto{repeated(field("violations", 1, typeMessage, ".buf.validate.Violation"))},
+			},
+			{
+				Name: proto.String("Violation"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("rule_id", 2, typeString, ""),
+					field("message", 3, typeString, ""),
+					field("field", 5, typeMessage, ".buf.validate.FieldPath"),
+				},
+			},
+			{
+				Name:  proto.String("FieldPath"),
+				Field: []*descriptorpb.FieldDescriptorProto{repeated(field("elements", 1, typeMessage, ".buf.validate.FieldPathElement"))},
+			},
+			{
+				Name: proto.String("FieldPathElement"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("field_name", 2, typeString, ""),
+					inSubscript(field("index", 6, typeUint64, "")),
+					inSubscript(field("string_key", 10, typeString, "")),
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("subscript")}},
+			},
+		},
+	}, new(protoregistry.Files))
+	if err != nil {
+		panic(err)
+	}
+	return file
+}()
+
+// protovalidateError mimics *protovalidate.ValidationError, which exposes its
+// violations as a buf.validate.Violations message.
+type protovalidateError struct {
+	violations proto.Message
+}
+
+func (e *protovalidateError) Error() string          { return "validation error" }
+func (e *protovalidateError) ToProto() proto.Message { return e.violations }
+
+// newProtovalidateError returns an error with a violation for each path,
+// given as field names optionally followed by an index or map key.
+func newProtovalidateError(violations map[string][][2]any) error {
+	messages := violationsFile.Messages()
+	set := dynamicpb.NewMessage(messages.ByName("Violations"))
+	list := set.Mutable(set.Descriptor().Fields().ByName("violations")).List()
+	for message, elements := range violations {
+		v := dynamicpb.NewMessage(messages.ByName("Violation"))
+		v.Set(v.Descriptor().Fields().ByName("message"), protoreflect.ValueOfString(message))
+		path := dynamicpb.NewMessage(messages.ByName("FieldPath"))
+		pathList := path.Mutable(path.Descriptor().Fields().ByName("elements")).List()
+		for _, e := range elements {
+			element := dynamicpb.NewMessage(messages.ByName("FieldPathElement"))
+			fields := element.Descriptor().Fields()
+			element.Set(fields.ByName("field_name"), protoreflect.ValueOfString(e[0].(string)))
+			switch sub := e[1].(type) {
+			case uint64:
+				element.Set(fields.ByName("index"), protoreflect.ValueOfUint64(sub))
+			case string:
+				element.Set(fields.ByName("string_key"), protoreflect.ValueOfString(sub))
+			}
+			pathList.Append(protoreflect.ValueOfMessage(element))
+		}
+		v.Set(v.Descriptor().Fields().ByName("field"), protoreflect.ValueOfMessage(path))
+		list.Append(protoreflect.ValueOfMessage(v))
+	}
+	return &protovalidateError{violations: set}
+}
+
+func TestValidationInterceptor_Protovalidate(t *testing.T) {
+	cfg := grpc_utils.ValidationConfig{
+		Validator: func(msg any) error {
+			return fmt.Errorf("validate: %w", newProtovalidateError(map[string][][2]any{
+				"value must be greater than 0": {{"quantity", nil}},
+				"value is required":            {{"items", uint64(1)}, {"sku", nil}},
+				"value must be a known label":  {{"labels", "env"}},
+			}))
+		},
+	}
+
+	_, err := callValidation(t, cfg, "payload")
+	fields := badRequestFields(t, err)
+	expected := map[string]string{
+		"quantity":      "value must be greater than 0",
+		"items[1].sku":  "value is required",
+		`labels["env"]`: "value must be a known label",
+	}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d field violations, got %v", len(expected), fields)
+	}
+	for field, description := range expected {
+		if fields[field] != description {
+			t.Errorf("Expected %s violation %q, got %v", field, description, fields)
+		}
+	}
+}
+
+// recvServerStream replays messages for RecvMsg.
+type recvServerStream struct {
+	grpc.ServerStream
+	msgs []*createOrderRequest
+}
+
+func (s *recvServerStream) Context() context.Context { return context.Background() }
+
+func (s *recvServerStream) RecvMsg(m any) error {
+	if len(s.msgs) == 0 {
+		return io.EOF
+	}
+	*m.(*createOrderRequest) = *s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+func TestValidationStreamInterceptor(t *testing.T) {
+	interceptor := grpc_utils.BuildValidationStreamInterceptor(grpc_utils.ValidationConfig{})
+	stream := &recvServerStream{msgs: []*createOrderRequest{
+		{quantity: 1, city: "Berlin"},
+		{quantity: 0, city: "Berlin"},
+	}}
+	info := &grpc.StreamServerInfo{FullMethod: "/orders.v1.OrderService/ImportOrders", IsClientStream: true}
+
+	err := interceptor(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+		for {
+			var req createOrderRequest
+			if err := ss.RecvMsg(&req); err != nil {
+				return err
+			}
+		}
+	})
+	if fields := badRequestFields(t, err); fields["Quantity"] == "" {
+		t.Errorf("Expected second message to fail validation, got %v", fields)
+	}
+}
@@ -0,0 +1,122 @@
+package http_utils
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	defaultCORSMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPost,
+		http.MethodPut, http.MethodPatch, http.MethodDelete,
+	}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", DefaultRequestIDHeader}
+)
+
+// CORSConfig holds configuration for the CORS middleware.
+//
+// Example config:
+//
+//	http:
+//	  cors:
+//	    allowed_origins: [https://app.example.com, https://*.example.com]
+//	    allow_credentials: true
+//	    max_age: 10m
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to make cross-origin
+	// requests. "*" allows any origin, and a "*." prefix after the scheme
+	// allows any subdomain. Cross-origin requests are rejected when unset.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// AllowedMethods lists the methods allowed in preflight requests.
+	// Optional. Defaults to GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowedMethods []string `mapstructure:"allowed_methods"`
+	// AllowedHeaders lists the request headers allowed in preflight
+	// requests. Optional. Defaults to Authorization, Content-Type and
+	// X-Request-ID.
+	AllowedHeaders []string `mapstructure:"allowed_headers"`
+	// ExposedHeaders lists the response headers readable by the browser.
+	// Optional. Defaults to X-Request-ID.
+	ExposedHeaders []string `mapstructure:"exposed_headers"`
+	// AllowCredentials allows cookies and Authorization headers to be sent.
+	// A "*" origin is answered with the request origin in that case.
+	AllowCredentials bool `mapstructure:"allow_credentials"`
+	// MaxAge is how long browsers may cache preflight results. Optional.
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+func (cfg CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.HasPrefix(origin, scheme+"://") &&
+			strings.HasSuffix(strings.ToLower(origin), "."+strings.ToLower(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates an HTTP middleware that handles CORS preflight requests and adds
+// CORS headers to responses for allowed origins. Preflight requests are
+// answered with 204 No Content without calling the next handler.
+func BuildCORSMiddleware(cfg CORSConfig) Middleware {
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = defaultCORSMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = defaultCORSHeaders
+	}
+	if len(cfg.ExposedHeaders) == 0 {
+		cfg.ExposedHeaders = []string{DefaultRequestIDHeader}
+	}
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*") && !cfg.AllowCredentials
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if origin == "" || !cfg.allowsOrigin(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package http_utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	http_utils "github.com/poly-workshop/go-webmods/http-utils"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	called := false
+	handler := http_utils.BuildCORSMiddleware(http_utils.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	serve := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		called = false
+		req := httptest.NewRequest(method, "/v1/orders", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodOptions, "https://app.example.com", true)
+	if rec.Code != http.StatusNoContent || called {
+		t.Errorf("Expected preflight to be answered with 204, got %d (handler called: %v)", rec.Code, called)
+	}
+	h := rec.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" ||
+		h.Get("Access-Control-Allow-Methods") == "" ||
+		h.Get("Access-Control-Allow-Headers") == "" ||
+		h.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Unexpected preflight headers %v", h)
+	}
+
+	rec = serve(http.MethodGet, "https://shop.example.org", false)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "https://shop.example.org" ||
+		rec.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Errorf("Expected wildcard subdomain to be allowed, got %v", rec.Header())
+	}
+
+	rec = serve(http.MethodOptions, "https://evil.example.net", true)
+	if rec.Code != http.StatusForbidden || called {
+		t.Errorf("Expected preflight from unknown origin to be rejected, got %d", rec.Code)
+	}
+
+	rec = serve(http.MethodGet, "https://evil.example.net", false)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected request from unknown origin without CORS headers, got %v", rec.Header())
+	}
+
+	rec = serve(http.MethodGet, "", false)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected same-origin request without CORS headers, got %v", rec.Header())
+	}
+}
+
+func TestCORSMiddleware_AnyOrigin(t *testing.T) {
+	handler := http_utils.BuildCORSMiddleware(http_utils.CORSConfig{
+		AllowedOrigins: []string{"*"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected any origin to be allowed, got %q", got)
+	}
+}
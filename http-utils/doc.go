@@ -0,0 +1,100 @@
+// Package http_utils provides net/http middleware mirroring the grpc_utils
+// interceptors, so plain HTTP services log, trace and report metrics the same
+// way as gRPC services.
+//
+// # Middleware
+//
+// This package provides the following middleware:
+//   - BuildRequestIDMiddleware: Request ID generation and propagation
+//   - BuildLogMiddleware: Access logging via the app logger
+//   - BuildRecoveryMiddleware: Panic recovery returning 500
+//   - BuildCORSMiddleware: CORS preflight handling and response headers
+//   - BuildTimeoutMiddleware: Request timeouts returning 503
+//   - BuildGzipMiddleware: Gzip response compression
+//   - ServerMetrics: Prometheus RED metrics
+//
+// Every middleware is a func(http.Handler) http.Handler, and Chain applies
+// them in order, the first being the outermost.
+//
+// # Request ID Middleware
+//
+// The request ID middleware reads the X-Request-ID header, falls back to the
+// trace ID of a W3C traceparent header, and generates a UUID otherwise. The
+// request ID is returned in the response header, stored in the context and
+// added to the log attributes with app.WithLogAttrs, exactly like the
+// grpc_utils request ID interceptor:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//	    requestID, _ := http_utils.RequestIDFromContext(r.Context())
+//	    slog.InfoContext(r.Context(), "handling request") // includes request_id
+//	}
+//
+// # Access Logging
+//
+// BuildLogMiddleware logs a "finished http request" entry per request with
+// the http.method, http.path, http.status, http.bytes and http.time_ms
+// attributes, the same entry the grpc_utils gateway writes. Requests failing
+// with a 5xx status are logged at error level.
+//
+// # CORS
+//
+// BuildCORSMiddleware answers preflight requests from allowed origins and
+// adds the CORS headers to their responses:
+//
+//	cors := http_utils.BuildCORSMiddleware(http_utils.CORSConfig{
+//	    AllowedOrigins:   []string{"https://app.example.com"},
+//	    AllowCredentials: true,
+//	    MaxAge:           10 * time.Minute,
+//	})
+//
+// Preflight requests from other origins are rejected with 403 Forbidden, and
+// other requests from them are served without CORS headers, so browsers
+// block the response.
+//
+// # Metrics
+//
+// ServerMetrics records http_server_requests_total, http_server_errors_total,
+// http_server_handling_seconds and http_server_in_flight_requests. Requests
+// are labeled with the http.ServeMux pattern they matched, so the metrics
+// middleware should wrap the ServeMux directly:
+//
+//	registry := prometheus.NewRegistry()
+//	metrics := http_utils.NewServerMetrics(http_utils.MetricsConfig{Registry: registry})
+//
+// # Example Server Setup
+//
+//	func main() {
+//	    app.SetCMDName("myapp")
+//	    app.Init(".")
+//
+//	    logger := slog.Default()
+//	    metrics := http_utils.NewServerMetrics(http_utils.MetricsConfig{})
+//
+//	    mux := http.NewServeMux()
+//	    mux.HandleFunc("GET /v1/orders/{id}", getOrder)
+//
+//	    handler := http_utils.Chain(mux,
+//	        http_utils.BuildRecoveryMiddleware(logger),
+//	        http_utils.BuildRequestIDMiddleware(),
+//	        http_utils.BuildLogMiddleware(logger),
+//	        http_utils.BuildCORSMiddleware(corsConfig),
+//	        http_utils.BuildGzipMiddleware(0),
+//	        http_utils.BuildTimeoutMiddleware(logger, 30*time.Second),
+//	        metrics.Middleware(),
+//	    )
+//	    server := &http.Server{Addr: ":8080", Handler: handler}
+//	    if err := server.ListenAndServe(); err != nil {
+//	        panic(err)
+//	    }
+//	}
+//
+// # Best Practices
+//
+//   - Place BuildRecoveryMiddleware first so panics in other middleware are
+//     recovered too
+//   - Place BuildRequestIDMiddleware before BuildLogMiddleware so access logs
+//     carry the request ID
+//   - Place metrics last so requests are labeled with their route
+//   - Do not wrap streaming handlers with BuildTimeoutMiddleware, which
+//     buffers the response
+package http_utils
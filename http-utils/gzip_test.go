@@ -0,0 +1,81 @@
+package http_utils_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	http_utils "github.com/poly-workshop/go-webmods/http-utils"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	body := strings.Repeat("hello gzip ", 100)
+	handler := http_utils.BuildGzipMiddleware(gzip.BestSpeed)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "1100")
+			_, _ = io.WriteString(w, body)
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != "" {
+		t.Fatalf("Expected gzip response without length, got %v", rec.Header())
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected content type to be detected, got %q", rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	decoded, err := io.ReadAll(gz)
+	if err != nil || string(decoded) != body {
+		t.Errorf("Expected decompressed body to match, got %q (%v)", decoded, err)
+	}
+}
+
+func TestGzipMiddleware_Passthrough(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		handler        http.HandlerFunc
+	}{
+		{"not_accepted", "br", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "plain")
+		}},
+		{"rejected_with_q0", "gzip;q=0", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "plain")
+		}},
+		{"already_encoded", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = io.WriteString(w, "plain")
+		}},
+		{"no_content", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			http_utils.BuildGzipMiddleware(0)(tt.handler).ServeHTTP(rec, req)
+
+			if rec.Header().Get("Content-Encoding") == "gzip" {
+				t.Errorf("Expected response not to be compressed, got %v", rec.Header())
+			}
+			if rec.Code != http.StatusNoContent && rec.Body.String() != "plain" {
+				t.Errorf("Expected body to pass through, got %q", rec.Body.String())
+			}
+		})
+	}
+}
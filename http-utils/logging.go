@@ -0,0 +1,62 @@
+package http_utils
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Creates an HTTP middleware that logs every request using the provided
+// slog.Logger once it finished, with the same message and attributes as the
+// grpc_utils gateway. Requests failing with a 5xx status are logged at error
+// level.
+func BuildLogMiddleware(l *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			l.LogAttrs(r.Context(), level, "finished http request",
+				slog.String("http.method", r.Method),
+				slog.String("http.path", r.URL.Path),
+				slog.Int("http.status", rec.status),
+				slog.Int("http.bytes", rec.bytes),
+				slog.Float64("http.time_ms", float64(time.Since(start).Microseconds())/1000),
+			)
+		})
+	}
+}
+
+// Creates an HTTP middleware that recovers from panics in handlers, logging
+// the panic with its stack trace and responding with 500 Internal Server
+// Error.
+//
+// http.ErrAbortHandler is re-panicked so the server aborts the response as
+// usual.
+func BuildRecoveryMiddleware(l *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				l.ErrorContext(r.Context(), "recovered from panic",
+					slog.Any("panic", p),
+					slog.String("stack", string(debug.Stack())),
+				)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package http_utils_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/poly-workshop/go-webmods/app"
+	http_utils "github.com/poly-workshop/go-webmods/http-utils"
+)
+
+func TestLogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(app.NewLogHandler(slog.NewJSONHandler(&buf, nil)))
+	handler := http_utils.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("short and stout"))
+	}), http_utils.BuildRequestIDMiddleware(), http_utils.BuildLogMiddleware(logger))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/tea", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log output %q: %v", buf.String(), err)
+	}
+	expected := map[string]any{
+		"msg":         "finished http request",
+		"level":       "INFO",
+		"request_id":  "req-1",
+		"http.method": "POST",
+		"http.path":   "/v1/tea",
+		"http.status": float64(http.StatusTeapot),
+		"http.bytes":  float64(len("short and stout")),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["http.time_ms"]; !ok {
+		t.Errorf("Expected http.time_ms in log entry, got %v", entry)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := http_utils.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), http_utils.BuildRecoveryMiddleware(logger), http_utils.BuildLogMiddleware(logger))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+	output := buf.String()
+	if !strings.Contains(output, "recovered from panic") || !strings.Contains(output, "boom") ||
+		!strings.Contains(output, "stack") {
+		t.Errorf("Expected panic to be logged with stack, got %q", output)
+	}
+}
+
+func TestRecoveryMiddleware_AbortHandler(t *testing.T) {
+	handler := http_utils.BuildRecoveryMiddleware(discardLogger)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("Expected ErrAbortHandler to be re-panicked, got %v", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
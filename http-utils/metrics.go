@@ -0,0 +1,151 @@
+package http_utils
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute labels requests that did not match a ServeMux pattern.
+const unmatchedRoute = "unmatched"
+
+// MetricsConfig holds configuration for the Prometheus metrics middleware.
+type MetricsConfig struct {
+	// Namespace is prepended to all metric names. Optional.
+	Namespace string `mapstructure:"namespace"`
+	// Buckets are the latency histogram buckets in seconds.
+	// Optional. Defaults to prometheus.DefBuckets.
+	Buckets []float64 `mapstructure:"buckets"`
+	// Registry is the registerer the metrics are registered on, typically a
+	// *prometheus.Registry. Optional. Defaults to prometheus.DefaultRegisterer.
+	Registry prometheus.Registerer `mapstructure:"-"`
+}
+
+// ServerMetrics records request counts, error counts, latency and in-flight
+// requests of an HTTP server.
+type ServerMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// NewServerMetrics creates and registers HTTP server metrics.
+//
+// Example:
+//
+//	registry := prometheus.NewRegistry()
+//	metrics := http_utils.NewServerMetrics(http_utils.MetricsConfig{
+//	    Registry: registry,
+//	})
+//	handler := http_utils.Chain(mux, metrics.Middleware())
+func NewServerMetrics(cfg MetricsConfig) *ServerMetrics {
+	buckets := cfg.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	if cfg.Registry != nil {
+		registerer = cfg.Registry
+	}
+
+	labels := []string{"http_method", "http_route"}
+	return &ServerMetrics{
+		requests: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "http_server",
+			Name:      "requests_total",
+			Help:      "Total number of HTTP requests completed, by status code.",
+		}, append(labels, "http_code"))),
+		errors: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "http_server",
+			Name:      "errors_total",
+			Help:      "Total number of HTTP requests completed with a 5xx status code.",
+		}, append(labels, "http_code"))),
+		latency: registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "http_server",
+			Name:      "handling_seconds",
+			Help:      "Latency of HTTP requests until completion.",
+			Buckets:   buckets,
+		}, labels)),
+		inFlight: registerCollector(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "http_server",
+			Name:      "in_flight_requests",
+			Help:      "Number of HTTP requests currently in flight.",
+		})),
+	}
+}
+
+// registerCollector registers c, reusing an identical collector that is
+// already registered so metrics can be created more than once per registry.
+func registerCollector[T prometheus.Collector](registerer prometheus.Registerer, c T) T {
+	if err := registerer.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// Middleware returns an HTTP middleware recording metrics for every request.
+//
+// Requests are labeled with the http.ServeMux pattern they matched, which is
+// only known if the middleware wraps the ServeMux directly, so it should be
+// the last middleware of the chain. Other requests are labeled "unmatched",
+// which keeps the label cardinality bounded. Panicking handlers are recorded
+// with status 500.
+func (m *ServerMetrics) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.inFlight.Inc()
+			start := time.Now()
+			rec := newResponseRecorder(w)
+
+			defer func() {
+				p := recover()
+				status := rec.status
+				if p != nil {
+					status = http.StatusInternalServerError
+				}
+				route := r.Pattern
+				if route == "" {
+					route = unmatchedRoute
+				}
+				method := methodLabel(r.Method)
+				code := strconv.Itoa(status)
+				m.inFlight.Dec()
+				m.latency.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+				m.requests.WithLabelValues(method, route, code).Inc()
+				if status >= http.StatusInternalServerError {
+					m.errors.WithLabelValues(method, route, code).Inc()
+				}
+				if p != nil {
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// methodLabel returns method if it is a standard HTTP method and "other"
+// otherwise, so clients cannot create arbitrary label values.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,56 @@
+package http_utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	http_utils "github.com/poly-workshop/go-webmods/http-utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServerMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := http_utils.NewServerMetrics(http_utils.MetricsConfig{Registry: registry})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/orders/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /v1/orders", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := http_utils.Chain(mux,
+		http_utils.BuildRecoveryMiddleware(discardLogger),
+		metrics.Middleware(),
+	)
+
+	for _, path := range []string{"/v1/orders/1", "/v1/orders/2", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/orders", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/v1/orders/1", nil))
+
+	expected := `
+# HELP http_server_errors_total Total number of HTTP requests completed with a 5xx status code.
+# TYPE http_server_errors_total counter
+http_server_errors_total{http_code="500",http_method="POST",http_route="POST /v1/orders"} 1
+# HELP http_server_requests_total Total number of HTTP requests completed, by status code.
+# TYPE http_server_requests_total counter
+http_server_requests_total{http_code="200",http_method="GET",http_route="GET /v1/orders/{id}"} 2
+http_server_requests_total{http_code="404",http_method="GET",http_route="unmatched"} 1
+http_server_requests_total{http_code="405",http_method="other",http_route="unmatched"} 1
+http_server_requests_total{http_code="500",http_method="POST",http_route="POST /v1/orders"} 1
+# HELP http_server_in_flight_requests Number of HTTP requests currently in flight.
+# TYPE http_server_in_flight_requests gauge
+http_server_in_flight_requests 0
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"http_server_errors_total", "http_server_requests_total", "http_server_in_flight_requests")
+	if err != nil {
+		t.Error(err)
+	}
+	if count := testutil.CollectAndCount(registry, "http_server_handling_seconds"); count != 4 {
+		t.Errorf("Expected 4 latency series, got %d", count)
+	}
+}
@@ -0,0 +1,106 @@
+package http_utils
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/poly-workshop/go-webmods/app"
+)
+
+type contextKey string
+
+const (
+	// DefaultRequestIDHeader is the header used to read and return the
+	// request ID when no other header is configured.
+	DefaultRequestIDHeader = "X-Request-ID"
+	// TraceParentHeader is the W3C Trace Context header carrying the trace ID.
+	TraceParentHeader = "Traceparent"
+
+	requestIDKey contextKey = "request_id"
+)
+
+// RequestIDConfig holds configuration for the request ID middleware.
+type RequestIDConfig struct {
+	// Header is the header used to read the incoming request ID and to
+	// return it in the response. Optional. Defaults to "X-Request-ID".
+	Header string `mapstructure:"header"`
+	// IgnoreTraceParent disables deriving the request ID from the trace ID of
+	// an incoming W3C traceparent header when no request ID header is present.
+	IgnoreTraceParent bool `mapstructure:"ignore_trace_parent"`
+}
+
+// Creates an HTTP middleware that adds a unique request ID to the context.
+func BuildRequestIDMiddleware() Middleware {
+	return BuildRequestIDMiddlewareWithConfig(RequestIDConfig{})
+}
+
+// Creates an HTTP middleware that adds a unique request ID to the context,
+// using the provided configuration.
+//
+// The request ID is taken from the configured header, then from the trace ID
+// of a W3C traceparent header, and is generated as a UUID otherwise. It is
+// returned in the same header and added to the log attributes as
+// "request_id", like the grpc_utils request ID interceptor does.
+func BuildRequestIDMiddlewareWithConfig(cfg RequestIDConfig) Middleware {
+	header := cfg.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(header)
+			if requestID == "" && !cfg.IgnoreTraceParent {
+				requestID = traceIDFromTraceParent(r.Header.Get(TraceParentHeader))
+			}
+			if requestID == "" {
+				requestID = uuid.New().String()
+			}
+			w.Header().Set(header, requestID)
+
+			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+			ctx = app.WithLogAttrs(ctx, slog.String("request_id", requestID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID stored in the context by the
+// request ID middleware.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey).(string)
+	return requestID, ok && requestID != ""
+}
+
+// traceIDFromTraceParent extracts the trace ID from a W3C traceparent value
+// of the form "version-traceid-parentid-flags". It returns an empty string
+// if the value is malformed or the trace ID is all zeros.
+func traceIDFromTraceParent(traceParent string) string {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+	traceID := strings.ToLower(parts[1])
+	if len(traceID) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	if !isHex(parts[0]) || !isHex(traceID) || !isHex(parts[2]) {
+		return ""
+	}
+	if traceID == strings.Repeat("0", 32) {
+		return ""
+	}
+	return traceID
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
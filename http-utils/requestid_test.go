@@ -0,0 +1,82 @@
+package http_utils_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/poly-workshop/go-webmods/app"
+	http_utils "github.com/poly-workshop/go-webmods/http-utils"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(app.NewLogHandler(slog.NewJSONHandler(&buf, nil)))
+
+	var requestID string
+	handler := http_utils.BuildRequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID, _ = http_utils.RequestIDFromContext(r.Context())
+		logger.InfoContext(r.Context(), "handled")
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected string
+	}{
+		{"from_header", "X-Request-ID", "req-1", "req-1"},
+		{"from_traceparent", "Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"4bf92f3577b34da6a3ce929d0e0e4736"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(tt.header, tt.value)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if requestID != tt.expected {
+				t.Errorf("Expected request ID %q in context, got %q", tt.expected, requestID)
+			}
+			if got := rec.Header().Get("X-Request-ID"); got != tt.expected {
+				t.Errorf("Expected request ID %q in response, got %q", tt.expected, got)
+			}
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("Failed to parse log output %q: %v", buf.String(), err)
+			}
+			if entry["request_id"] != tt.expected {
+				t.Errorf("Expected request ID in log attrs, got %v", entry)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if requestID == "" || rec.Header().Get("X-Request-ID") != requestID {
+		t.Errorf("Expected generated request ID to be returned, got %q and %q",
+			requestID, rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestRequestIDMiddleware_CustomHeader(t *testing.T) {
+	handler := http_utils.BuildRequestIDMiddlewareWithConfig(http_utils.RequestIDConfig{
+		Header:            "X-Correlation-ID",
+		IgnoreTraceParent: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	got := rec.Header().Get("X-Correlation-ID")
+	if got == "" || got == "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected generated request ID ignoring traceparent, got %q", got)
+	}
+}
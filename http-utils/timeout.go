@@ -0,0 +1,30 @@
+package http_utils
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Creates an HTTP middleware that cancels the request context after timeout
+// and responds with 503 Service Unavailable if the handler has not finished
+// by then. Cut off requests are logged as warnings to l.
+//
+// The response is buffered until the handler returns, so the middleware must
+// not wrap streaming handlers.
+func BuildTimeoutMiddleware(l *slog.Logger, timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		logged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+				l.WarnContext(r.Context(), "request cut off by deadline",
+					slog.String("http.path", r.URL.Path),
+					slog.Duration("timeout", timeout),
+				)
+			}
+		})
+		return http.TimeoutHandler(logged, timeout, http.StatusText(http.StatusServiceUnavailable))
+	}
+}
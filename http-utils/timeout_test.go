@@ -0,0 +1,71 @@
+package http_utils_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	http_utils "github.com/poly-workshop/go-webmods/http-utils"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use, since
+// http.TimeoutHandler runs the handler in its own goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	var buf syncBuffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := http_utils.BuildTimeoutMiddleware(logger, 20*time.Millisecond)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+				_, _ = w.Write([]byte("too late"))
+			}
+		}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/slow", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rec.Code)
+	}
+
+	// The handler may still be returning when the response is written.
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "request cut off by deadline") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if output := buf.String(); !strings.Contains(output, "request cut off by deadline") ||
+		!strings.Contains(output, "/v1/slow") {
+		t.Errorf("Expected cut off to be logged, got %q", output)
+	}
+
+	fast := http_utils.BuildTimeoutMiddleware(logger, time.Second)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) }))
+	rec = httptest.NewRecorder()
+	fast.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("Expected fast request to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -92,6 +92,16 @@
 //
 // For more MongoDB features, see https://www.mongodb.com/docs/drivers/go/current/
 //
+// # Multi-Tenancy
+//
+// TenantDatabase selects a database per tenant, named by a prefix followed by
+// the tenant stored in the context with app.WithTenant:
+//
+//	db, err := mongo_client.TenantDatabase(ctx, client, "orders_")
+//	if err != nil {
+//	    return err // app.ErrNoTenant
+//	}
+//
 // # Connection Strings
 //
 // MongoDB connection strings support various options:
@@ -0,0 +1,33 @@
+package mongo_client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/poly-workshop/go-webmods/app"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// TenantDatabase returns the database of the tenant stored in ctx with
+// app.WithTenant, named prefix followed by the tenant ID. It returns
+// app.ErrNoTenant if ctx carries no tenant, and an error for tenants failing
+// app.ValidTenant, such as mixed case IDs that MongoDB would confuse with
+// their lowercase form.
+//
+// Example:
+//
+//	db, err := mongo_client.TenantDatabase(ctx, client, "orders_")
+//	if err != nil {
+//	    return err
+//	}
+//	_, err = db.Collection("orders").InsertOne(ctx, order)
+func TenantDatabase(ctx context.Context, client *mongo.Client, prefix string) (*mongo.Database, error) {
+	tenant, ok := app.TenantFromContext(ctx)
+	if !ok {
+		return nil, app.ErrNoTenant
+	}
+	if !app.ValidTenant(tenant) {
+		return nil, fmt.Errorf("invalid tenant %q", tenant)
+	}
+	return client.Database(prefix + tenant), nil
+}
@@ -37,3 +37,27 @@ func NewObjectStorage(cfg Config) (ObjectStorage, error) {
 		return nil, fmt.Errorf("unsupported object storage provider: %s", cfg.ProviderType)
 	}
 }
+
+// NewContextObjectStorage creates the context-first storage of the configured
+// provider.
+func NewContextObjectStorage(cfg Config) (ContextObjectStorage, error) {
+	switch cfg.ProviderType {
+	case ProviderLocal:
+		return unlessError(NewLocalObjectStorage(cfg.ProviderConfig))
+	case ProviderVolcengine:
+		return unlessError(NewTOSObjectStorage(cfg.ProviderConfig))
+	case ProviderMinio:
+		return unlessError(NewMinioObjectStorage(cfg.ProviderConfig))
+	default:
+		return nil, fmt.Errorf("unsupported object storage provider: %s", cfg.ProviderType)
+	}
+}
+
+// unlessError returns storage as a ContextObjectStorage, or a nil interface
+// rather than a typed nil pointer if err is set.
+func unlessError[T ContextObjectStorage](storage T, err error) (ContextObjectStorage, error) {
+	if err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
@@ -1,6 +1,7 @@
 package object_storage
 
 import (
+	"io"
 	"os"
 	"strings"
 	"testing"
@@ -103,3 +104,77 @@ func TestNewObjectStorageMinio(t *testing.T) {
 		t.Fatalf("Expected basePath 'test', got %q", minioStorage.basePath)
 	}
 }
+
+func TestNewContextObjectStorage(t *testing.T) {
+	storage, err := NewContextObjectStorage(Config{
+		ProviderType: ProviderLocal,
+		ProviderConfig: ProviderConfig{
+			BasePath: t.TempDir(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local object storage: %v", err)
+	}
+	if _, ok := storage.(*LocalObjectStorage); !ok {
+		t.Fatalf("Expected LocalObjectStorage, got %T", storage)
+	}
+
+	storage, err = NewContextObjectStorage(Config{ProviderType: "unsupported"})
+	if err == nil || storage != nil {
+		t.Fatalf("Expected error and nil storage, got %v and %v", err, storage)
+	}
+}
+
+// contextOnlyStorage hides the ObjectStorage methods of the wrapped storage.
+type contextOnlyStorage struct {
+	ContextObjectStorage
+}
+
+func TestObjectStorageAdapter(t *testing.T) {
+	local, err := NewLocalObjectStorage(ProviderConfig{
+		BasePath: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	storage := NewObjectStorageAdapter(contextOnlyStorage{local})
+
+	if _, err := storage.Save("dir/file.txt", strings.NewReader("data")); err != nil {
+		t.Fatalf("Failed to save file: %v", err)
+	}
+	objects, err := storage.List("dir")
+	if err != nil {
+		t.Fatalf("Failed to list objects: %v", err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("Expected 1 object, got %d", len(objects))
+	}
+	info, err := objects[0].Stat()
+	if err != nil {
+		t.Fatalf("Failed to stat object: %v", err)
+	}
+	if info.Size() != 4 {
+		t.Errorf("Expected size 4, got %d", info.Size())
+	}
+	_ = objects[0].Close()
+
+	obj, err := storage.Open("dir/file.txt")
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	data, err := io.ReadAll(obj)
+	_ = obj.Close()
+	if err != nil || string(data) != "data" {
+		t.Fatalf("Expected data %q, got %q (%v)", "data", data, err)
+	}
+
+	if err := storage.Delete("dir/file.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if _, err := storage.Stat("dir/file.txt"); !IsNotExist(err) {
+		t.Errorf("Expected IsNotExist after delete, got %v", err)
+	}
+	if _, err := storage.Open("dir/file.txt"); !IsNotExist(err) {
+		t.Errorf("Expected IsNotExist opening deleted file, got %v", err)
+	}
+}
@@ -103,6 +103,71 @@
 //
 //	err := storage.Delete("photos/photo.jpg")
 //
+// # Context-Aware API
+//
+// The providers also implement ContextObjectStorage, whose methods take a
+// context, so uploads and downloads can be canceled or bounded per request.
+// NewContextObjectStorage returns the configured provider as one:
+//
+//	storage, err := object_storage.NewContextObjectStorage(cfg)
+//	if err != nil {
+//	    panic(err)
+//	}
+//
+//	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+//	defer cancel()
+//	size, err := storage.SaveContext(ctx, "photos/photo.jpg", file)
+//
+// Objects returned by OpenContext and ListContext read with the context they
+// were opened with, since io.Reader does not accept one:
+//
+//	obj, err := storage.OpenContext(ctx, "photos/photo.jpg")
+//	if err != nil {
+//	    return err
+//	}
+//	defer obj.Close()
+//	_, err = io.Copy(w, obj) // fails with ctx.Err() once ctx is done
+//
+// The ObjectStorage methods call the same code with context.Background().
+// NewObjectStorageAdapter adapts other ContextObjectStorage implementations to
+// ObjectStorage the same way.
+//
+// # Presigned URLs
+//
+// The local, MinIO and TOS providers implement Presigner, which mints URLs
+// that browsers use to download and upload objects directly, without
+// credentials:
+//
+//	presigner := storage.(object_storage.Presigner)
+//	req, err := presigner.PresignPut(ctx, "uploads/photo.jpg", object_storage.PresignOptions{
+//	    Expiry:      10 * time.Minute,
+//	    ContentType: "image/jpeg",
+//	    Size:        size,
+//	})
+//	// Send req.Method, req.URL and req.Header to the browser, which uploads
+//	// with fetch(req.URL, {method: req.Method, headers: req.Header, body: file})
+//
+// PresignGet serves the object with opts.ContentType if set. The local and
+// MinIO PresignPut require uploads to send opts.ContentType and exactly
+// opts.Size bytes, and return the headers to send in PresignedRequest.Header.
+//
+// LocalObjectStorage signs URLs with HMAC-SHA256 using SecretKey, and serves
+// them with Handler, mounted at the path of Endpoint:
+//
+//	storage, err := object_storage.NewContextObjectStorage(object_storage.Config{
+//	    ProviderType: object_storage.ProviderLocal,
+//	    ProviderConfig: object_storage.ProviderConfig{
+//	        BasePath:  "/var/data",
+//	        Endpoint:  "http://localhost:8080/storage",
+//	        SecretKey: "change-me",
+//	    },
+//	})
+//	mux.Handle("/storage/", storage.(*object_storage.LocalObjectStorage).Handler())
+//
+// TOS query signatures only cover the host and x-tos-* headers, so its
+// PresignPut returns the content type as an advisory header without enforcing
+// it, and rejects opts.Size with an error.
+//
 // # Interface Design
 //
 // The ObjectStorage interface provides a consistent API across all providers:
@@ -124,6 +189,21 @@
 //
 // This allows seamless use with Go's standard I/O functions.
 //
+// ContextObjectStorage and ContextObject are the context-first variants:
+//
+//	type ContextObjectStorage interface {
+//	    SaveContext(ctx context.Context, path string, r io.Reader) (int64, error)
+//	    ListContext(ctx context.Context, path string) ([]ContextObject, error)
+//	    OpenContext(ctx context.Context, path string) (ContextObject, error)
+//	    StatContext(ctx context.Context, path string) (os.FileInfo, error)
+//	    DeleteContext(ctx context.Context, path string) error
+//	}
+//
+//	type ContextObject interface {
+//	    io.ReadSeekCloser
+//	    StatContext(ctx context.Context) (os.FileInfo, error)
+//	}
+//
 // # Configuration with Viper
 //
 // Example configuration file (configs/default.yaml):
@@ -164,6 +244,14 @@
 //	    },
 //	})
 //
+// # Multi-Tenancy
+//
+// TenantKey prefixes a key with the tenant stored in the context with
+// app.WithTenant. The key is cleaned first, so ".." segments cannot reach
+// another tenant's objects:
+//
+//	key, err := object_storage.TenantKey(ctx, "invoices/1.pdf") // acme/invoices/1.pdf
+//
 // # Best Practices
 //
 //   - Use local provider for development and testing
@@ -174,7 +262,7 @@
 //   - Use Stat() to check file existence before Open()
 //   - Handle errors appropriately (file not found, permission denied, etc.)
 //   - For large files, use streaming (Open/Read) instead of loading into memory
-//   - Consider using signed URLs for direct client uploads (provider-specific)
+//   - Use presigned URLs for direct client uploads of large files
 //
 // # Error Handling
 //
@@ -195,10 +283,19 @@
 //	}
 //	defer obj.Close()
 //
+// IsNotExist reports whether an error means the object does not exist for
+// every provider, including MinIO and TOS responses:
+//
+//	if object_storage.IsNotExist(err) {
+//	    // Handle object not found
+//	}
+//
 // # Provider-Specific Notes
 //
 // Local Provider:
 //   - Automatically creates directories as needed
+//   - Save removes the partial file when the copy fails or ctx is done
+//   - Endpoint and SecretKey are only used for presigned URLs
 //   - BasePath is the root directory
 //   - Paths are relative to BasePath
 //
@@ -209,6 +306,7 @@
 //
 // Volcengine TOS Provider:
 //   - UseInternal=true uses internal endpoint (for in-region VMs)
+//   - Presigned URLs always use the public endpoint
 //   - Supports Volcengine-specific features
 //   - Region must match bucket region
 package object_storage
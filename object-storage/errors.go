@@ -0,0 +1,40 @@
+package object_storage
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/volcengine/ve-tos-golang-sdk/v2/tos"
+)
+
+// IsNotExist reports whether err indicates that an object or bucket does not
+// exist, regardless of the provider that returned it.
+func IsNotExist(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		switch minioErr.Code {
+		case "NoSuchKey", "NoSuchBucket", "NotFound":
+			return true
+		}
+		return minioErr.StatusCode == http.StatusNotFound
+	}
+
+	var tosErr *tos.TosServerError
+	if errors.As(err, &tosErr) {
+		return tosErr.StatusCode == http.StatusNotFound
+	}
+	var tosStatusErr *tos.UnexpectedStatusCodeError
+	if errors.As(err, &tosStatusErr) {
+		return tosStatusErr.StatusCode == http.StatusNotFound
+	}
+	return false
+}
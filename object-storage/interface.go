@@ -1,6 +1,7 @@
 package object_storage
 
 import (
+	"context"
 	"io"
 	"os"
 )
@@ -17,3 +18,103 @@ type ObjectStorage interface {
 	Stat(path string) (os.FileInfo, error)
 	Delete(path string) error
 }
+
+// ContextObject is an object opened from a ContextObjectStorage. Read and Seek
+// use the context the object was opened or listed with, since io.Reader and
+// io.Seeker do not accept one.
+type ContextObject interface {
+	io.ReadSeekCloser
+	StatContext(ctx context.Context) (os.FileInfo, error)
+}
+
+// ContextObjectStorage is the context-first variant of ObjectStorage. Every
+// call is canceled when its context is done. The local, MinIO and TOS storages
+// implement both interfaces.
+type ContextObjectStorage interface {
+	SaveContext(ctx context.Context, path string, r io.Reader) (int64, error)
+	ListContext(ctx context.Context, path string) ([]ContextObject, error)
+	OpenContext(ctx context.Context, path string) (ContextObject, error)
+	StatContext(ctx context.Context, path string) (os.FileInfo, error)
+	DeleteContext(ctx context.Context, path string) error
+}
+
+var (
+	_ ObjectStorage        = (*LocalObjectStorage)(nil)
+	_ ObjectStorage        = (*MinioObjectStorage)(nil)
+	_ ObjectStorage        = (*TOSObjectStorage)(nil)
+	_ ContextObjectStorage = (*LocalObjectStorage)(nil)
+	_ ContextObjectStorage = (*MinioObjectStorage)(nil)
+	_ ContextObjectStorage = (*TOSObjectStorage)(nil)
+)
+
+// asObjects returns the objects listed by a provider as I, either Object or
+// ContextObject, both of which the provider objects implement.
+func asObjects[I any, T any](objects []T, err error) ([]I, error) {
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]I, len(objects))
+	for i, obj := range objects {
+		converted[i] = any(obj).(I)
+	}
+	return converted, nil
+}
+
+// ObjectStorageAdapter adapts any ContextObjectStorage to the ObjectStorage
+// interface by calling it with context.Background(). The built-in providers
+// implement ObjectStorage themselves.
+type ObjectStorageAdapter struct {
+	Storage ContextObjectStorage
+}
+
+// NewObjectStorageAdapter returns an ObjectStorage calling storage with
+// context.Background().
+func NewObjectStorageAdapter(storage ContextObjectStorage) *ObjectStorageAdapter {
+	return &ObjectStorageAdapter{Storage: storage}
+}
+
+// Save saves r to path.
+func (a *ObjectStorageAdapter) Save(path string, r io.Reader) (int64, error) {
+	return a.Storage.SaveContext(context.Background(), path, r)
+}
+
+// List lists the objects under path.
+func (a *ObjectStorageAdapter) List(path string) ([]Object, error) {
+	objects, err := a.Storage.ListContext(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	adapted := make([]Object, len(objects))
+	for i, obj := range objects {
+		adapted[i] = objectAdapter{obj}
+	}
+	return adapted, nil
+}
+
+// Open opens the object at path for reading.
+func (a *ObjectStorageAdapter) Open(path string) (Object, error) {
+	obj, err := a.Storage.OpenContext(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	return objectAdapter{obj}, nil
+}
+
+// Stat returns the object information of path.
+func (a *ObjectStorageAdapter) Stat(path string) (os.FileInfo, error) {
+	return a.Storage.StatContext(context.Background(), path)
+}
+
+// Delete deletes the object at path.
+func (a *ObjectStorageAdapter) Delete(path string) error {
+	return a.Storage.DeleteContext(context.Background(), path)
+}
+
+// objectAdapter adapts a ContextObject to the Object interface.
+type objectAdapter struct {
+	ContextObject
+}
+
+func (o objectAdapter) Stat() (os.FileInfo, error) {
+	return o.StatContext(context.Background())
+}
@@ -1,22 +1,35 @@
 package object_storage
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 )
 
-// LocalObjectStorage implements ObjectStorage interface for local file system
+// LocalObjectStorage implements ObjectStorage and ContextObjectStorage
+// interfaces for local file system
 type LocalObjectStorage struct {
 	basePath string
+
+	// endpoint and secretKey sign the URLs served by Handler
+	endpoint  *url.URL
+	secretKey []byte
+	now       func() time.Time
 }
 
-// LocalObject implements Object interface for local files
+// LocalObject implements Object and ContextObject interfaces for local files
 type LocalObject struct {
+	ctx  context.Context
 	file *os.File
 }
 
-// NewLocalObjectStorage creates a new local object storage instance
+// NewLocalObjectStorage creates a new local object storage instance. Endpoint
+// is the URL Handler is served at and SecretKey signs its presigned URLs;
+// both are only required for presigning.
 func NewLocalObjectStorage(config ProviderConfig) (*LocalObjectStorage, error) {
 	basePath := config.BasePath
 	if basePath == "" {
@@ -28,8 +41,19 @@ func NewLocalObjectStorage(config ProviderConfig) (*LocalObjectStorage, error) {
 		return nil, err
 	}
 
+	var endpoint *url.URL
+	if config.Endpoint != "" {
+		var err error
+		if endpoint, err = url.Parse(config.Endpoint); err != nil {
+			return nil, fmt.Errorf("invalid local storage endpoint: %w", err)
+		}
+	}
+
 	return &LocalObjectStorage{
-		basePath: basePath,
+		basePath:  basePath,
+		endpoint:  endpoint,
+		secretKey: []byte(config.SecretKey),
+		now:       time.Now,
 	}, nil
 }
 
@@ -40,6 +64,15 @@ func (s *LocalObjectStorage) getObjectPath(objectPath string) string {
 
 // Save saves a file to the local storage
 func (s *LocalObjectStorage) Save(path string, r io.Reader) (int64, error) {
+	return s.SaveContext(context.Background(), path, r)
+}
+
+// SaveContext saves a file to the local storage. Copying stops when ctx is
+// done.
+func (s *LocalObjectStorage) SaveContext(ctx context.Context, path string, r io.Reader) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 	fullPath := s.getObjectPath(path)
 
 	// Create directory if it doesn't exist
@@ -56,8 +89,10 @@ func (s *LocalObjectStorage) Save(path string, r io.Reader) (int64, error) {
 		_ = file.Close() // Ignore close error as we've already written successfully
 	}()
 
-	written, err := io.Copy(file, r)
+	written, err := io.Copy(file, contextReader{ctx: ctx, r: r})
 	if err != nil {
+		// Do not leave a partial object behind
+		_ = os.Remove(fullPath)
 		return 0, err
 	}
 
@@ -66,19 +101,32 @@ func (s *LocalObjectStorage) Save(path string, r io.Reader) (int64, error) {
 
 // List lists objects in the given path
 func (s *LocalObjectStorage) List(path string) ([]Object, error) {
+	return asObjects[Object](s.list(context.Background(), path))
+}
+
+// ListContext lists objects in the given path. Reads of the objects fail once
+// ctx is done.
+func (s *LocalObjectStorage) ListContext(ctx context.Context, path string) ([]ContextObject, error) {
+	return asObjects[ContextObject](s.list(ctx, path))
+}
+
+func (s *LocalObjectStorage) list(ctx context.Context, path string) ([]*LocalObject, error) {
 	fullPath := s.getObjectPath(path)
 
 	// Check if path exists
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return []Object{}, nil
+		return []*LocalObject{}, nil
 	}
 
-	var objects []Object
+	var objects []*LocalObject
 
 	err := filepath.Walk(fullPath, func(walkPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Skip directories and the root path itself
 		if info.IsDir() || walkPath == fullPath {
@@ -90,10 +138,13 @@ func (s *LocalObjectStorage) List(path string) ([]Object, error) {
 			return err
 		}
 
-		objects = append(objects, &LocalObject{file: file})
+		objects = append(objects, &LocalObject{ctx: ctx, file: file})
 		return nil
 	})
 	if err != nil {
+		for _, obj := range objects {
+			_ = obj.Close()
+		}
 		return nil, err
 	}
 
@@ -102,6 +153,18 @@ func (s *LocalObjectStorage) List(path string) ([]Object, error) {
 
 // Open opens a file for reading
 func (s *LocalObjectStorage) Open(path string) (Object, error) {
+	return s.open(context.Background(), path)
+}
+
+// OpenContext opens a file for reading. Reads fail once ctx is done.
+func (s *LocalObjectStorage) OpenContext(ctx context.Context, path string) (ContextObject, error) {
+	return s.open(ctx, path)
+}
+
+func (s *LocalObjectStorage) open(ctx context.Context, path string) (*LocalObject, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	fullPath := s.getObjectPath(path)
 
 	file, err := os.Open(fullPath)
@@ -109,25 +172,44 @@ func (s *LocalObjectStorage) Open(path string) (Object, error) {
 		return nil, err
 	}
 
-	return &LocalObject{file: file}, nil
+	return &LocalObject{ctx: ctx, file: file}, nil
 }
 
 // Stat returns file information
 func (s *LocalObjectStorage) Stat(path string) (os.FileInfo, error) {
+	return s.StatContext(context.Background(), path)
+}
+
+// StatContext returns file information
+func (s *LocalObjectStorage) StatContext(ctx context.Context, path string) (os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	fullPath := s.getObjectPath(path)
 	return os.Stat(fullPath)
 }
 
 // Delete deletes a file
 func (s *LocalObjectStorage) Delete(path string) error {
+	return s.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext deletes a file
+func (s *LocalObjectStorage) DeleteContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fullPath := s.getObjectPath(path)
 	return os.Remove(fullPath)
 }
 
 // LocalObject methods
 
-// Read implements io.Reader
+// Read implements io.Reader. It fails once the context of the object is done.
 func (o *LocalObject) Read(p []byte) (n int, err error) {
+	if err := o.ctx.Err(); err != nil {
+		return 0, err
+	}
 	return o.file.Read(p)
 }
 
@@ -145,3 +227,25 @@ func (o *LocalObject) Close() error {
 func (o *LocalObject) Stat() (os.FileInfo, error) {
 	return o.file.Stat()
 }
+
+// StatContext returns file information
+func (o *LocalObject) StatContext(ctx context.Context) (os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return o.file.Stat()
+}
+
+// contextReader fails reads once ctx is done, so copies from slow readers
+// can be canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -1,6 +1,8 @@
 package object_storage
 
 import (
+	"context"
+	"errors"
 	"io"
 	"os"
 	"strings"
@@ -144,3 +146,94 @@ func TestLocalObjectStorageDefaultPath(t *testing.T) {
 	// Clean up
 	_ = storage.Delete(testPath)
 }
+
+func TestLocalObjectStorage_IsNotExist(t *testing.T) {
+	storage, err := NewLocalObjectStorage(ProviderConfig{
+		BasePath: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+
+	_, err = storage.Stat("missing.txt")
+	if !IsNotExist(err) {
+		t.Errorf("Expected IsNotExist for missing object, got %v", err)
+	}
+	_, err = storage.Open("missing.txt")
+	if !IsNotExist(err) {
+		t.Errorf("Expected IsNotExist when opening missing object, got %v", err)
+	}
+	if IsNotExist(nil) {
+		t.Error("Expected IsNotExist(nil) to be false")
+	}
+}
+
+func TestLocalObjectStorage_Context(t *testing.T) {
+	storage, err := NewLocalObjectStorage(ProviderConfig{
+		BasePath: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := storage.SaveContext(ctx, "file.txt", strings.NewReader("data")); err != nil {
+		t.Fatalf("Failed to save file: %v", err)
+	}
+	obj, err := storage.OpenContext(ctx, "file.txt")
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer func() { _ = obj.Close() }()
+
+	cancel()
+	if _, err := obj.Read(make([]byte, 4)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled reading after cancel, got %v", err)
+	}
+	if _, err := storage.SaveContext(ctx, "other.txt", strings.NewReader("data")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled saving after cancel, got %v", err)
+	}
+	if _, err := storage.StatContext(ctx, "file.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for stat after cancel, got %v", err)
+	}
+	if _, err := storage.ListContext(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled listing after cancel, got %v", err)
+	}
+	if err := storage.DeleteContext(ctx, "file.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled deleting after cancel, got %v", err)
+	}
+	if _, err := storage.Stat("other.txt"); !IsNotExist(err) {
+		t.Errorf("Expected nothing saved after cancel, got %v", err)
+	}
+}
+
+func TestLocalObjectStorage_SaveCanceledMidCopy(t *testing.T) {
+	storage, err := NewLocalObjectStorage(ProviderConfig{
+		BasePath: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := io.MultiReader(
+		strings.NewReader("first"),
+		readerFunc(func(p []byte) (int, error) {
+			cancel()
+			return copy(p, "second"), nil
+		}),
+		strings.NewReader("third"),
+	)
+	if _, err := storage.SaveContext(ctx, "file.txt", r); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := storage.Stat("file.txt"); !IsNotExist(err) {
+		t.Errorf("Expected partial file to be removed, got %v", err)
+	}
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
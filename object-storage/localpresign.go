@@ -0,0 +1,200 @@
+package object_storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters of local presigned URLs.
+const (
+	localPresignExpires     = "expires"
+	localPresignContentType = "content-type"
+	localPresignSize        = "size"
+	localPresignSignature   = "signature"
+)
+
+var errLocalPresignConfig = errors.New("presigning local objects requires an endpoint and a secret key")
+
+// cleanKey returns the object key of p with ".." segments resolved, so signed
+// keys cannot reach outside the base path.
+func cleanKey(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// sign returns the HMAC-SHA256 signature of a request to key.
+func (s *LocalObjectStorage) sign(method, key string, expires int64, contentType string, size int64) string {
+	mac := hmac.New(sha256.New, s.secretKey)
+	mac.Write([]byte(strings.Join([]string{
+		method,
+		key,
+		strconv.FormatInt(expires, 10),
+		contentType,
+		strconv.FormatInt(size, 10),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalObjectStorage) presign(method, p string, opts PresignOptions) (PresignedRequest, error) {
+	if s.endpoint == nil || len(s.secretKey) == 0 {
+		return PresignedRequest{}, errLocalPresignConfig
+	}
+	key := cleanKey(p)
+	expires := s.now().Add(opts.expiry()).Unix()
+	size := int64(0)
+	header := http.Header{}
+	if method == http.MethodPut {
+		size = opts.Size
+		header = opts.putHeader()
+	}
+
+	u := s.endpoint.JoinPath(key)
+	query := u.Query()
+	query.Set(localPresignExpires, strconv.FormatInt(expires, 10))
+	if opts.ContentType != "" {
+		query.Set(localPresignContentType, opts.ContentType)
+	}
+	if size > 0 {
+		query.Set(localPresignSize, strconv.FormatInt(size, 10))
+	}
+	query.Set(localPresignSignature, s.sign(method, key, expires, opts.ContentType, size))
+	u.RawQuery = query.Encode()
+
+	return PresignedRequest{
+		Method:  method,
+		URL:     u.String(),
+		Header:  header,
+		Expires: time.Unix(expires, 0),
+	}, nil
+}
+
+// PresignGet returns a URL downloading the object at path from Handler. The
+// response is served with opts.ContentType if set.
+func (s *LocalObjectStorage) PresignGet(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error) {
+	return s.presign(http.MethodGet, path, opts)
+}
+
+// PresignPut returns a URL uploading the object at path to Handler. Uploads
+// sending another content type or size are rejected.
+func (s *LocalObjectStorage) PresignPut(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error) {
+	return s.presign(http.MethodPut, path, opts)
+}
+
+// Handler returns the handler serving the URLs of PresignGet and PresignPut.
+// It must be mounted at the path of the Endpoint, for example
+// mux.Handle("/storage/", storage.Handler()) for the endpoint
+// http://localhost:8080/storage. Requests without a valid signature are
+// rejected with 403 Forbidden.
+func (s *LocalObjectStorage) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.endpoint == nil || len(s.secretKey) == 0 {
+			http.Error(w, "presigned URLs are not enabled", http.StatusForbidden)
+			return
+		}
+		prefix := strings.TrimSuffix(s.endpoint.Path, "/") + "/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+		key := cleanKey(strings.TrimPrefix(r.URL.Path, prefix))
+		if key == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		expires, err := strconv.ParseInt(query.Get(localPresignExpires), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+		size, err := strconv.ParseInt(query.Get(localPresignSize), 10, 64)
+		if err != nil && query.Has(localPresignSize) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+		contentType := query.Get(localPresignContentType)
+		want := s.sign(r.Method, key, expires, contentType, size)
+		if !hmac.Equal([]byte(query.Get(localPresignSignature)), []byte(want)) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+		if s.now().Unix() > expires {
+			http.Error(w, "URL expired", http.StatusForbidden)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			s.serveGet(w, r, key, contentType)
+			return
+		}
+		s.servePut(w, r, key, contentType, size)
+	})
+}
+
+func (s *LocalObjectStorage) serveGet(w http.ResponseWriter, r *http.Request, key, contentType string) {
+	obj, err := s.OpenContext(r.Context(), key)
+	if err != nil {
+		writeLocalError(w, err)
+		return
+	}
+	defer func() { _ = obj.Close() }()
+	info, err := obj.StatContext(r.Context())
+	if err != nil {
+		writeLocalError(w, err)
+		return
+	}
+	if info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, path.Base(key), info.ModTime(), obj)
+}
+
+func (s *LocalObjectStorage) servePut(w http.ResponseWriter, r *http.Request, key, contentType string, size int64) {
+	if contentType != "" && r.Header.Get("Content-Type") != contentType {
+		http.Error(w, "content type does not match the signature", http.StatusForbidden)
+		return
+	}
+	body := r.Body
+	if size > 0 {
+		if r.ContentLength != size {
+			http.Error(w, "content length does not match the signature", http.StatusForbidden)
+			return
+		}
+		body = http.MaxBytesReader(w, r.Body, size)
+	}
+	if _, err := s.SaveContext(r.Context(), key, body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeLocalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeLocalError(w http.ResponseWriter, err error) {
+	if IsNotExist(err) {
+		http.Error(w, "object not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
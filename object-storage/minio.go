@@ -3,15 +3,17 @@ package object_storage
 import (
 	"context"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 type MinioObjectStorage struct {
-	ctx    context.Context
 	client *minio.Client
 
 	bucket   string
@@ -56,7 +58,11 @@ func (o *MinioObject) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (o *MinioObject) Stat() (os.FileInfo, error) {
-	stat, err := o.client.StatObject(o.ctx, o.bucket, o.key, minio.StatObjectOptions{})
+	return o.StatContext(o.ctx)
+}
+
+func (o *MinioObject) StatContext(ctx context.Context) (os.FileInfo, error) {
+	stat, err := o.client.StatObject(ctx, o.bucket, o.key, minio.StatObjectOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +116,6 @@ func NewMinioObjectStorage(config ProviderConfig) (*MinioObjectStorage, error) {
 	}
 
 	return &MinioObjectStorage{
-		ctx:      context.Background(),
 		client:   client,
 		bucket:   config.Bucket,
 		basePath: config.BasePath,
@@ -122,10 +127,14 @@ func (s *MinioObjectStorage) getObjectPath(objectPath string) string {
 }
 
 func (s *MinioObjectStorage) Save(path string, r io.Reader) (int64, error) {
+	return s.SaveContext(context.Background(), path, r)
+}
+
+func (s *MinioObjectStorage) SaveContext(ctx context.Context, path string, r io.Reader) (int64, error) {
 	objectPath := s.getObjectPath(path)
 
 	// Upload object to MinIO
-	info, err := s.client.PutObject(s.ctx, s.bucket, objectPath, r, -1, minio.PutObjectOptions{})
+	info, err := s.client.PutObject(ctx, s.bucket, objectPath, r, -1, minio.PutObjectOptions{})
 	if err != nil {
 		return 0, err
 	}
@@ -134,11 +143,19 @@ func (s *MinioObjectStorage) Save(path string, r io.Reader) (int64, error) {
 }
 
 func (s *MinioObjectStorage) List(path string) ([]Object, error) {
+	return asObjects[Object](s.list(context.Background(), path))
+}
+
+func (s *MinioObjectStorage) ListContext(ctx context.Context, path string) ([]ContextObject, error) {
+	return asObjects[ContextObject](s.list(ctx, path))
+}
+
+func (s *MinioObjectStorage) list(ctx context.Context, path string) ([]*MinioObject, error) {
 	prefix := s.getObjectPath(path)
 
 	// List objects with prefix
-	objects := []Object{}
-	for objInfo := range s.client.ListObjects(s.ctx, s.bucket, minio.ListObjectsOptions{
+	objects := []*MinioObject{}
+	for objInfo := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
 		Prefix:    prefix,
 		Recursive: true,
 	}) {
@@ -152,7 +169,7 @@ func (s *MinioObjectStorage) List(path string) ([]Object, error) {
 		}
 
 		objects = append(objects, &MinioObject{
-			ctx:    s.ctx,
+			ctx:    ctx,
 			client: s.client,
 			bucket: s.bucket,
 			key:    objInfo.Key,
@@ -163,20 +180,32 @@ func (s *MinioObjectStorage) List(path string) ([]Object, error) {
 }
 
 func (s *MinioObjectStorage) Open(path string) (Object, error) {
+	return s.open(context.Background(), path), nil
+}
+
+func (s *MinioObjectStorage) OpenContext(ctx context.Context, path string) (ContextObject, error) {
+	return s.open(ctx, path), nil
+}
+
+func (s *MinioObjectStorage) open(ctx context.Context, path string) *MinioObject {
 	objectPath := s.getObjectPath(path)
 
 	return &MinioObject{
-		ctx:    s.ctx,
+		ctx:    ctx,
 		client: s.client,
 		bucket: s.bucket,
 		key:    objectPath,
-	}, nil
+	}
 }
 
 func (s *MinioObjectStorage) Stat(path string) (os.FileInfo, error) {
+	return s.StatContext(context.Background(), path)
+}
+
+func (s *MinioObjectStorage) StatContext(ctx context.Context, path string) (os.FileInfo, error) {
 	objectPath := s.getObjectPath(path)
 
-	stat, err := s.client.StatObject(s.ctx, s.bucket, objectPath, minio.StatObjectOptions{})
+	stat, err := s.client.StatObject(ctx, s.bucket, objectPath, minio.StatObjectOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -190,7 +219,51 @@ func (s *MinioObjectStorage) Stat(path string) (os.FileInfo, error) {
 }
 
 func (s *MinioObjectStorage) Delete(path string) error {
+	return s.DeleteContext(context.Background(), path)
+}
+
+func (s *MinioObjectStorage) DeleteContext(ctx context.Context, path string) error {
 	objectPath := s.getObjectPath(path)
 
-	return s.client.RemoveObject(s.ctx, s.bucket, objectPath, minio.RemoveObjectOptions{})
+	return s.client.RemoveObject(ctx, s.bucket, objectPath, minio.RemoveObjectOptions{})
+}
+
+// PresignGet returns a presigned URL downloading the object at path. The
+// response is served with opts.ContentType if set.
+func (s *MinioObjectStorage) PresignGet(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error) {
+	expiry := opts.expiry()
+	params := url.Values{}
+	if opts.ContentType != "" {
+		params.Set("response-content-type", opts.ContentType)
+	}
+
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, s.getObjectPath(path), expiry, params)
+	if err != nil {
+		return PresignedRequest{}, err
+	}
+	return PresignedRequest{
+		Method:  http.MethodGet,
+		URL:     u.String(),
+		Header:  http.Header{},
+		Expires: time.Now().Add(expiry),
+	}, nil
+}
+
+// PresignPut returns a presigned URL uploading the object at path. The
+// content type and size are signed, so uploads sending other values are
+// rejected.
+func (s *MinioObjectStorage) PresignPut(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error) {
+	expiry := opts.expiry()
+	header := opts.putHeader()
+
+	u, err := s.client.PresignHeader(ctx, http.MethodPut, s.bucket, s.getObjectPath(path), expiry, nil, header)
+	if err != nil {
+		return PresignedRequest{}, err
+	}
+	return PresignedRequest{
+		Method:  http.MethodPut,
+		URL:     u.String(),
+		Header:  header,
+		Expires: time.Now().Add(expiry),
+	}, nil
 }
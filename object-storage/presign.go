@@ -0,0 +1,69 @@
+package object_storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultPresignExpiry = 15 * time.Minute
+
+// errPresignSize is returned by providers that cannot enforce
+// PresignOptions.Size, rather than issuing a URL accepting any size.
+var errPresignSize = errors.New("presigned upload size is not supported by this provider")
+
+// PresignOptions constrains a presigned request.
+type PresignOptions struct {
+	// Expiry is how long the URL stays valid. Optional. Defaults to 15
+	// minutes.
+	Expiry time.Duration
+	// ContentType is the content type of the object. PresignGet serves the
+	// object with it, and PresignPut requires uploads to send it, except on
+	// TOS where it is advisory. Optional.
+	ContentType string
+	// Size is the exact size in bytes uploads must have. Only used by
+	// PresignPut. Optional.
+	Size int64
+}
+
+// PresignedRequest is a request clients send without credentials, typically
+// from a browser.
+type PresignedRequest struct {
+	// Method is the HTTP method of the request.
+	Method string
+	// URL is the presigned URL.
+	URL string
+	// Header lists the headers the request must send with these values.
+	Header http.Header
+	// Expires is when the URL stops being valid.
+	Expires time.Time
+}
+
+// Presigner mints presigned URLs for downloading and uploading objects. It is
+// implemented by the local, MinIO and TOS providers.
+type Presigner interface {
+	PresignGet(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error)
+	PresignPut(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error)
+}
+
+// expiry returns how long the URL stays valid.
+func (o PresignOptions) expiry() time.Duration {
+	if o.Expiry <= 0 {
+		return defaultPresignExpiry
+	}
+	return o.Expiry
+}
+
+// putHeader returns the headers uploads must send.
+func (o PresignOptions) putHeader() http.Header {
+	header := http.Header{}
+	if o.ContentType != "" {
+		header.Set("Content-Type", o.ContentType)
+	}
+	if o.Size > 0 {
+		header.Set("Content-Length", strconv.FormatInt(o.Size, 10))
+	}
+	return header
+}
@@ -0,0 +1,286 @@
+package object_storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPresignedLocalStorage returns a local storage whose Handler is served
+// under /storage by a test server.
+func newPresignedLocalStorage(t *testing.T) *LocalObjectStorage {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	storage, err := NewLocalObjectStorage(ProviderConfig{
+		BasePath:  t.TempDir(),
+		Endpoint:  server.URL + "/storage",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	mux.Handle("/storage/", storage.Handler())
+	return storage
+}
+
+// send sends req with body, returning the response status and body. The
+// Content-Length header is computed from body.
+func send(t *testing.T, req PresignedRequest, body string) (int, string) {
+	t.Helper()
+	r, err := http.NewRequest(req.Method, req.URL, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	for key := range req.Header {
+		r.Header.Set(key, req.Header.Get(key))
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestLocalObjectStorage_Presign(t *testing.T) {
+	ctx := context.Background()
+	storage := newPresignedLocalStorage(t)
+
+	put, err := storage.PresignPut(ctx, "uploads/photo.jpg", PresignOptions{
+		ContentType: "image/jpeg",
+		Size:        5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to presign upload: %v", err)
+	}
+	if put.Method != http.MethodPut {
+		t.Errorf("Expected method PUT, got %s", put.Method)
+	}
+	if put.Header.Get("Content-Type") != "image/jpeg" || put.Header.Get("Content-Length") != "5" {
+		t.Errorf("Expected content type and length headers, got %v", put.Header)
+	}
+	if until := time.Until(put.Expires); until <= 14*time.Minute || until > 15*time.Minute {
+		t.Errorf("Expected the default expiry of 15 minutes, got %v", until)
+	}
+	if status, body := send(t, put, "image"); status != http.StatusOK {
+		t.Fatalf("Expected upload to succeed, got %d: %s", status, body)
+	}
+
+	get, err := storage.PresignGet(ctx, "uploads/photo.jpg", PresignOptions{ContentType: "image/jpeg"})
+	if err != nil {
+		t.Fatalf("Failed to presign download: %v", err)
+	}
+	r, err := http.Get(get.URL)
+	if err != nil {
+		t.Fatalf("Failed to download: %v", err)
+	}
+	data, _ := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if r.StatusCode != http.StatusOK || string(data) != "image" {
+		t.Fatalf("Expected %q, got %d: %q", "image", r.StatusCode, data)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Expected content type image/jpeg, got %q", ct)
+	}
+}
+
+func TestLocalObjectStorage_PresignRejected(t *testing.T) {
+	ctx := context.Background()
+	storage := newPresignedLocalStorage(t)
+	if _, err := storage.SaveContext(ctx, "file.txt", strings.NewReader("data")); err != nil {
+		t.Fatalf("Failed to save file: %v", err)
+	}
+	opts := PresignOptions{ContentType: "text/plain", Size: 4}
+	put, err := storage.PresignPut(ctx, "file.txt", opts)
+	if err != nil {
+		t.Fatalf("Failed to presign upload: %v", err)
+	}
+	get, err := storage.PresignGet(ctx, "file.txt", PresignOptions{})
+	if err != nil {
+		t.Fatalf("Failed to presign download: %v", err)
+	}
+
+	tampered := put
+	tampered.URL = strings.Replace(put.URL, "size=4", "size=40", 1)
+
+	otherType := put
+	otherType.Header = put.Header.Clone()
+	otherType.Header.Set("Content-Type", "text/html")
+
+	otherKey := get
+	otherKey.URL = strings.Replace(get.URL, "/file.txt", "/other.txt", 1)
+
+	getAsPut := get
+	getAsPut.Method = http.MethodPut
+
+	tests := []struct {
+		name   string
+		req    PresignedRequest
+		body   string
+		status int
+	}{
+		{name: "tampered_size", req: tampered, body: strings.Repeat("x", 40), status: http.StatusForbidden},
+		{name: "other_content_type", req: otherType, body: "data", status: http.StatusForbidden},
+		{name: "other_size", req: put, body: "too long", status: http.StatusForbidden},
+		{name: "other_key", req: otherKey, status: http.StatusForbidden},
+		{name: "other_method", req: getAsPut, body: "data", status: http.StatusForbidden},
+		{name: "unsupported_method", req: PresignedRequest{Method: http.MethodDelete, URL: get.URL}, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := send(t, tt.req, tt.body); status != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, status, body)
+			}
+		})
+	}
+
+	if _, err := storage.StatContext(ctx, "other.txt"); !IsNotExist(err) {
+		t.Errorf("Expected rejected uploads not to be saved, got %v", err)
+	}
+}
+
+func TestLocalObjectStorage_PresignExpired(t *testing.T) {
+	ctx := context.Background()
+	storage := newPresignedLocalStorage(t)
+	if _, err := storage.SaveContext(ctx, "file.txt", strings.NewReader("data")); err != nil {
+		t.Fatalf("Failed to save file: %v", err)
+	}
+	get, err := storage.PresignGet(ctx, "file.txt", PresignOptions{Expiry: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to presign download: %v", err)
+	}
+
+	storage.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if status, _ := send(t, get, ""); status != http.StatusForbidden {
+		t.Errorf("Expected expired URL to be rejected, got %d", status)
+	}
+}
+
+func TestLocalObjectStorage_PresignKeyCleaned(t *testing.T) {
+	storage := newPresignedLocalStorage(t)
+	req, err := storage.PresignPut(context.Background(), "../outside.txt", PresignOptions{})
+	if err != nil {
+		t.Fatalf("Failed to presign upload: %v", err)
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	}
+	if u.Path != "/storage/outside.txt" {
+		t.Errorf("Expected path /storage/outside.txt, got %s", u.Path)
+	}
+	if status, body := send(t, req, "data"); status != http.StatusOK {
+		t.Fatalf("Expected upload to succeed, got %d: %s", status, body)
+	}
+	if _, err := storage.StatContext(context.Background(), "outside.txt"); err != nil {
+		t.Errorf("Expected object inside the base path, got %v", err)
+	}
+}
+
+func TestLocalObjectStorage_PresignNotConfigured(t *testing.T) {
+	storage, err := NewLocalObjectStorage(ProviderConfig{BasePath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	if _, err := storage.PresignGet(context.Background(), "file.txt", PresignOptions{}); err == nil {
+		t.Error("Expected presigning without a secret key to fail")
+	}
+
+	rec := httptest.NewRecorder()
+	storage.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file.txt?expires=1&signature=x", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a secret key, got %d", rec.Code)
+	}
+}
+
+func TestMinioObjectStorage_Presign(t *testing.T) {
+	storage, err := NewMinioObjectStorage(ProviderConfig{
+		Endpoint:  "localhost:9000",
+		Region:    "us-east-1",
+		AccessKey: "minioadmin",
+		SecretKey: "minioadmin",
+		Bucket:    "test-bucket",
+		BasePath:  "uploads",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create MinIO storage: %v", err)
+	}
+
+	put, err := storage.PresignPut(context.Background(), "photo.jpg", PresignOptions{
+		ContentType: "image/jpeg",
+		Size:        5,
+		Expiry:      time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to presign upload: %v", err)
+	}
+	u, err := url.Parse(put.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	}
+	if u.Path != "/test-bucket/uploads/photo.jpg" {
+		t.Errorf("Expected path /test-bucket/uploads/photo.jpg, got %s", u.Path)
+	}
+	if signed := u.Query().Get("X-Amz-SignedHeaders"); signed != "content-length;content-type;host" {
+		t.Errorf("Expected content length and type to be signed, got %q", signed)
+	}
+	if expires := u.Query().Get("X-Amz-Expires"); expires != "3600" {
+		t.Errorf("Expected expiry of 3600 seconds, got %q", expires)
+	}
+
+	get, err := storage.PresignGet(context.Background(), "photo.jpg", PresignOptions{ContentType: "image/jpeg"})
+	if err != nil {
+		t.Fatalf("Failed to presign download: %v", err)
+	}
+	if !strings.Contains(get.URL, "response-content-type=image%2Fjpeg") {
+		t.Errorf("Expected response content type in URL, got %s", get.URL)
+	}
+}
+
+func TestTOSObjectStorage_Presign(t *testing.T) {
+	storage, err := NewTOSObjectStorage(ProviderConfig{
+		Endpoint:  "tos-cn-beijing.volces.com",
+		Region:    "cn-beijing",
+		AccessKey: "access-key",
+		SecretKey: "secret-key",
+		Bucket:    "mybucket",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create TOS storage: %v", err)
+	}
+
+	if _, err := storage.PresignPut(context.Background(), "photo.jpg", PresignOptions{Size: 5}); !errors.Is(err, errPresignSize) {
+		t.Errorf("Expected size constraint to be rejected, got %v", err)
+	}
+	put, err := storage.PresignPut(context.Background(), "photo.jpg", PresignOptions{ContentType: "image/jpeg"})
+	if err != nil {
+		t.Fatalf("Failed to presign upload: %v", err)
+	}
+	u, err := url.Parse(put.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	}
+	if u.Query().Get("X-Tos-Signature") == "" {
+		t.Errorf("Expected signed URL, got %s", put.URL)
+	}
+	// The content type is not part of the signature, only advisory.
+	if signed := u.Query().Get("X-Tos-SignedHeaders"); signed != "host" {
+		t.Errorf("Expected only the host to be signed, got %q", signed)
+	}
+	if put.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("Expected content type header, got %v", put.Header)
+	}
+}
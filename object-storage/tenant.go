@@ -0,0 +1,28 @@
+package object_storage
+
+import (
+	"context"
+	"path"
+
+	"github.com/poly-workshop/go-webmods/app"
+)
+
+// TenantKey returns key under the prefix of the tenant stored in ctx with
+// app.WithTenant, e.g. "acme/invoices/1.pdf" for "invoices/1.pdf". The key is
+// cleaned so that ".." segments cannot reach another tenant's prefix. It
+// returns app.ErrNoTenant if ctx carries no tenant.
+//
+// Example:
+//
+//	key, err := object_storage.TenantKey(ctx, "invoices/1.pdf")
+//	if err != nil {
+//	    return err
+//	}
+//	_, err = storage.Save(key, file)
+func TenantKey(ctx context.Context, key string) (string, error) {
+	tenant, ok := app.TenantFromContext(ctx)
+	if !ok {
+		return "", app.ErrNoTenant
+	}
+	return path.Join(tenant, path.Clean("/"+key)), nil
+}
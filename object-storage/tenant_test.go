@@ -0,0 +1,35 @@
+package object_storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/poly-workshop/go-webmods/app"
+)
+
+func TestTenantKey(t *testing.T) {
+	ctx := app.WithTenant(context.Background(), "acme")
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"invoices/1.pdf", "acme/invoices/1.pdf"},
+		{"/invoices/1.pdf", "acme/invoices/1.pdf"},
+		{"../globex/secret.txt", "acme/globex/secret.txt"},
+		{"a/../../b", "acme/b"},
+	}
+	for _, tt := range tests {
+		got, err := TenantKey(ctx, tt.key)
+		if err != nil {
+			t.Fatalf("TenantKey(%q) failed: %v", tt.key, err)
+		}
+		if got != tt.expected {
+			t.Errorf("TenantKey(%q): expected %q, got %q", tt.key, tt.expected, got)
+		}
+	}
+
+	if _, err := TenantKey(context.Background(), "invoices/1.pdf"); !errors.Is(err, app.ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant without tenant, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package object_storage
 import (
 	"context"
 	"io"
+	"net/http"
 	"os"
 	"path"
 	"strconv"
@@ -10,10 +11,10 @@ import (
 	"time"
 
 	"github.com/volcengine/ve-tos-golang-sdk/v2/tos"
+	"github.com/volcengine/ve-tos-golang-sdk/v2/tos/enum"
 )
 
 type TOSObjectStorage struct {
-	ctx            context.Context
 	client         *tos.ClientV2
 	internalClient *tos.ClientV2
 	useInternal    bool
@@ -66,7 +67,11 @@ func (o *TOSObject) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (o *TOSObject) Stat() (os.FileInfo, error) {
-	resp, err := o.client.HeadObjectV2(o.ctx, &tos.HeadObjectV2Input{
+	return o.StatContext(o.ctx)
+}
+
+func (o *TOSObject) StatContext(ctx context.Context) (os.FileInfo, error) {
+	resp, err := o.client.HeadObjectV2(ctx, &tos.HeadObjectV2Input{
 		Bucket: o.bucket,
 		Key:    o.key,
 	})
@@ -139,7 +144,6 @@ func NewTOSObjectStorage(config ProviderConfig) (*TOSObjectStorage, error) {
 	}
 
 	return &TOSObjectStorage{
-		ctx:            context.Background(),
 		client:         client,
 		internalClient: internalClient,
 		useInternal:    config.UseInternal,
@@ -154,12 +158,16 @@ func (s *TOSObjectStorage) getObjectPath(objectPath string) string {
 }
 
 func (s *TOSObjectStorage) Save(path string, r io.Reader) (int64, error) {
+	return s.SaveContext(context.Background(), path, r)
+}
+
+func (s *TOSObjectStorage) SaveContext(ctx context.Context, path string, r io.Reader) (int64, error) {
 	client := s.client
 	if s.useInternal {
 		client = s.internalClient
 	}
 
-	_, err := client.PutObjectV2(s.ctx, &tos.PutObjectV2Input{
+	_, err := client.PutObjectV2(ctx, &tos.PutObjectV2Input{
 		PutObjectBasicInput: tos.PutObjectBasicInput{
 			Bucket: s.bucket,
 			Key:    s.getObjectPath(path),
@@ -169,7 +177,7 @@ func (s *TOSObjectStorage) Save(path string, r io.Reader) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	fileInfo, err := s.Stat(path)
+	fileInfo, err := s.StatContext(ctx, path)
 	if err != nil {
 		return 0, err
 	}
@@ -177,21 +185,29 @@ func (s *TOSObjectStorage) Save(path string, r io.Reader) (int64, error) {
 }
 
 func (s *TOSObjectStorage) List(path string) ([]Object, error) {
+	return asObjects[Object](s.list(context.Background(), path))
+}
+
+func (s *TOSObjectStorage) ListContext(ctx context.Context, path string) ([]ContextObject, error) {
+	return asObjects[ContextObject](s.list(ctx, path))
+}
+
+func (s *TOSObjectStorage) list(ctx context.Context, path string) ([]*TOSObject, error) {
 	client := s.client
 	if s.useInternal {
 		client = s.internalClient
 	}
-	res, err := client.ListObjectsType2(s.ctx, &tos.ListObjectsType2Input{
+	res, err := client.ListObjectsType2(ctx, &tos.ListObjectsType2Input{
 		Bucket: s.bucket,
 		Prefix: s.getObjectPath(path),
 	})
 	if err != nil {
 		return nil, err
 	}
-	objs := []Object{}
+	objs := []*TOSObject{}
 	for _, file := range res.Contents {
 		objs = append(objs, &TOSObject{
-			ctx:    s.ctx,
+			ctx:    ctx,
 			client: client,
 			bucket: s.bucket,
 			key:    file.Key,
@@ -201,26 +217,38 @@ func (s *TOSObjectStorage) List(path string) ([]Object, error) {
 }
 
 func (s *TOSObjectStorage) Open(path string) (Object, error) {
+	return s.open(context.Background(), path), nil
+}
+
+func (s *TOSObjectStorage) OpenContext(ctx context.Context, path string) (ContextObject, error) {
+	return s.open(ctx, path), nil
+}
+
+func (s *TOSObjectStorage) open(ctx context.Context, path string) *TOSObject {
 	client := s.client
 	if s.useInternal {
 		client = s.internalClient
 	}
 
 	return &TOSObject{
-		ctx:    s.ctx,
+		ctx:    ctx,
 		client: client,
 		bucket: s.bucket,
 		key:    s.getObjectPath(path),
-	}, nil
+	}
 }
 
 func (s *TOSObjectStorage) Stat(path string) (os.FileInfo, error) {
+	return s.StatContext(context.Background(), path)
+}
+
+func (s *TOSObjectStorage) StatContext(ctx context.Context, path string) (os.FileInfo, error) {
 	client := s.client
 	if s.useInternal {
 		client = s.internalClient
 	}
 
-	resp, err := client.HeadObjectV2(s.ctx, &tos.HeadObjectV2Input{
+	resp, err := client.HeadObjectV2(ctx, &tos.HeadObjectV2Input{
 		Bucket: s.bucket,
 		Key:    s.getObjectPath(path),
 	})
@@ -245,14 +273,74 @@ func (s *TOSObjectStorage) Stat(path string) (os.FileInfo, error) {
 }
 
 func (s *TOSObjectStorage) Delete(path string) error {
+	return s.DeleteContext(context.Background(), path)
+}
+
+func (s *TOSObjectStorage) DeleteContext(ctx context.Context, path string) error {
 	client := s.client
 	if s.useInternal {
 		client = s.internalClient
 	}
 
-	_, err := client.DeleteObjectV2(s.ctx, &tos.DeleteObjectV2Input{
+	_, err := client.DeleteObjectV2(ctx, &tos.DeleteObjectV2Input{
 		Bucket: s.bucket,
 		Key:    s.getObjectPath(path),
 	})
 	return err
 }
+
+// PresignGet returns a presigned URL downloading the object at path. The
+// response is served with opts.ContentType if set. URLs always use the public
+// endpoint, since they are meant for clients outside the region.
+func (s *TOSObjectStorage) PresignGet(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error) {
+	expiry := opts.expiry()
+	query := map[string]string{}
+	if opts.ContentType != "" {
+		query["response-content-type"] = opts.ContentType
+	}
+
+	out, err := s.client.PreSignedURL(&tos.PreSignedURLInput{
+		HTTPMethod: enum.HttpMethodGet,
+		Bucket:     s.bucket,
+		Key:        s.getObjectPath(path),
+		Expires:    int64(expiry / time.Second),
+		Query:      query,
+	})
+	if err != nil {
+		return PresignedRequest{}, err
+	}
+	return PresignedRequest{
+		Method:  http.MethodGet,
+		URL:     out.SignedUrl,
+		Header:  http.Header{},
+		Expires: time.Now().Add(expiry),
+	}, nil
+}
+
+// PresignPut returns a presigned URL uploading the object at path.
+//
+// TOS query signatures only cover the host and x-tos-* headers, so the
+// content type is advisory: it is returned as a header to send but not
+// enforced. opts.Size is rejected with an error since it cannot be enforced.
+func (s *TOSObjectStorage) PresignPut(ctx context.Context, path string, opts PresignOptions) (PresignedRequest, error) {
+	if opts.Size > 0 {
+		return PresignedRequest{}, errPresignSize
+	}
+	expiry := opts.expiry()
+
+	out, err := s.client.PreSignedURL(&tos.PreSignedURLInput{
+		HTTPMethod: enum.HttpMethodPut,
+		Bucket:     s.bucket,
+		Key:        s.getObjectPath(path),
+		Expires:    int64(expiry / time.Second),
+	})
+	if err != nil {
+		return PresignedRequest{}, err
+	}
+	return PresignedRequest{
+		Method:  http.MethodPut,
+		URL:     out.SignedUrl,
+		Header:  opts.putHeader(),
+		Expires: time.Now().Add(expiry),
+	}, nil
+}
@@ -25,6 +25,7 @@ type Cache struct {
 	*cache.Cache
 	rdb                redis.UniversalClient
 	refreshEventChannel string
+	localCacheTTL       time.Duration
 }
 
 // CacheConfig holds configuration for creating a new cache instance.
@@ -81,6 +82,7 @@ func NewCache(cfg CacheConfig) *Cache {
 		Cache:               cacheClient,
 		rdb:                 cfg.Redis,
 		refreshEventChannel: refreshEventChannel,
+		localCacheTTL:       localCacheTTL,
 	}
 
 	// Subscribe cache refresh event
@@ -148,6 +150,26 @@ func (c *Cache) Set(ctx context.Context, key string, value any, expiration time.
 	return c.publishCacheRefreshEvent(ctx, key)
 }
 
+// SetSkippingLocalCache stores value in Redis only, e.g. for entries that
+// expire before the local cache TTL.
+func (c *Cache) SetSkippingLocalCache(ctx context.Context, key string, value any, expiration time.Duration) error {
+	if err := c.Cache.Set(&cache.Item{
+		Ctx:            ctx,
+		Key:            key,
+		Value:          value,
+		TTL:            expiration,
+		SkipLocalCache: true,
+	}); err != nil {
+		return err
+	}
+	return c.publishCacheRefreshEvent(ctx, key)
+}
+
+// LocalCacheTTL returns the time-to-live of entries in the local cache.
+func (c *Cache) LocalCacheTTL() time.Duration {
+	return c.localCacheTTL
+}
+
 func (c *Cache) Delete(ctx context.Context, key string) error {
 	if err := c.Cache.Delete(ctx, key); err != nil {
 		return err